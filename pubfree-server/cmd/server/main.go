@@ -11,10 +11,15 @@ import (
 
 	"pubfree-platform/pubfree-server/internal/config"
 	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
 	"pubfree-platform/pubfree-server/internal/router"
+	"pubfree-platform/pubfree-server/internal/service"
 	"pubfree-platform/pubfree-server/pkg/logger"
+	"pubfree-platform/pubfree-server/pkg/storage"
+	"pubfree-platform/pubfree-server/pkg/workerpool"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -33,6 +38,9 @@ func main() {
 		os.Exit(0)
 	}
 
+	// gateway 子命令：只启动静态站点网关，不提供API
+	gatewayOnly := len(os.Args) > 1 && os.Args[1] == "gateway"
+
 	// 显示版本信息
 	fmt.Printf("PubFree Server %s (built %s, commit %s)\n", version, buildTime, gitCommit)
 
@@ -56,18 +64,103 @@ func main() {
 		logger.Logger.Fatalf("数据库初始化失败: %v", err)
 	}
 
+	// 初始化部署产物存储
+	store, err := config.InitStorage(cfg.Storage)
+	if err != nil {
+		logger.Logger.Fatalf("存储初始化失败: %v", err)
+	}
+
+	var (
+		servers     []*http.Server
+		pool        *workerpool.Pool
+		rdb         *redis.Client
+		stopCleanup = func() {}
+	)
+	if !gatewayOnly {
+		// 初始化Redis，连接失败时降级为仅使用数据库
+		if cfg.Redis.Enabled {
+			rdb, err = config.InitRedis(cfg.Redis)
+			if err != nil {
+				logger.Logger.Warnf("Redis初始化失败，将不使用缓存: %v", err)
+			}
+		}
+
+		// 部署的下载、解压和校验在后台任务池中执行
+		pool = workerpool.New(cfg.Deploy.Workers, cfg.Deploy.QueueSize)
+		servers = append(servers, newAPIServer(db, rdb, store, pool, cfg))
+
+		var cleanupCtx context.Context
+		cleanupCtx, stopCleanup = context.WithCancel(context.Background())
+		go runUploadCleanup(cleanupCtx, db, store, cfg)
+		go runDeployRecovery(cleanupCtx, db, cfg)
+	}
+	if gatewayOnly || cfg.Gateway.Enabled {
+		servers = append(servers, &http.Server{
+			Addr:           cfg.Gateway.Port,
+			Handler:        router.SetupGateway(db, store, cfg),
+			ReadTimeout:    cfg.Server.ReadTimeout,
+			WriteTimeout:   cfg.Server.WriteTimeout,
+			MaxHeaderBytes: cfg.Server.MaxHeaderMB << 20,
+		})
+	}
+
+	// 在goroutine中启动服务器
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			logger.Logger.Infof("服务器启动在端口: %s", srv.Addr)
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Logger.Fatalf("服务器启动失败: %v", err)
+			}
+		}(srv)
+	}
+
+	// 等待中断信号来优雅地关闭服务器
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	logger.Logger.Info("正在关闭服务器...")
+
+	// 设置5秒的超时时间用于优雅关闭
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Logger.Errorf("服务器强制关闭: %v", err)
+		} else {
+			logger.Logger.Infof("服务器已优雅关闭: %s", srv.Addr)
+		}
+	}
+
+	stopCleanup()
+
+	// 等待部署任务完成，超时后中断剩余任务并将其标记为失败
+	if pool != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Deploy.ShutdownTimeout)
+		defer cancel()
+		if err := pool.Shutdown(ctx); err != nil {
+			logger.Logger.Warnf("部署任务未在 %s 内完成，已中断", cfg.Deploy.ShutdownTimeout)
+		} else {
+			logger.Logger.Info("部署任务已全部完成")
+		}
+	}
+
+	if rdb != nil {
+		if err := rdb.Close(); err != nil {
+			logger.Logger.Warnf("关闭Redis连接失败: %v", err)
+		}
+	}
+}
+
+// newAPIServer 创建API服务，包括数据库迁移，rdb 为 nil 时不使用Redis缓存
+func newAPIServer(db *gorm.DB, rdb *redis.Client, store storage.Storage, pool *workerpool.Pool, cfg *config.Config) *http.Server {
 	// 自动迁移数据库表
 	if err := autoMigrate(db); err != nil {
 		logger.Logger.Fatalf("数据库迁移失败: %v", err)
 	}
 
-	// 初始化路由
-	r := router.SetupRouter(db)
-
-	// 添加中间件
-	r.Use(logger.GinLogger())
-	r.Use(logger.GinRecovery())
-	r.Use(corsMiddleware())
+	// 初始化路由，日志、恢复和跨域中间件在其中添加
+	r := router.SetupRouter(db, rdb, store, pool, cfg)
 
 	// 添加健康检查接口
 	r.GET("/health", func(c *gin.Context) {
@@ -79,37 +172,68 @@ func main() {
 		})
 	})
 
-	// 创建HTTP服务器
-	srv := &http.Server{
+	return &http.Server{
 		Addr:           cfg.Server.Port,
 		Handler:        r,
 		ReadTimeout:    cfg.Server.ReadTimeout,
 		WriteTimeout:   cfg.Server.WriteTimeout,
 		MaxHeaderBytes: cfg.Server.MaxHeaderMB << 20,
 	}
+}
 
-	// 在goroutine中启动服务器
-	go func() {
-		logger.Logger.Infof("服务器启动在端口: %s", cfg.Server.Port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			logger.Logger.Fatalf("服务器启动失败: %v", err)
+// runUploadCleanup 定期清理过期的断点续传上传和超过同样期限未提交的增量上传部署，直到 ctx 结束
+func runUploadCleanup(ctx context.Context, db *gorm.DB, store storage.Storage, cfg *config.Config) {
+	uploadService := service.NewUploadService(repository.NewUploadRepository(db), cfg.Upload, cfg.Deploy.MaxArtifactMB<<20)
+	// 只用于处理过期的增量上传，不需要其他依赖
+	deployService := service.NewDeployService(nil, nil, repository.NewProjectDeployRepository(db), nil, nil, nil, nil, uploadService, store, nil, nil, cfg.Deploy)
+
+	ticker := time.NewTicker(cfg.Upload.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := uploadService.Cleanup(ctx)
+			if err != nil && ctx.Err() == nil {
+				logger.Logger.Errorf("清理过期上传失败: %v", err)
+			}
+			if count > 0 {
+				logger.Logger.Infof("已清理 %d 个过期上传", count)
+			}
+
+			count, err = deployService.ExpireUploading(ctx, cfg.Upload.Expiration)
+			if err != nil && ctx.Err() == nil {
+				logger.Logger.Errorf("处理过期的增量上传部署失败: %v", err)
+			}
+			if count > 0 {
+				logger.Logger.Warnf("%d 个超过有效期未提交的增量上传部署已标记为失败", count)
+			}
 		}
-	}()
+	}
+}
 
-	// 等待中断信号来优雅地关闭服务器
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	logger.Logger.Info("正在关闭服务器...")
+// runDeployRecovery 启动时及此后定期将租约过期的部署标记为失败，直到 ctx 结束。
+// 只处理已退出的实例留下的部署，其他实例正在处理的部署会持续续约，不受影响
+func runDeployRecovery(ctx context.Context, db *gorm.DB, cfg *config.Config) {
+	projectDeployRepo := repository.NewProjectDeployRepository(db)
 
-	// 设置5秒的超时时间用于优雅关闭
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	ticker := time.NewTicker(cfg.Deploy.LeaseTTL / 2)
+	defer ticker.Stop()
+	for {
+		count, err := projectDeployRepo.FailExpired(ctx, cfg.Deploy.LeaseTTL, service.ReasonLeaseExpired)
+		if err != nil && ctx.Err() == nil {
+			logger.Logger.Errorf("处理租约过期的部署失败: %v", err)
+		}
+		if count > 0 {
+			logger.Logger.Warnf("%d 个租约过期的部署已标记为失败", count)
+		}
 
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Logger.Errorf("服务器强制关闭: %v", err)
-	} else {
-		logger.Logger.Info("服务器已优雅关闭")
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
 }
 
@@ -126,35 +250,34 @@ func autoMigrate(db *gorm.DB) error {
 		&model.ProjectEnv{},
 		&model.ProjectDomain{},
 		&model.ProjectEnvDeploy{},
+		&model.RefreshToken{},
+		&model.ApiToken{},
+		&model.DeployActivation{},
+		&model.GrayRule{},
+		&model.DeployTransition{},
+		&model.DeployLog{},
+		&model.DeployFile{},
+		&model.Upload{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	// 成员角色改为位标记之前的数据，须在角色列改为 int 之后执行
+	ctx := context.Background()
+	projectMembers, err := repository.NewProjectMemberRepository(db).MigrateLegacyRoles(ctx)
+	if err != nil {
+		return err
+	}
+	groupMembers, err := repository.NewGroupMemberRepository(db).MigrateLegacyRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if projectMembers > 0 || groupMembers > 0 {
+		logger.Logger.Infof("已转换 %d 个项目成员和 %d 个空间成员的旧角色", projectMembers, groupMembers)
+	}
+
 	logger.Logger.Info("数据库迁移完成")
 	return nil
 }
-
-// corsMiddleware CORS中间件
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		method := c.Request.Method
-		origin := c.Request.Header.Get("Origin")
-
-		if origin != "" {
-			c.Header("Access-Control-Allow-Origin", origin)
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, UPDATE")
-			c.Header("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, Authorization, Token")
-			c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Cache-Control, Content-Language, Content-Type")
-			c.Header("Access-Control-Allow-Credentials", "true")
-		}
-
-		if method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
-
-		c.Next()
-	}
-}
@@ -17,6 +17,11 @@ type Config struct {
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Logger   LoggerConfig   `mapstructure:"logger"`
 	App      AppConfig      `mapstructure:"app"`
+	Storage  StorageConfig  `mapstructure:"storage"`
+	Deploy   DeployConfig   `mapstructure:"deploy"`
+	Upload   UploadConfig   `mapstructure:"upload"`
+	Gateway  GatewayConfig  `mapstructure:"gateway"`
+	Build    BuildConfig    `mapstructure:"build"`
 }
 
 // ServerConfig 服务器配置
@@ -48,6 +53,7 @@ type DatabaseConfig struct {
 
 // RedisConfig Redis配置
 type RedisConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	Password string `mapstructure:"password"`
@@ -57,9 +63,85 @@ type RedisConfig struct {
 
 // JWTConfig JWT配置
 type JWTConfig struct {
-	Secret    string        `mapstructure:"secret"`
-	ExpiresAt time.Duration `mapstructure:"expires_at"`
-	Issuer    string        `mapstructure:"issuer"`
+	Secret           string        `mapstructure:"secret"`
+	ExpiresAt        time.Duration `mapstructure:"expires_at"`
+	RefreshExpiresAt time.Duration `mapstructure:"refresh_expires_at"`
+	Issuer           string        `mapstructure:"issuer"`
+}
+
+// StorageConfig 部署产物存储配置
+type StorageConfig struct {
+	Driver string             `mapstructure:"driver"` // local 或 s3
+	Local  LocalStorageConfig `mapstructure:"local"`
+	S3     S3StorageConfig    `mapstructure:"s3"`
+}
+
+// LocalStorageConfig 本地文件系统存储配置
+type LocalStorageConfig struct {
+	Root string `mapstructure:"root"`
+}
+
+// S3StorageConfig S3兼容对象存储配置，也适用于 MinIO
+type S3StorageConfig struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Region    string `mapstructure:"region"`
+	Bucket    string `mapstructure:"bucket"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Prefix    string `mapstructure:"prefix"`
+	UseSSL    bool   `mapstructure:"use_ssl"`
+}
+
+// DeployConfig 部署配置
+type DeployConfig struct {
+	MaxArtifactMB int64 `mapstructure:"max_artifact_mb"` // 上传压缩包的大小上限
+	MaxUnpackedMB int64 `mapstructure:"max_unpacked_mb"` // 解压后的总大小上限
+	MaxFiles      int   `mapstructure:"max_files"`       // 单次部署的文件数量上限
+
+	DownloadTimeout      time.Duration `mapstructure:"download_timeout"`       // 远程地址部署的下载超时
+	AllowPrivateNetworks bool          `mapstructure:"allow_private_networks"` // 允许从内网、回环地址下载，仅用于开发环境
+
+	Workers         int           `mapstructure:"workers"`          // 同时处理的部署任务数
+	QueueSize       int           `mapstructure:"queue_size"`       // 等待处理的部署任务上限，超出时拒绝新部署
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // 关闭服务时等待部署任务完成的时间，超时的任务标记为失败
+	LeaseTTL        time.Duration `mapstructure:"lease_ttl"`        // 部署任务的租约时长，实例退出后超过该时长未续约的部署标记为失败
+}
+
+// UploadConfig tus 断点续传的配置，上传中的内容保存在本地目录，多实例部署时需要共享该目录。
+// 单个上传的大小上限与 deploy.max_artifact_mb 一致
+type UploadConfig struct {
+	Dir             string        `mapstructure:"dir"`              // 上传内容的保存目录
+	Expiration      time.Duration `mapstructure:"expiration"`       // 最后一次写入后的有效期，过期的上传会被清理，超过该时长未提交的增量上传部署标记为失败
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"` // 清理过期上传的间隔
+}
+
+// BuildConfig 从代码仓库构建部署的配置，构建机需要安装 git 与构建所需的工具
+type BuildConfig struct {
+	Enabled        bool          `mapstructure:"enabled"`
+	WorkDir        string        `mapstructure:"work_dir"`         // 构建工作目录，为空时使用系统临时目录
+	Timeout        time.Duration `mapstructure:"timeout"`          // 克隆与构建的总时长上限
+	MaxMemoryMB    int64         `mapstructure:"max_memory_mb"`    // 构建进程的虚拟内存上限，0 为不限制
+	MaxFileMB      int64         `mapstructure:"max_file_mb"`      // 构建进程写入单个文件的大小上限，0 为不限制
+	MaxLogLines    int           `mapstructure:"max_log_lines"`    // 记录到部署日志的输出行数上限
+	AllowLocalRepo bool          `mapstructure:"allow_local_repo"` // 允许使用服务器本地仓库，仅用于测试
+	User           string        `mapstructure:"user"`             // 执行构建的无特权系统用户，服务须以 root 运行才能切换
+	RunAsServer    bool          `mapstructure:"run_as_server"`    // 未配置 user 时以服务自身的用户执行构建，仅用于开发和测试
+}
+
+// GatewayConfig 静态站点网关配置
+type GatewayConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`   // 是否随API服务一起启动网关
+	Port     string        `mapstructure:"port"`      // 网关监听端口，与API服务分开
+	CacheTTL time.Duration `mapstructure:"cache_ttl"` // 域名解析结果的缓存时间
+	Preview  PreviewConfig `mapstructure:"preview"`
+}
+
+// PreviewConfig 部署预览地址配置，预览域名为 <部署ID>--<项目>.preview.<base_domain>，
+// 需要将 *.preview.<base_domain> 解析到网关
+type PreviewConfig struct {
+	BaseDomain string `mapstructure:"base_domain"` // 为空时不提供预览地址
+	Scheme     string `mapstructure:"scheme"`      // http 或 https
+	Port       string `mapstructure:"port"`        // 预览地址中的端口，为空时使用协议默认端口
 }
 
 // LoggerConfig 日志配置
@@ -81,6 +163,7 @@ type AppConfig struct {
 	Description string `mapstructure:"description"`
 	Debug       bool   `mapstructure:"debug"`
 	Timezone    string `mapstructure:"timezone"`
+	Admins      []uint `mapstructure:"admins"` // 平台管理员的用户ID
 }
 
 // 全局配置实例
@@ -158,9 +241,16 @@ func setupEnvBindings() {
 	viper.BindEnv("jwt.secret", "JWT_SECRET")
 	viper.BindEnv("jwt.expires_at", "JWT_EXPIRE_TIME")
 
+	// 对象存储相关
+	viper.BindEnv("storage.s3.endpoint", "S3_ENDPOINT")
+	viper.BindEnv("storage.s3.access_key", "S3_ACCESS_KEY")
+	viper.BindEnv("storage.s3.secret_key", "S3_SECRET_KEY")
+
 	// 服务器相关
 	viper.BindEnv("server.mode", "GIN_MODE")
 	viper.BindEnv("server.port", "SERVER_PORT")
+	viper.BindEnv("gateway.port", "GATEWAY_PORT")
+	viper.BindEnv("gateway.preview.base_domain", "PREVIEW_BASE_DOMAIN")
 
 	// 日志相关
 	viper.BindEnv("logger.level", "LOG_LEVEL")
@@ -206,6 +296,17 @@ func applyEnvOverrides(config *Config) {
 		}
 	}
 
+	// 对象存储配置覆盖
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		config.Storage.S3.Endpoint = endpoint
+	}
+	if accessKey := os.Getenv("S3_ACCESS_KEY"); accessKey != "" {
+		config.Storage.S3.AccessKey = accessKey
+	}
+	if secretKey := os.Getenv("S3_SECRET_KEY"); secretKey != "" {
+		config.Storage.S3.SecretKey = secretKey
+	}
+
 	// 服务器配置覆盖
 	if mode := os.Getenv("GIN_MODE"); mode != "" {
 		config.Server.Mode = mode
@@ -213,6 +314,12 @@ func applyEnvOverrides(config *Config) {
 	if port := os.Getenv("SERVER_PORT"); port != "" {
 		config.Server.Port = port
 	}
+	if port := os.Getenv("GATEWAY_PORT"); port != "" {
+		config.Gateway.Port = port
+	}
+	if baseDomain := os.Getenv("PREVIEW_BASE_DOMAIN"); baseDomain != "" {
+		config.Gateway.Preview.BaseDomain = baseDomain
+	}
 
 	// 日志配置覆盖
 	if level := os.Getenv("LOG_LEVEL"); level != "" {
@@ -237,6 +344,55 @@ func validateConfig(config *Config) error {
 	if config.JWT.Secret == "" {
 		return fmt.Errorf("jwt.secret 不能为空")
 	}
+	if config.JWT.ExpiresAt <= 0 {
+		return fmt.Errorf("jwt.expires_at 必须大于0")
+	}
+	if config.JWT.RefreshExpiresAt <= 0 {
+		return fmt.Errorf("jwt.refresh_expires_at 必须大于0")
+	}
+	switch config.Storage.Driver {
+	case StorageDriverLocal:
+		if config.Storage.Local.Root == "" {
+			return fmt.Errorf("storage.local.root 不能为空")
+		}
+	case StorageDriverS3:
+		if config.Storage.S3.Endpoint == "" || config.Storage.S3.Bucket == "" {
+			return fmt.Errorf("storage.s3.endpoint 和 storage.s3.bucket 不能为空")
+		}
+	default:
+		return fmt.Errorf("不支持的 storage.driver: %q", config.Storage.Driver)
+	}
+	if config.Deploy.MaxArtifactMB <= 0 || config.Deploy.MaxUnpackedMB <= 0 || config.Deploy.MaxFiles <= 0 {
+		return fmt.Errorf("deploy.max_artifact_mb、max_unpacked_mb、max_files 必须大于0")
+	}
+	if config.Gateway.Port == "" {
+		return fmt.Errorf("gateway.port 不能为空")
+	}
+	if preview := config.Gateway.Preview; preview.BaseDomain != "" && preview.Scheme != "http" && preview.Scheme != "https" {
+		return fmt.Errorf("gateway.preview.scheme 只能是 http 或 https")
+	}
+	if config.Deploy.DownloadTimeout <= 0 {
+		return fmt.Errorf("deploy.download_timeout 必须大于0")
+	}
+	if config.Deploy.Workers <= 0 || config.Deploy.QueueSize <= 0 || config.Deploy.ShutdownTimeout <= 0 {
+		return fmt.Errorf("deploy.workers、queue_size、shutdown_timeout 必须大于0")
+	}
+	// 续约和过期检查的定时器按租约时长的几分之一触发，时长过短时定时器间隔为0
+	if config.Deploy.LeaseTTL < time.Second {
+		return fmt.Errorf("deploy.lease_ttl 不能小于1s")
+	}
+	if config.Upload.Dir == "" {
+		return fmt.Errorf("upload.dir 不能为空")
+	}
+	if config.Upload.Expiration <= 0 || config.Upload.CleanupInterval <= 0 {
+		return fmt.Errorf("upload.expiration、cleanup_interval 必须大于0")
+	}
+	if build := config.Build; build.Enabled && (build.Timeout <= 0 || build.MaxLogLines <= 0) {
+		return fmt.Errorf("build.timeout、max_log_lines 必须大于0")
+	}
+	if config.Build.MaxMemoryMB < 0 || config.Build.MaxFileMB < 0 {
+		return fmt.Errorf("build.max_memory_mb、max_file_mb 不能小于0")
+	}
 	return nil
 }
 
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pubfree-platform/pubfree-server/pkg/storage"
+)
+
+const (
+	StorageDriverLocal = "local"
+	StorageDriverS3    = "s3"
+)
+
+// InitStorage 根据配置初始化部署产物存储
+func InitStorage(config StorageConfig) (storage.Storage, error) {
+	switch config.Driver {
+	case StorageDriverLocal:
+		store, err := storage.NewLocalStorage(config.Local.Root)
+		if err != nil {
+			return nil, fmt.Errorf("初始化本地存储失败: %w", err)
+		}
+		return store, nil
+	case StorageDriverS3:
+		store, err := storage.NewS3Storage(storage.S3Options{
+			Endpoint:  config.S3.Endpoint,
+			Region:    config.S3.Region,
+			Bucket:    config.S3.Bucket,
+			AccessKey: config.S3.AccessKey,
+			SecretKey: config.S3.SecretKey,
+			Prefix:    config.S3.Prefix,
+			UseSSL:    config.S3.UseSSL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("初始化对象存储失败: %w", err)
+		}
+
+		// 测试连接
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := store.CheckBucket(ctx); err != nil {
+			return nil, fmt.Errorf("对象存储连接测试失败: %w", err)
+		}
+
+		fmt.Println("对象存储连接成功")
+		return store, nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", config.Driver)
+	}
+}
@@ -0,0 +1,13 @@
+package request
+
+type CreateApiTokenRequest struct {
+	Name      string   `json:"name" binding:"required,min=2,max=128"`
+	Scopes    []string `json:"scopes" binding:"required,min=1,dive,oneof=read write deploy"`
+	ExpiresIn int      `json:"expires_in" binding:"omitempty,min=1,max=3650"` // 有效天数，为空表示永不过期
+}
+
+type CreateDeployTokenRequest struct {
+	Name         string `json:"name" binding:"required,min=2,max=128"`
+	ProjectEnvID *uint  `json:"project_env_id"`                                // 为空表示项目下全部环境
+	ExpiresIn    int    `json:"expires_in" binding:"omitempty,min=1,max=3650"` // 有效天数，为空表示永不过期
+}
@@ -1,5 +1,7 @@
 package request
 
+import "pubfree-platform/pubfree-server/internal/model"
+
 type CreateGroupRequest struct {
 	Name        string  `json:"name" binding:"required,min=2,max=128"`
 	Description *string `json:"description" binding:"omitempty,max=255"`
@@ -11,6 +13,6 @@ type UpdateGroupRequest struct {
 }
 
 type AddGroupMemberRequest struct {
-	UserID uint `json:"user_id" binding:"required"`
-	Role   int8 `json:"role" binding:"required,min=1,max=10"`
+	UserID uint       `json:"user_id" binding:"required"`
+	Role   model.Role `json:"role" binding:"required,enum"`
 }
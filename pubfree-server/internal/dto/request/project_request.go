@@ -1,5 +1,7 @@
 package request
 
+import "pubfree-platform/pubfree-server/internal/model"
+
 type CreateProjectRequest struct {
 	Name        string  `json:"name" binding:"required,min=2,max=128"`
 	ZhName      string  `json:"zh_name" binding:"required,min=2,max=128"`
@@ -15,13 +17,58 @@ type UpdateProjectRequest struct {
 }
 
 type AddProjectMemberRequest struct {
+	UserID uint       `json:"user_id" binding:"required"`
+	Role   model.Role `json:"role" binding:"required,enum"`
+}
+
+type TransferProjectRequest struct {
 	UserID uint `json:"user_id" binding:"required"`
-	Role   int8 `json:"role" binding:"required,min=1,max=10"`
 }
 
 type CreateProjectEnvRequest struct {
-	Name    string `json:"name" binding:"required,min=2,max=128"`
-	EnvType int8   `json:"env_type" binding:"required,min=1,max=4"`
+	Name    string         `json:"name" binding:"required,min=2,max=128"`
+	EnvType *model.EnvType `json:"env_type" binding:"required,enum"`
+}
+
+// UpdateBuildSettingsRequest 更新项目的构建配置，整体替换
+type UpdateBuildSettingsRequest struct {
+	RepoURL   string `json:"repo_url" binding:"required,max=512"`
+	Branch    string `json:"branch" binding:"omitempty,max=255"`
+	Command   string `json:"command" binding:"required,max=1024"`
+	OutputDir string `json:"output_dir" binding:"omitempty,max=255"`
+}
+
+// UpdateEnvRoutingRequest 更新环境的路由设置，整体替换
+type UpdateEnvRoutingRequest struct {
+	SPAFallback   bool   `json:"spa_fallback"`
+	TrailingSlash string `json:"trailing_slash" binding:"omitempty,oneof=add remove"`
+	CleanURLs     bool   `json:"clean_urls"`
+	NotFoundPage  string `json:"not_found_page" binding:"omitempty,max=255,startswith=/"`
+}
+
+// UpdateEnvCacheRequest 更新环境的缓存设置，整体替换
+type UpdateEnvCacheRequest struct {
+	Rules []*CacheRuleRequest `json:"rules" binding:"max=50,dive"`
+}
+
+type CacheRuleRequest struct {
+	Path         string `json:"path" binding:"required,max=255,startswith=/"`
+	CacheControl string `json:"cache_control" binding:"required,max=255"`
+}
+
+// SaveGrayRuleRequest 配置灰度环境的分流规则，整体替换
+type SaveGrayRuleRequest struct {
+	ProdEnvID    uint     `json:"prod_env_id" binding:"required"`
+	Percentage   *int     `json:"percentage" binding:"required,min=0,max=100"`
+	ForceHeader  string   `json:"force_header" binding:"omitempty,max=64"`
+	ForceCookie  string   `json:"force_cookie" binding:"omitempty,max=64"`
+	UserIDCookie string   `json:"user_id_cookie" binding:"omitempty,max=64"`
+	AllowUserIDs []string `json:"allow_user_ids" binding:"omitempty,max=1000,dive,required,max=64"`
+}
+
+// UpdateGrayPercentageRequest 调整灰度流量比例
+type UpdateGrayPercentageRequest struct {
+	Percentage *int `json:"percentage" binding:"required,min=0,max=100"`
 }
 
 type CreateProjectDomainRequest struct {
@@ -29,9 +76,42 @@ type CreateProjectDomainRequest struct {
 	Host         string `json:"host" binding:"required,min=3,max=255"`
 }
 
+// CreateProjectDeployRequest 通过远程地址或已完成的断点续传上传创建部署，二者只能指定一个：
+// 远程地址的 target_type 为 url，上传的 target_type 为 zip
 type CreateProjectDeployRequest struct {
-	ProjectEnvID uint    `json:"project_env_id" binding:"required"`
-	Remark       *string `json:"remark" binding:"omitempty,max=255"`
-	TargetType   int8    `json:"target_type" binding:"required,min=1,max=10"`
-	Target       string  `json:"target" binding:"required,min=3,max=512"`
+	ProjectEnvID uint              `json:"project_env_id" binding:"required"`
+	Remark       *string           `json:"remark" binding:"omitempty,max=255"`
+	TargetType   *model.TargetType `json:"target_type" binding:"required,enum"`
+	Target       string            `json:"target" binding:"omitempty,url,max=512"`
+	UploadID     string            `json:"upload_id" binding:"omitempty,len=32,hexadecimal"` // /uploads 接口返回的上传ID
+	Checksum     string            `json:"checksum" binding:"omitempty,len=64,hexadecimal"`  // 可选，压缩包的 sha256
+}
+
+// UploadDeployRequest 上传部署产物，压缩包通过 multipart 的 file 字段提交
+type UploadDeployRequest struct {
+	Remark   *string `form:"remark" binding:"omitempty,max=255"`
+	Filename string  `form:"-"` // 上传的文件名，由 handler 填充
+}
+
+// CreateManifestDeployRequest 增量上传：提交部署的完整文件清单，之后只需上传服务端缺少的文件内容
+type CreateManifestDeployRequest struct {
+	Remark *string         `json:"remark" binding:"omitempty,max=255"`
+	Files  []*ManifestFile `json:"files" binding:"required,min=1,dive"`
+}
+
+type ManifestFile struct {
+	Path   string `json:"path" binding:"required"` // 相对站点根目录的路径
+	SHA256 string `json:"sha256" binding:"required,len=64,hexadecimal"`
+	Size   int64  `json:"size" binding:"min=0"`
+}
+
+// CreateBuildRequest 从代码仓库构建部署，不指定分支时使用项目构建配置中的分支
+type CreateBuildRequest struct {
+	Branch string  `json:"branch" binding:"omitempty,max=255"`
+	Remark *string `json:"remark" binding:"omitempty,max=255"`
+}
+
+// RollbackEnvRequest 回滚环境，不指定部署时回到上一个激活的部署
+type RollbackEnvRequest struct {
+	DeployID *uint `json:"deploy_id"`
 }
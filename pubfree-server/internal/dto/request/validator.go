@@ -0,0 +1,30 @@
+package request
+
+import (
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// enumValidator 由 model 中的枚举类型实现
+type enumValidator interface {
+	IsValid() bool
+}
+
+// RegisterValidators 向gin注册自定义校验规则：
+//
+//	enum: 字段值必须是枚举类型的合法取值
+func RegisterValidators() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return nil
+	}
+	return v.RegisterValidation("enum", validateEnum)
+}
+
+func validateEnum(fl validator.FieldLevel) bool {
+	if !fl.Field().CanInterface() {
+		return false
+	}
+	value, ok := fl.Field().Interface().(enumValidator)
+	return ok && value.IsValid()
+}
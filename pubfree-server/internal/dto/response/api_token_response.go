@@ -0,0 +1,24 @@
+package response
+
+import "time"
+
+type ApiTokenResponse struct {
+	ID           uint       `json:"id"`
+	Name         string     `json:"name"`
+	Kind         string     `json:"kind"`
+	TokenPrefix  string     `json:"token_prefix"`
+	Scopes       []string   `json:"scopes"`
+	UserID       uint       `json:"user_id"`
+	ProjectID    *uint      `json:"project_id"`
+	ProjectEnvID *uint      `json:"project_env_id"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ApiTokenCreatedResponse 创建令牌时返回明文令牌，之后无法再次查看
+type ApiTokenCreatedResponse struct {
+	ApiTokenResponse
+	Token string `json:"token"`
+}
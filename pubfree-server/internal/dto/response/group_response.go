@@ -1,6 +1,9 @@
 package response
 
-import "time"
+import (
+	"pubfree-platform/pubfree-server/internal/model"
+	"time"
+)
 
 type GroupResponse struct {
 	ID           uint         `json:"id"`
@@ -18,6 +21,6 @@ type GroupMemberResponse struct {
 	ID      uint         `json:"id"`
 	GroupID uint         `json:"group_id"`
 	UserID  uint         `json:"user_id"`
-	Role    int8         `json:"role"`
+	Role    model.Role   `json:"role"`
 	User    UserResponse `json:"user"`
 }
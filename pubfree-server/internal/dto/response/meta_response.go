@@ -0,0 +1,15 @@
+package response
+
+// EnumOption 枚举取值，供前端渲染下拉框和展示名称
+type EnumOption struct {
+	Value       int    `json:"value"`
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+}
+
+type EnumsResponse struct {
+	Role       []EnumOption `json:"role"`
+	EnvType    []EnumOption `json:"env_type"`
+	TargetType []EnumOption `json:"target_type"`
+}
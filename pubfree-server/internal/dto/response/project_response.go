@@ -1,41 +1,52 @@
 package response
 
-import "time"
+import (
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/pkg/rules"
+	"time"
+)
 
 type ProjectResponse struct {
-	ID           uint           `json:"id"`
-	Name         string         `json:"name"`
-	ZhName       string         `json:"zh_name"`
-	Description  *string        `json:"description"`
-	OwnerID      uint           `json:"owner_id"`
-	GroupID      *uint          `json:"group_id"`
-	CreateUserID uint           `json:"create_user_id"`
-	Owner        UserResponse   `json:"owner,omitempty"`
-	Group        *GroupResponse `json:"group,omitempty"`
-	CreateUser   UserResponse   `json:"create_user,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID           uint                 `json:"id"`
+	Name         string               `json:"name"`
+	ZhName       string               `json:"zh_name"`
+	Description  *string              `json:"description"`
+	OwnerID      uint                 `json:"owner_id"`
+	GroupID      *uint                `json:"group_id"`
+	CreateUserID uint                 `json:"create_user_id"`
+	Build        *model.BuildSettings `json:"build"`
+	Owner        UserResponse         `json:"owner,omitempty"`
+	Group        *GroupResponse       `json:"group,omitempty"`
+	CreateUser   UserResponse         `json:"create_user,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
 }
 
 type ProjectMemberResponse struct {
-	ID        uint         `json:"id"`
-	ProjectID uint         `json:"project_id"`
-	UserID    uint         `json:"user_id"`
-	Role      int8         `json:"role"`
-	User      UserResponse `json:"user"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+	ID            uint         `json:"id"` // 项目成员记录ID，仅继承自空间的成员为0
+	ProjectID     uint         `json:"project_id"`
+	UserID        uint         `json:"user_id"`
+	Role          model.Role   `json:"role"`   // 有效角色
+	Source        string       `json:"source"` // 有效角色来源：direct 项目成员，group 继承自空间
+	DirectRole    model.Role   `json:"direct_role,omitempty"`
+	InheritedRole model.Role   `json:"inherited_role,omitempty"`
+	GroupID       *uint        `json:"group_id,omitempty"`
+	User          UserResponse `json:"user"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
 }
 
 type ProjectEnvResponse struct {
-	ID           uint         `json:"id"`
-	ProjectID    uint         `json:"project_id"`
-	Name         string       `json:"name"`
-	EnvType      int8         `json:"env_type"`
-	CreateUserID uint         `json:"create_user_id"`
-	CreateUser   UserResponse `json:"create_user,omitempty"`
-	CreatedAt    time.Time    `json:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at"`
+	ID           uint                  `json:"id"`
+	ProjectID    uint                  `json:"project_id"`
+	Name         string                `json:"name"`
+	EnvType      model.EnvType         `json:"env_type"`
+	Routing      model.RoutingSettings `json:"routing"`
+	Cache        model.CacheSettings   `json:"cache"`
+	CreateUserID uint                  `json:"create_user_id"`
+	CreateUser   UserResponse          `json:"create_user,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at"`
 }
 
 type ProjectDomainResponse struct {
@@ -48,15 +59,100 @@ type ProjectDomainResponse struct {
 }
 
 type ProjectDeployResponse struct {
+	ID             uint             `json:"id"`
+	ProjectID      uint             `json:"project_id"`
+	ProjectEnvID   uint             `json:"project_env_id"`
+	Remark         *string          `json:"remark"`
+	TargetType     model.TargetType `json:"target_type"`
+	Target         string           `json:"target"`
+	Checksum       string           `json:"checksum"`
+	Size           int64            `json:"size"`
+	FileCount      int              `json:"file_count"`
+	Status         string           `json:"status"`
+	FailReason     *string          `json:"fail_reason"`
+	Rules          *rules.RuleSet   `json:"rules,omitempty"`
+	SourceDeployID *uint            `json:"source_deploy_id,omitempty"`
+	PreviewURL     string           `json:"preview_url,omitempty"` // 不需要激活即可访问的预览地址
+	CreateUserID   uint             `json:"create_user_id"`
+	ActionUserID   uint             `json:"action_user_id"`
+	IsActive       *int8            `json:"is_active"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+
+	Transitions []*DeployTransitionResponse `json:"transitions,omitempty"` // 仅部署详情返回
+}
+
+// ManifestDeployResponse 增量上传的部署，Missing 为仍需上传的文件内容的 sha256
+type ManifestDeployResponse struct {
+	Deploy  *ProjectDeployResponse `json:"deploy"`
+	Missing []string               `json:"missing"`
+}
+
+type DeployTransitionResponse struct {
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     *string   `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type DeployLogResponse struct {
+	ID        uint      `json:"id"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeployFileResponse struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum"`
+	ContentType string `json:"content_type"`
+	GzipSize    int64  `json:"gzip_size"`   // 预压缩版本的大小，0 表示没有该版本
+	BrotliSize  int64  `json:"brotli_size"` // 预压缩版本的大小，0 表示没有该版本
+}
+
+// DeployFileChange 两个部署之间一个文件的变化，新增的文件 FromSize 为0，删除的文件 ToSize 为0
+type DeployFileChange struct {
+	Path      string `json:"path"`
+	FromSize  int64  `json:"from_size"`
+	ToSize    int64  `json:"to_size"`
+	SizeDelta int64  `json:"size_delta"`
+}
+
+// DeployDiffResponse 从 FromDeployID 切换到 ToDeployID 时文件的变化
+type DeployDiffResponse struct {
+	FromDeployID uint                `json:"from_deploy_id"`
+	ToDeployID   uint                `json:"to_deploy_id"`
+	Added        []*DeployFileChange `json:"added"`
+	Removed      []*DeployFileChange `json:"removed"`
+	Changed      []*DeployFileChange `json:"changed"`
+	SizeDelta    int64               `json:"size_delta"` // 全部文件总大小的变化
+}
+
+type GrayRuleResponse struct {
 	ID           uint      `json:"id"`
 	ProjectID    uint      `json:"project_id"`
-	ProjectEnvID uint      `json:"project_env_id"`
-	Remark       *string   `json:"remark"`
-	TargetType   int8      `json:"target_type"`
-	Target       string    `json:"target"`
-	CreateUserID uint      `json:"create_user_id"`
-	ActionUserID uint      `json:"action_user_id"`
-	IsActive     *int8     `json:"is_active"`
+	ProdEnvID    uint      `json:"prod_env_id"`
+	GrayEnvID    uint      `json:"gray_env_id"`
+	Percentage   int       `json:"percentage"`
+	ForceHeader  string    `json:"force_header"`
+	ForceCookie  string    `json:"force_cookie"`
+	UserIDCookie string    `json:"user_id_cookie"`
+	AllowUserIDs []string  `json:"allow_user_ids"`
+	OperatorID   uint      `json:"operator_id"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// DeployActivationResponse 环境的激活历史记录
+type DeployActivationResponse struct {
+	ID           uint         `json:"id"`
+	ProjectID    uint         `json:"project_id"`
+	ProjectEnvID uint         `json:"project_env_id"`
+	FromDeployID *uint        `json:"from_deploy_id"`
+	ToDeployID   uint         `json:"to_deploy_id"`
+	Action       string       `json:"action"`
+	OperatorID   uint         `json:"operator_id"`
+	Operator     UserResponse `json:"operator"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
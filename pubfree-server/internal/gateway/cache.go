@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+)
+
+const (
+	// immutableCacheControl 文件名带内容哈希的资源，内容变化时文件名随之变化，可以长期缓存
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// revalidateCacheControl 其他文件每次使用缓存前向网关确认，未变化时返回 304
+	revalidateCacheControl = "no-cache"
+
+	// 十六进制哈希的长度范围，webpack、Parcel 等默认 8 到 20 位
+	minHexHashLength = 8
+	maxHexHashLength = 64
+	// bundlerHashLength Vite（Rollup）与 esbuild 默认的哈希长度
+	bundlerHashLength = 8
+)
+
+// wordPattern 驼峰或全小写的单词，如 ProductDetail、vendor，不视为内容哈希
+var wordPattern = regexp.MustCompile(`^[A-Z]?[a-z]{2,}([A-Z][a-z]{2,})*$`)
+
+// setCacheHeaders 设置 Cache-Control 与 ETag，优先级：
+//  1. 环境缓存设置中第一条与请求路径匹配的规则
+//  2. 产物 _headers 中的 Cache-Control
+//  3. 直接请求的文件名带内容哈希时长期缓存，其他文件（包括改写和回退返回的页面）每次确认
+//
+// ETag 取文件内容的 sha256，压缩版本带上编码，旧版部署没有文件清单，只有 Last-Modified
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, site *service.Site, name string, file *model.DeployFile, encoding string, status int) {
+	requestPath := path.Clean("/" + r.URL.Path)
+	switch rule := site.Cache.Match(requestPath); {
+	case rule != nil:
+		w.Header().Set("Cache-Control", rule.CacheControl)
+	case w.Header().Get("Cache-Control") != "":
+	case status == http.StatusOK && name == requestPath && isFingerprinted(name):
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	default:
+		w.Header().Set("Cache-Control", revalidateCacheControl)
+	}
+
+	if file != nil && status == http.StatusOK {
+		tag := file.Checksum
+		if encoding != "" {
+			tag += "-" + encoding
+		}
+		w.Header().Set("ETag", `"`+tag+`"`)
+	}
+}
+
+// isFingerprinted 判断文件名是否带内容哈希，如 index-a1b2c3d4.js、main.3f2a1b9c.chunk.js、index-BkHtLqZw.js。
+// 哈希是文件名中以 "-" 或 "." 分隔的一段，见 isHash
+func isFingerprinted(name string) bool {
+	base := path.Base(name)
+	ext := path.Ext(base)
+	if ext == "" || ext == ".html" {
+		return false
+	}
+
+	segments := strings.FieldsFunc(strings.TrimSuffix(base, ext), func(r rune) bool {
+		return r == '-' || r == '.'
+	})
+	// 第一段是原始文件名
+	for _, segment := range segments[min(1, len(segments)):] {
+		if isHash(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHash 只识别常见打包工具生成的哈希，避免把 192x192、20240101 这类尺寸和日期当作哈希：
+//   - 8 位以上的小写十六进制，不能全是数字（webpack、Parcel 等）
+//   - 8 位大小写混合的 base64url，且不像单词（Rollup）
+//   - 8 位 base32 大写字母和数字，同时包含两者（esbuild）
+func isHash(s string) bool {
+	if len(s) < minHexHashLength || len(s) > maxHexHashLength {
+		return false
+	}
+
+	var digit, upper, lower bool
+	hex, base32 := true, true
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digit = true
+			base32 = base32 && c >= '2' && c <= '7'
+		case c >= 'a' && c <= 'z':
+			lower = true
+			hex = hex && c <= 'f'
+		case c >= 'A' && c <= 'Z':
+			upper = true
+			hex = false
+		case c == '_':
+			hex, base32 = false, false
+		default:
+			return false
+		}
+	}
+
+	switch {
+	case hex:
+		return lower
+	case len(s) != bundlerHashLength:
+		return false
+	case upper && lower:
+		return !wordPattern.MatchString(s)
+	default:
+		return upper && digit && base32
+	}
+}
@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+)
+
+func TestIsFingerprinted(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"/assets/index-a1b2c3d4.js", true},
+		{"/static/js/main.3f2a1b9c.chunk.js", true},
+		{"/static/css/app.0123456789abcdef0123.css", true},
+		{"/assets/index-BkHtLqZw.js", true},
+		{"/assets/ProductDetail-DxYz_abQ.js", true},
+		{"/assets/logo-5d8e9f01.svg", true},
+		{"/assets/chunk-5ZTCTL2M.js", true},
+		{"/assets/vendor-1a2b3c4d5e6f7a8b.js", true},
+		{"/assets/ProductDetail.js", false},
+		{"/assets/vendor-react.js", false},
+		{"/assets/chunk-ProductDetail.js", false},
+		{"/assets/font-abcdef.woff2", false},
+		{"/assets/app-v1.js", false},
+		{"/img/logo.2x.png", false},
+		// 尺寸、日期、版本号不是哈希
+		{"/android-chrome-192x192.png", false},
+		{"/apple-touch-icon-180x180.png", false},
+		{"/photo-20240101.jpg", false},
+		{"/img/banner-1920x1080.jpg", false},
+		{"/fonts/inter-v12-latin.woff2", false},
+		{"/docs/report-2024q1.pdf", false},
+		{"/assets/app-1a2b3c4.js", false},
+		{"/assets/index-ABCDEFGH.js", false},
+		{"/assets/index-abcdefgh.js", false},
+		{"/assets/index-BkHtLqZwX.js", false},
+		// 第一段视为原始文件名
+		{"/a1b2c3d4.js", false},
+		{"/about-a1b2c3d4.html", false},
+		{"/index.html", false},
+		{"/LICENSE", false},
+		{"/favicon.ico", false},
+		{"/assets/app-" + strings.Repeat("a1", 33) + ".js", false},
+		{"/assets/app-a1b2+c3d4.js", false},
+	}
+	for _, tt := range tests {
+		if got := isFingerprinted(tt.name); got != tt.want {
+			t.Errorf("isFingerprinted(%q) = %v, 期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetCacheHeaders(t *testing.T) {
+	site := &service.Site{Cache: model.CacheSettings{Rules: []model.CacheRule{
+		{Path: "/config.json", CacheControl: "no-store"},
+	}}}
+	file := &model.DeployFile{Checksum: "abc"}
+
+	tests := []struct {
+		name         string
+		path         string
+		file         string // 实际返回的文件
+		encoding     string
+		status       int
+		headers      string // 产物 _headers 中设置的 Cache-Control
+		wantCache    string
+		wantETag     string
+		withManifest bool
+	}{
+		{"带哈希的资源长期缓存", "/assets/index-a1b2c3d4.js", "/assets/index-a1b2c3d4.js", "", http.StatusOK, "", immutableCacheControl, `"abc"`, true},
+		{"压缩版本的 ETag 带编码", "/assets/index-a1b2c3d4.js", "/assets/index-a1b2c3d4.js", "br", http.StatusOK, "", immutableCacheControl, `"abc-br"`, true},
+		{"页面每次确认", "/index.html", "/index.html", "", http.StatusOK, "", revalidateCacheControl, `"abc"`, true},
+		{"回退返回的页面每次确认", "/assets/index-a1b2c3d4.js", "/index.html", "", http.StatusOK, "", revalidateCacheControl, `"abc"`, true},
+		{"404 页面每次确认", "/assets/missing-a1b2c3d4.js", "/404.html", "", http.StatusNotFound, "", revalidateCacheControl, "", true},
+		{"环境缓存规则优先", "/config.json", "/config.json", "", http.StatusOK, "max-age=60", "no-store", `"abc"`, true},
+		{"_headers 优先于默认策略", "/assets/index-a1b2c3d4.js", "/assets/index-a1b2c3d4.js", "", http.StatusOK, "max-age=60", "max-age=60", `"abc"`, true},
+		{"旧版部署没有 ETag", "/index.html", "/index.html", "", http.StatusOK, "", revalidateCacheControl, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if tt.headers != "" {
+				w.Header().Set("Cache-Control", tt.headers)
+			}
+			var f *model.DeployFile
+			if tt.withManifest {
+				f = file
+			}
+			setCacheHeaders(w, httptest.NewRequest(http.MethodGet, tt.path, nil), site, tt.file, f, tt.encoding, tt.status)
+
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, 期望 %q", got, tt.wantCache)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, 期望 %q", got, tt.wantETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,495 @@
+// Package gateway 静态站点网关：按 Host 找到环境当前激活的部署，从产物存储中返回文件
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/compress"
+	"pubfree-platform/pubfree-server/pkg/logger"
+	"pubfree-platform/pubfree-server/pkg/rules"
+	"pubfree-platform/pubfree-server/pkg/storage"
+)
+
+const (
+	indexFile = "index.html"
+
+	// maxCachedSites 域名解析缓存的条目上限，防止任意 Host 请求撑满内存
+	maxCachedSites = 10000
+
+	// grayBucketCookie 灰度分桶 cookie，保证同一用户始终落在同一侧
+	grayBucketCookie = "pubfree_gray_bucket"
+	grayBucketMaxAge = 30 * 24 * time.Hour
+)
+
+type Gateway struct {
+	siteService service.SiteService
+	storage     storage.Storage
+	cacheTTL    time.Duration
+
+	mu    sync.RWMutex
+	sites map[string]cachedSite
+}
+
+// cachedSite 域名解析结果的缓存，err 不为空表示站点不存在或未发布
+type cachedSite struct {
+	site      *service.Site
+	err       error
+	expiresAt time.Time
+}
+
+// New 创建网关，cacheTTL 为域名解析结果的缓存时间，激活新部署后最多延迟该时间生效
+func New(siteService service.SiteService, store storage.Storage, cacheTTL time.Duration) *Gateway {
+	return &Gateway{
+		siteService: siteService,
+		storage:     store,
+		cacheTTL:    cacheTTL,
+		sites:       make(map[string]cachedSite),
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	site, err := g.resolve(r.Context(), r.Host)
+	switch {
+	case errors.Is(err, service.ErrSiteNotFound):
+		http.Error(w, "站点不存在", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrSiteNotReady):
+		http.Error(w, "站点尚未发布", http.StatusNotFound)
+		return
+	case err != nil:
+		logger.Logger.Errorf("网关解析站点失败 host=%s: %v", r.Host, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if site.Preview {
+		// 预览地址不应被搜索引擎收录
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
+	g.serveFile(w, r, g.pickSite(w, r, site))
+}
+
+// pickSite 按灰度规则为请求选择生产或灰度站点，优先级：
+//  1. 强制请求头、强制 cookie
+//  2. 用户ID白名单
+//  3. 分桶 cookie：首次访问随机分到 0-99 号桶，桶号小于灰度比例的进入灰度。
+//     桶号保持不变，逐步调大比例时已进入灰度的用户不会回到生产
+func (g *Gateway) pickSite(w http.ResponseWriter, r *http.Request, site *service.Site) *service.Site {
+	gray := site.Gray
+	if gray == nil {
+		return site
+	}
+	rule := gray.Rule
+
+	// 同一地址的响应因请求而异，不能被共享缓存复用
+	w.Header().Add("Vary", "Cookie")
+	if rule.ForceHeader != "" {
+		w.Header().Add("Vary", rule.ForceHeader)
+		if toGray, ok := grayFlag(r.Header.Get(rule.ForceHeader)); ok {
+			return choose(site, toGray)
+		}
+	}
+	if rule.ForceCookie != "" {
+		if cookie, err := r.Cookie(rule.ForceCookie); err == nil {
+			if toGray, ok := grayFlag(cookie.Value); ok {
+				return choose(site, toGray)
+			}
+		}
+	}
+	if rule.UserIDCookie != "" && len(rule.AllowUserIDs) > 0 {
+		if cookie, err := r.Cookie(rule.UserIDCookie); err == nil && slices.Contains(rule.AllowUserIDs, cookie.Value) {
+			return gray.Site
+		}
+	}
+
+	if rule.Percentage <= 0 {
+		return site
+	}
+	bucket := -1
+	if cookie, err := r.Cookie(grayBucketCookie); err == nil {
+		if n, err := strconv.Atoi(cookie.Value); err == nil && n >= 0 && n < 100 {
+			bucket = n
+		}
+	}
+	if bucket < 0 {
+		bucket = rand.IntN(100)
+		http.SetCookie(w, &http.Cookie{
+			Name:     grayBucketCookie,
+			Value:    strconv.Itoa(bucket),
+			Path:     "/",
+			MaxAge:   int(grayBucketMaxAge / time.Second),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return choose(site, bucket < rule.Percentage)
+}
+
+func choose(site *service.Site, toGray bool) *service.Site {
+	if toGray {
+		return site.Gray.Site
+	}
+	return site
+}
+
+// grayFlag 解析强制分流的取值，无法识别时 ok 为 false
+func grayFlag(value string) (toGray bool, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "gray":
+		return true, true
+	case "0", "false", "prod":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// serveFile 按部署的规则和环境的路由设置查找并返回文件：
+//  1. 添加 _headers 中与请求路径匹配的响应头
+//  2. 执行 _redirects 中第一条匹配的规则，未强制（!）的规则在路径对应的文件存在时不生效
+//  3. 开启 CleanURLs 时，*.html 地址重定向到去掉扩展名的地址
+//  4. 按结尾斜杠策略重定向
+//  5. 依次查找 路径本身、路径.html（CleanURLs）、路径/index.html
+//  6. 都不存在时，页面路径在开启 SPAFallback 时返回 /index.html，否则返回自定义404页面
+func (g *Gateway) serveFile(w http.ResponseWriter, r *http.Request, site *service.Site) {
+	routing := site.Routing
+	name := path.Clean("/" + r.URL.Path)
+	slash := strings.HasSuffix(r.URL.Path, "/") && name != "/"
+
+	for _, header := range site.Rules.MatchHeaders(name) {
+		w.Header().Add(header.Name, header.Value)
+	}
+
+	found, err := g.find(r.Context(), site, g.candidates(name, slash, routing))
+	if err != nil {
+		g.serverError(w, site, name, err)
+		return
+	}
+
+	if rule, target, ok := site.Rules.MatchRedirect(name); ok && (rule.Force || found == nil) {
+		g.applyRedirect(w, r, site, rule, target)
+		return
+	}
+
+	if routing.CleanURLs && path.Ext(name) == ".html" {
+		target := strings.TrimSuffix(name, ".html")
+		index := path.Base(target) == "index"
+		if index {
+			target = path.Dir(target)
+		}
+		addSlash := (index && routing.TrailingSlash != model.TrailingSlashRemove) ||
+			routing.TrailingSlash == model.TrailingSlashAdd
+		g.redirect(w, r, target, addSlash)
+		return
+	}
+	if slash && routing.TrailingSlash == model.TrailingSlashRemove {
+		g.redirect(w, r, name, false)
+		return
+	}
+
+	if found != nil {
+		if found.addSlash {
+			g.redirect(w, r, name, true)
+			return
+		}
+		g.serve(w, r, site, found.file, http.StatusOK)
+		return
+	}
+
+	if routing.SPAFallback && isPage(name) {
+		g.serve(w, r, site, "/"+indexFile, http.StatusOK)
+		return
+	}
+
+	g.notFound(w, r, site)
+}
+
+// applyRedirect 执行 _redirects 规则：改写时返回目标文件的内容，否则重定向
+func (g *Gateway) applyRedirect(w http.ResponseWriter, r *http.Request, site *service.Site, rule *rules.Redirect, target string) {
+	if !rule.IsRewrite() {
+		location := target
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			// 占位符的取值来自请求路径，重新转义
+			targetPath, query, _ := strings.Cut(location, "?")
+			location = (&url.URL{Path: targetPath, RawQuery: query}).String()
+		}
+		if r.URL.RawQuery != "" && !strings.Contains(location, "?") {
+			location += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, location, rule.Status)
+		return
+	}
+
+	targetPath, _, _ := strings.Cut(target, "?")
+	name := path.Clean("/" + targetPath)
+	found, err := g.find(r.Context(), site, []candidate{{file: name}, {file: path.Join(name, indexFile)}})
+	if err != nil {
+		g.serverError(w, site, name, err)
+		return
+	}
+	if found == nil {
+		g.notFound(w, r, site)
+		return
+	}
+	g.serve(w, r, site, found.file, rule.Status)
+}
+
+// notFound 返回自定义404页面，页面不存在时返回默认的404
+func (g *Gateway) notFound(w http.ResponseWriter, r *http.Request, site *service.Site) {
+	notFoundPage := site.Routing.NotFoundPage
+	if notFoundPage == "" {
+		notFoundPage = "/404.html"
+	}
+	if served, err := g.serveObject(w, r, site, path.Clean(notFoundPage), http.StatusNotFound); err == nil && served {
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// candidate 待查找的文件，addSlash 表示找到后重定向到带斜杠的地址
+type candidate struct {
+	file     string
+	addSlash bool
+}
+
+func (g *Gateway) candidates(name string, slash bool, routing model.RoutingSettings) []candidate {
+	if name == "/" {
+		return []candidate{{file: "/" + indexFile}}
+	}
+
+	var candidates []candidate
+	if !slash {
+		candidates = append(candidates, candidate{file: name})
+	}
+	if routing.CleanURLs {
+		// 强制带斜杠时，/about 先重定向到 /about/ 再返回 about.html
+		candidates = append(candidates, candidate{
+			file:     name + ".html",
+			addSlash: !slash && routing.TrailingSlash == model.TrailingSlashAdd,
+		})
+	}
+	candidates = append(candidates, candidate{
+		file:     path.Join(name, indexFile),
+		addSlash: !slash && routing.TrailingSlash != model.TrailingSlashRemove,
+	})
+	return candidates
+}
+
+// find 返回第一个存在的候选文件，都不存在时返回 nil
+func (g *Gateway) find(ctx context.Context, site *service.Site, candidates []candidate) (*candidate, error) {
+	for i := range candidates {
+		exists, err := g.exists(ctx, site, candidates[i].file)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (g *Gateway) exists(ctx context.Context, site *service.Site, name string) (bool, error) {
+	if site.Files != nil {
+		_, ok := site.Files[strings.TrimPrefix(name, "/")]
+		return ok, nil
+	}
+	_, err := g.storage.Stat(ctx, g.key(site, name))
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// serve 返回文件，文件在检查后被删除时返回404
+func (g *Gateway) serve(w http.ResponseWriter, r *http.Request, site *service.Site, name string, status int) {
+	served, err := g.serveObject(w, r, site, name, status)
+	if err != nil {
+		g.serverError(w, site, name, err)
+		return
+	}
+	if !served {
+		http.NotFound(w, r)
+	}
+}
+
+// redirect 重定向到站内地址，保留查询参数
+func (g *Gateway) redirect(w http.ResponseWriter, r *http.Request, target string, slash bool) {
+	if slash && !strings.HasSuffix(target, "/") {
+		target += "/"
+	}
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+// isPage 判断是否为页面地址：没有扩展名或扩展名为 .html
+func isPage(name string) bool {
+	ext := path.Ext(name)
+	return ext == "" || ext == ".html"
+}
+
+func (g *Gateway) serverError(w http.ResponseWriter, site *service.Site, name string, err error) {
+	logger.Logger.Errorf("网关读取文件失败 deploy=%d file=%s: %v", site.DeployID, name, err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// key 旧版部署文件在存储中的键
+func (g *Gateway) key(site *service.Site, name string) string {
+	return site.Prefix + strings.TrimPrefix(name, "/")
+}
+
+// lookup 返回文件内容在存储中的键，有文件清单时按清单中的 sha256 寻址，
+// file 为清单中的记录，旧版部署为 nil
+func (g *Gateway) lookup(site *service.Site, name string) (key string, file *model.DeployFile, ok bool) {
+	if site.Files == nil {
+		return g.key(site, name), nil, true
+	}
+	file, ok = site.Files[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return "", nil, false
+	}
+	return service.BlobKey(file.Checksum), file, true
+}
+
+// negotiate 按 Accept-Encoding 选择文件的预压缩版本，返回其在存储中的键和编码，不使用压缩版本时 encoding 为空
+func (g *Gateway) negotiate(w http.ResponseWriter, r *http.Request, file *model.DeployFile) (key, encoding string) {
+	if file == nil || (file.GzipSize == 0 && file.BrotliSize == 0) {
+		return "", ""
+	}
+	// 同一地址的响应因 Accept-Encoding 而异，不能被共享缓存复用
+	w.Header().Add("Vary", "Accept-Encoding")
+	encoding = compress.Negotiate(r.Header.Get("Accept-Encoding"), func(encoding string) bool {
+		return file.EncodedSize(encoding) > 0
+	})
+	if encoding == "" {
+		return "", ""
+	}
+	return service.EncodedBlobKey(file.Checksum, encoding), encoding
+}
+
+// statObject 获取要返回的内容的键、编码和元信息，优先使用客户端支持的预压缩版本，压缩版本缺失时退回原文件
+func (g *Gateway) statObject(w http.ResponseWriter, r *http.Request, key string, file *model.DeployFile) (string, string, *storage.ObjectInfo, error) {
+	if encodedKey, encoding := g.negotiate(w, r, file); encoding != "" {
+		info, err := g.storage.Stat(r.Context(), encodedKey)
+		if err == nil {
+			return encodedKey, encoding, info, nil
+		}
+		if !errors.Is(err, storage.ErrNotFound) {
+			return "", "", nil, err
+		}
+	}
+	info, err := g.storage.Stat(r.Context(), key)
+	return key, "", info, err
+}
+
+// serveObject 返回部署中的文件，文件不存在时返回 false。客户端支持时返回预压缩版本，
+// 状态码为 200 时支持条件请求和范围请求
+func (g *Gateway) serveObject(w http.ResponseWriter, r *http.Request, site *service.Site, name string, status int) (bool, error) {
+	key, file, ok := g.lookup(site, name)
+	if !ok {
+		return false, nil
+	}
+	key, encoding, info, err := g.statObject(w, r, key, file)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	object, err := g.storage.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer object.Close()
+
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	setCacheHeaders(w, r, site, name, file, encoding, status)
+	if file != nil && file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	} else if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	if status != http.StatusOK {
+		// 404 页面不支持条件请求和范围请求，直接返回内容
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			_, _ = io.Copy(w, object)
+		}
+		return true, nil
+	}
+
+	content, ok := object.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(object)
+		if err != nil {
+			return false, err
+		}
+		content = bytes.NewReader(data)
+	}
+	// 按内容寻址的文件在部署间共享，存储中的修改时间可能早于当前部署，回滚后会被误判为未修改。
+	// 使用部署的更新时间，激活时会刷新
+	modTime := site.ModTime
+	if modTime.IsZero() {
+		modTime = info.ModTime
+	}
+	http.ServeContent(w, r, name, modTime, content)
+	return true, nil
+}
+
+func (g *Gateway) resolve(ctx context.Context, host string) (*service.Site, error) {
+	host = service.NormalizeHost(host)
+	now := time.Now()
+
+	g.mu.RLock()
+	cached, ok := g.sites[host]
+	g.mu.RUnlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.site, cached.err
+	}
+
+	site, err := g.siteService.Resolve(ctx, host)
+	if err != nil && !errors.Is(err, service.ErrSiteNotFound) && !errors.Is(err, service.ErrSiteNotReady) {
+		// 数据库错误不缓存
+		return nil, err
+	}
+
+	if g.cacheTTL > 0 {
+		g.mu.Lock()
+		if len(g.sites) >= maxCachedSites {
+			g.sites = make(map[string]cachedSite)
+		}
+		g.sites[host] = cachedSite{site: site, err: err, expiresAt: now.Add(g.cacheTTL)}
+		g.mu.Unlock()
+	}
+	return site, err
+}
@@ -0,0 +1,557 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/rules"
+	"pubfree-platform/pubfree-server/pkg/storage"
+)
+
+// memStorage 内存中的对象存储
+type memStorage struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	modTime time.Time
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{objects: make(map[string][]byte), modTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (s *memStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = data
+	return nil
+}
+
+// readSeekCloser 与本地存储一样返回可以 Seek 的内容
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error { return nil }
+
+func (s *memStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return readSeekCloser{bytes.NewReader(data)}, nil
+}
+
+func (s *memStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return &storage.ObjectInfo{Key: key, Size: int64(len(data)), ModTime: s.modTime}, nil
+}
+
+func (s *memStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var infos []storage.ObjectInfo
+	for key, data := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, storage.ObjectInfo{Key: key, Size: int64(len(data)), ModTime: s.modTime})
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+func (s *memStorage) DeletePrefix(ctx context.Context, prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			delete(s.objects, key)
+		}
+	}
+	return nil
+}
+
+// fakeSiteService 按 Host 返回站点
+type fakeSiteService struct {
+	sites map[string]*service.Site
+}
+
+func (f *fakeSiteService) Resolve(ctx context.Context, host string) (*service.Site, error) {
+	site, ok := f.sites[host]
+	if !ok {
+		return nil, service.ErrSiteNotFound
+	}
+	return site, nil
+}
+
+// newTestSite 将 files（路径 -> 内容）按内容寻址写入 store，返回带文件清单的站点
+func newTestSite(t *testing.T, store storage.Storage, deployID uint, files map[string]string) *service.Site {
+	t.Helper()
+	site := &service.Site{
+		DeployID: deployID,
+		Files:    make(map[string]*model.DeployFile, len(files)),
+		Rules:    &rules.RuleSet{},
+		ModTime:  time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for name, content := range files {
+		sum := sha256.Sum256([]byte(content))
+		checksum := hex.EncodeToString(sum[:])
+		if err := store.Put(context.Background(), service.BlobKey(checksum), strings.NewReader(content), int64(len(content))); err != nil {
+			t.Fatal(err)
+		}
+		site.Files[name] = &model.DeployFile{
+			Path:        name,
+			Size:        int64(len(content)),
+			Checksum:    checksum,
+			ContentType: mime.TypeByExtension(path.Ext(name)),
+		}
+	}
+	return site
+}
+
+func newTestGateway(store storage.Storage, sites map[string]*service.Site) *Gateway {
+	return New(&fakeSiteService{sites: sites}, store, 0)
+}
+
+// get 向 site.test 发送请求，headers 为额外的请求头
+func get(g *Gateway, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "http://site.test"+target, nil)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	return w
+}
+
+var testFiles = map[string]string{
+	"index.html":       "home",
+	"about.html":       "about",
+	"docs/index.html":  "docs",
+	"app.js":           "console.log(1)",
+	"404.html":         "custom not found",
+	"secret.txt":       "secret",
+	"errors/gone.html": "gone",
+}
+
+func TestServeFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		routing      model.RoutingSettings
+		method       string
+		path         string
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{name: "首页", path: "/", wantStatus: http.StatusOK, wantBody: "home"},
+		{name: "静态资源", path: "/app.js", wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+		{name: "目录补斜杠", path: "/docs", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "目录补斜杠保留查询参数", path: "/docs?a=1", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/?a=1"},
+		{name: "目录首页", path: "/docs/", wantStatus: http.StatusOK, wantBody: "docs"},
+		{name: "未开启 CleanURLs", path: "/about", wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+		{name: "html 地址", path: "/about.html", wantStatus: http.StatusOK, wantBody: "about"},
+		{name: "自定义404页面", path: "/missing", wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+		{name: "HEAD 请求", method: http.MethodHead, path: "/about.html", wantStatus: http.StatusOK, wantBody: ""},
+		{name: "不支持的方法", method: http.MethodPost, path: "/", wantStatus: http.StatusMethodNotAllowed},
+
+		{name: "CleanURLs 去掉扩展名", routing: model.RoutingSettings{CleanURLs: true}, path: "/about", wantStatus: http.StatusOK, wantBody: "about"},
+		{name: "CleanURLs 重定向 html 地址", routing: model.RoutingSettings{CleanURLs: true}, path: "/about.html?a=1", wantStatus: http.StatusMovedPermanently, wantLocation: "/about?a=1"},
+		{name: "CleanURLs 重定向 index.html", routing: model.RoutingSettings{CleanURLs: true}, path: "/docs/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs/"},
+		{name: "CleanURLs 目录", routing: model.RoutingSettings{CleanURLs: true}, path: "/docs/", wantStatus: http.StatusOK, wantBody: "docs"},
+
+		{name: "强制斜杠", routing: model.RoutingSettings{CleanURLs: true, TrailingSlash: model.TrailingSlashAdd}, path: "/about", wantStatus: http.StatusMovedPermanently, wantLocation: "/about/"},
+		{name: "强制斜杠后返回页面", routing: model.RoutingSettings{CleanURLs: true, TrailingSlash: model.TrailingSlashAdd}, path: "/about/", wantStatus: http.StatusOK, wantBody: "about"},
+		{name: "强制斜杠时重定向 html 地址", routing: model.RoutingSettings{CleanURLs: true, TrailingSlash: model.TrailingSlashAdd}, path: "/about.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/about/"},
+		{name: "去掉斜杠", routing: model.RoutingSettings{TrailingSlash: model.TrailingSlashRemove}, path: "/docs/", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs"},
+		{name: "去掉斜杠后返回目录首页", routing: model.RoutingSettings{TrailingSlash: model.TrailingSlashRemove}, path: "/docs", wantStatus: http.StatusOK, wantBody: "docs"},
+		{name: "去掉斜杠时重定向 index.html", routing: model.RoutingSettings{CleanURLs: true, TrailingSlash: model.TrailingSlashRemove}, path: "/docs/index.html", wantStatus: http.StatusMovedPermanently, wantLocation: "/docs"},
+
+		{name: "SPA 回退", routing: model.RoutingSettings{SPAFallback: true}, path: "/users/1", wantStatus: http.StatusOK, wantBody: "home"},
+		{name: "SPA 回退不包括资源", routing: model.RoutingSettings{SPAFallback: true}, path: "/missing.js", wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+		{name: "SPA 回退优先返回存在的文件", routing: model.RoutingSettings{SPAFallback: true}, path: "/app.js", wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+
+		{name: "指定的404页面", routing: model.RoutingSettings{NotFoundPage: "/errors/gone.html"}, path: "/missing", wantStatus: http.StatusNotFound, wantBody: "gone"},
+		{name: "指定的404页面不存在", routing: model.RoutingSettings{NotFoundPage: "/errors/none.html"}, path: "/missing", wantStatus: http.StatusNotFound, wantBody: "404 page not found\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStorage()
+			site := newTestSite(t, store, 1, testFiles)
+			site.Routing = tt.routing
+			g := newTestGateway(store, map[string]*service.Site{"site.test": site})
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := get(g, method, tt.path, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantLocation != "" {
+				if got := w.Header().Get("Location"); got != tt.wantLocation {
+					t.Errorf("Location = %q, 期望 %q", got, tt.wantLocation)
+				}
+				return
+			}
+			if tt.wantStatus != http.StatusMethodNotAllowed && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, 期望 %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServeUnknownSite(t *testing.T) {
+	g := newTestGateway(newMemStorage(), nil)
+	if w := get(g, http.MethodGet, "/", nil); w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "站点不存在") {
+		t.Errorf("状态码 = %d, body = %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRedirectRules(t *testing.T) {
+	redirects, err := rules.ParseRedirects(strings.NewReader(strings.Join([]string{
+		"/old /about.html 301",
+		"/blog/:slug /posts/:slug 302",
+		"/api/* https://api.example.com/:splat 302",
+		"/app/* /index.html 200",
+		"/app.js /other.js 302",
+		"/secret.txt /index.html 404!",
+		"/gone /missing.html 200",
+		"/docs-old /docs 200",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, err := rules.ParseHeaders(strings.NewReader("/app.js\n  X-Test: js\n/*\n  X-Frame-Options: DENY\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newMemStorage()
+	site := newTestSite(t, store, 1, testFiles)
+	site.Rules = &rules.RuleSet{Redirects: redirects, Headers: headers}
+	g := newTestGateway(store, map[string]*service.Site{"site.test": site})
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantBody     string
+		wantLocation string
+	}{
+		{name: "重定向", path: "/old", wantStatus: http.StatusMovedPermanently, wantLocation: "/about.html"},
+		{name: "重定向保留查询参数", path: "/old?a=1", wantStatus: http.StatusMovedPermanently, wantLocation: "/about.html?a=1"},
+		{name: "占位符", path: "/blog/hello%20world", wantStatus: http.StatusFound, wantLocation: "/posts/hello%20world"},
+		{name: "外部地址", path: "/api/v1/users", wantStatus: http.StatusFound, wantLocation: "https://api.example.com/v1/users"},
+		{name: "改写", path: "/app/settings", wantStatus: http.StatusOK, wantBody: "home"},
+		{name: "文件存在时不执行未强制的规则", path: "/app.js", wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+		{name: "强制规则", path: "/secret.txt", wantStatus: http.StatusNotFound, wantBody: "home"},
+		{name: "改写目标不存在", path: "/gone", wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+		{name: "改写到目录", path: "/docs-old", wantStatus: http.StatusOK, wantBody: "docs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(g, http.MethodGet, tt.path, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantLocation != "" {
+				if got := w.Header().Get("Location"); got != tt.wantLocation {
+					t.Errorf("Location = %q, 期望 %q", got, tt.wantLocation)
+				}
+				return
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, 期望 %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	w := get(g, http.MethodGet, "/app.js", nil)
+	if w.Header().Get("X-Test") != "js" || w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("_headers 未生效: %v", w.Header())
+	}
+	if w := get(g, http.MethodGet, "/missing", nil); w.Header().Get("X-Frame-Options") != "DENY" {
+		t.Error("404 页面也应添加 _headers 中的响应头")
+	}
+}
+
+// newGraySite 返回生产站点，灰度站点的首页内容为 gray
+func newGraySite(t *testing.T, store storage.Storage, rule *model.GrayRule) *service.Site {
+	t.Helper()
+	prod := newTestSite(t, store, 1, map[string]string{"index.html": "prod"})
+	gray := newTestSite(t, store, 2, map[string]string{"index.html": "gray"})
+	prod.Gray = &service.GraySite{Rule: rule, Site: gray}
+	return prod
+}
+
+func bucketCookie(bucket string) map[string]string {
+	return map[string]string{"Cookie": grayBucketCookie + "=" + bucket}
+}
+
+func TestPickSite(t *testing.T) {
+	rule := &model.GrayRule{
+		Percentage:   30,
+		ForceHeader:  "X-Gray",
+		ForceCookie:  "force_gray",
+		UserIDCookie: "uid",
+		AllowUserIDs: []string{"42"},
+	}
+	tests := []struct {
+		name       string
+		percentage int
+		headers    map[string]string
+		want       string
+	}{
+		{name: "分桶小于比例", headers: bucketCookie("0"), want: "gray"},
+		{name: "分桶位于边界内", headers: bucketCookie("29"), want: "gray"},
+		{name: "分桶等于比例", headers: bucketCookie("30"), want: "prod"},
+		{name: "分桶大于比例", headers: bucketCookie("99"), want: "prod"},
+		{name: "0% 全部留在生产", percentage: -1, headers: bucketCookie("0"), want: "prod"},
+		{name: "100% 全部进入灰度", percentage: 100, headers: bucketCookie("99"), want: "gray"},
+		{name: "请求头强制灰度", headers: map[string]string{"X-Gray": "true", "Cookie": grayBucketCookie + "=99"}, want: "gray"},
+		{name: "请求头强制生产", headers: map[string]string{"X-Gray": "prod", "Cookie": grayBucketCookie + "=0"}, want: "prod"},
+		{name: "无法识别的请求头按分桶", headers: map[string]string{"X-Gray": "maybe", "Cookie": grayBucketCookie + "=0"}, want: "gray"},
+		{name: "cookie 强制灰度", headers: map[string]string{"Cookie": "force_gray=1; " + grayBucketCookie + "=99"}, want: "gray"},
+		{name: "请求头优先于 cookie", headers: map[string]string{"X-Gray": "0", "Cookie": "force_gray=1"}, want: "prod"},
+		{name: "白名单用户", headers: map[string]string{"Cookie": "uid=42; " + grayBucketCookie + "=99"}, want: "gray"},
+		{name: "非白名单用户", headers: map[string]string{"Cookie": "uid=7; " + grayBucketCookie + "=99"}, want: "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := *rule
+			switch {
+			case tt.percentage < 0:
+				r.Percentage = 0
+			case tt.percentage > 0:
+				r.Percentage = tt.percentage
+			}
+			store := newMemStorage()
+			g := newTestGateway(store, map[string]*service.Site{"site.test": newGraySite(t, store, &r)})
+
+			w := get(g, http.MethodGet, "/", tt.headers)
+			if w.Body.String() != tt.want {
+				t.Errorf("返回 %q, 期望 %q", w.Body.String(), tt.want)
+			}
+			if !slices.Contains(w.Header().Values("Vary"), "Cookie") {
+				t.Errorf("Vary = %v, 期望包含 Cookie", w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestPickSiteSticky(t *testing.T) {
+	store := newMemStorage()
+	g := newTestGateway(store, map[string]*service.Site{
+		"site.test": newGraySite(t, store, &model.GrayRule{Percentage: 50}),
+	})
+
+	for i := 0; i < 20; i++ {
+		w := get(g, http.MethodGet, "/", nil)
+		var bucket *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == grayBucketCookie {
+				bucket = c
+			}
+		}
+		if bucket == nil {
+			t.Fatal("首次访问应设置分桶 cookie")
+		}
+		n, err := strconv.Atoi(bucket.Value)
+		if err != nil || n < 0 || n >= 100 {
+			t.Fatalf("分桶 = %q", bucket.Value)
+		}
+		first := w.Body.String()
+		if want := map[bool]string{true: "gray", false: "prod"}[n < 50]; first != want {
+			t.Fatalf("分桶 %d 返回 %q, 期望 %q", n, first, want)
+		}
+
+		// 带上分桶 cookie 后始终访问同一个站点，且不再重新分桶
+		for j := 0; j < 5; j++ {
+			w := get(g, http.MethodGet, "/", bucketCookie(bucket.Value))
+			if w.Body.String() != first {
+				t.Fatalf("分桶 %d 第 %d 次访问返回 %q, 期望 %q", n, j, w.Body.String(), first)
+			}
+			if w.Header().Get("Set-Cookie") != "" {
+				t.Fatalf("已有分桶时不应重新设置 cookie: %q", w.Header().Get("Set-Cookie"))
+			}
+		}
+	}
+}
+
+func TestPickSiteEdges(t *testing.T) {
+	for _, tt := range []struct {
+		percentage int
+		want       string
+		setCookie  bool
+	}{
+		{percentage: 0, want: "prod", setCookie: false},
+		{percentage: 100, want: "gray", setCookie: true},
+	} {
+		store := newMemStorage()
+		g := newTestGateway(store, map[string]*service.Site{
+			"site.test": newGraySite(t, store, &model.GrayRule{Percentage: tt.percentage}),
+		})
+		for i := 0; i < 50; i++ {
+			w := get(g, http.MethodGet, "/", nil)
+			if w.Body.String() != tt.want {
+				t.Fatalf("%d%% 返回 %q, 期望 %q", tt.percentage, w.Body.String(), tt.want)
+			}
+			if got := w.Header().Get("Set-Cookie") != ""; got != tt.setCookie {
+				t.Fatalf("%d%% 设置 cookie = %v, 期望 %v", tt.percentage, got, tt.setCookie)
+			}
+		}
+	}
+}
+
+func TestServeObjectConditional(t *testing.T) {
+	store := newMemStorage()
+	site := newTestSite(t, store, 1, testFiles)
+	g := newTestGateway(store, map[string]*service.Site{"site.test": site})
+
+	etag := `"` + site.Files["app.js"].Checksum + `"`
+	lastModified := site.ModTime.UTC().Format(http.TimeFormat)
+	earlier := site.ModTime.Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		headers          map[string]string
+		wantStatus       int
+		wantBody         string
+		wantContentRange string
+	}{
+		{name: "无条件请求", path: "/app.js", wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+		{name: "ETag 匹配", path: "/app.js", headers: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "弱 ETag 匹配", path: "/app.js", headers: map[string]string{"If-None-Match": `"other", W/` + etag}, wantStatus: http.StatusNotModified},
+		{name: "ETag 不匹配", path: "/app.js", headers: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+		{name: "HEAD 请求 ETag 匹配", method: http.MethodHead, path: "/app.js", headers: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "未修改", path: "/app.js", headers: map[string]string{"If-Modified-Since": lastModified}, wantStatus: http.StatusNotModified},
+		{name: "已修改", path: "/app.js", headers: map[string]string{"If-Modified-Since": earlier}, wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+		{name: "If-None-Match 优先于 If-Modified-Since", path: "/app.js", headers: map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+
+		{name: "范围请求", path: "/app.js", headers: map[string]string{"Range": "bytes=0-6"}, wantStatus: http.StatusPartialContent, wantBody: "console", wantContentRange: "bytes 0-6/14"},
+		{name: "开放范围", path: "/app.js", headers: map[string]string{"Range": "bytes=8-"}, wantStatus: http.StatusPartialContent, wantBody: "log(1)", wantContentRange: "bytes 8-13/14"},
+		{name: "末尾范围", path: "/app.js", headers: map[string]string{"Range": "bytes=-3"}, wantStatus: http.StatusPartialContent, wantBody: "(1)", wantContentRange: "bytes 11-13/14"},
+		{name: "超出范围", path: "/app.js", headers: map[string]string{"Range": "bytes=100-"}, wantStatus: http.StatusRequestedRangeNotSatisfiable, wantContentRange: "bytes */14"},
+		{name: "If-Range 匹配", path: "/app.js", headers: map[string]string{"Range": "bytes=0-6", "If-Range": etag}, wantStatus: http.StatusPartialContent, wantBody: "console", wantContentRange: "bytes 0-6/14"},
+		{name: "If-Range 不匹配时返回全部内容", path: "/app.js", headers: map[string]string{"Range": "bytes=0-6", "If-Range": `"other"`}, wantStatus: http.StatusOK, wantBody: "console.log(1)"},
+
+		{name: "404 页面不支持条件请求", path: "/missing", headers: map[string]string{"If-None-Match": `"` + site.Files["404.html"].Checksum + `"`, "If-Modified-Since": lastModified}, wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+		{name: "404 页面不支持范围请求", path: "/missing", headers: map[string]string{"Range": "bytes=0-5"}, wantStatus: http.StatusNotFound, wantBody: "custom not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			w := get(g, method, tt.path, tt.headers)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("状态码 = %d, 期望 %d (body %q)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusRequestedRangeNotSatisfiable && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, 期望 %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Content-Range"); got != tt.wantContentRange {
+				t.Errorf("Content-Range = %q, 期望 %q", got, tt.wantContentRange)
+			}
+			if tt.path == "/app.js" && tt.wantStatus != http.StatusRequestedRangeNotSatisfiable {
+				if got := w.Header().Get("ETag"); got != etag {
+					t.Errorf("ETag = %q, 期望 %q", got, etag)
+				}
+				// 有 ETag 时 304 响应不带 Last-Modified
+				if got := w.Header().Get("Last-Modified"); tt.wantStatus != http.StatusNotModified && got != lastModified {
+					t.Errorf("Last-Modified = %q, 期望 %q", got, lastModified)
+				}
+			}
+		})
+	}
+}
+
+func TestServeObjectEncoded(t *testing.T) {
+	store := newMemStorage()
+	site := newTestSite(t, store, 1, testFiles)
+	file := site.Files["app.js"]
+	file.GzipSize = 2
+	if err := store.Put(context.Background(), service.EncodedBlobKey(file.Checksum, "gzip"), strings.NewReader("gz"), 2); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGateway(store, map[string]*service.Site{"site.test": site})
+
+	w := get(g, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Code != http.StatusOK || w.Body.String() != "gz" || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("状态码 = %d, body = %q, Content-Encoding = %q", w.Code, w.Body.String(), w.Header().Get("Content-Encoding"))
+	}
+	encodedTag := `"` + file.Checksum + `-gzip"`
+	if got := w.Header().Get("ETag"); got != encodedTag {
+		t.Errorf("ETag = %q, 期望 %q", got, encodedTag)
+	}
+
+	// 压缩版本与原文件的 ETag 不同，缓存的原文件不能用于确认压缩版本
+	w = get(g, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": `"` + file.Checksum + `"`})
+	if w.Code != http.StatusOK {
+		t.Errorf("使用原文件的 ETag 确认压缩版本, 状态码 = %d, 期望 200", w.Code)
+	}
+	w = get(g, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": encodedTag})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("使用压缩版本的 ETag, 状态码 = %d, 期望 304", w.Code)
+	}
+
+	// 不支持压缩的客户端得到原文件
+	w = get(g, http.MethodGet, "/app.js", nil)
+	if w.Body.String() != "console.log(1)" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("body = %q, Content-Encoding = %q", w.Body.String(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestServeObjectLegacy(t *testing.T) {
+	store := newMemStorage()
+	site := &service.Site{DeployID: 1, Prefix: service.DeployPrefix(1, 1), ModTime: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if err := store.Put(context.Background(), site.Prefix+"app.js", strings.NewReader("legacy"), 6); err != nil {
+		t.Fatal(err)
+	}
+	g := newTestGateway(store, map[string]*service.Site{"site.test": site})
+
+	// 旧版部署没有文件清单，只能按修改时间确认
+	w := get(g, http.MethodGet, "/app.js", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "legacy" || w.Header().Get("ETag") != "" {
+		t.Fatalf("状态码 = %d, body = %q, ETag = %q", w.Code, w.Body.String(), w.Header().Get("ETag"))
+	}
+	w = get(g, http.MethodGet, "/app.js", map[string]string{"If-Modified-Since": site.ModTime.Format(http.TimeFormat)})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("状态码 = %d, 期望 304", w.Code)
+	}
+	w = get(g, http.MethodGet, "/app.js", map[string]string{"Range": "bytes=1-3"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "ega" {
+		t.Errorf("状态码 = %d, body = %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiTokenHandler struct {
+	apiTokenService service.ApiTokenService
+}
+
+func NewApiTokenHandler(apiTokenService service.ApiTokenService) *ApiTokenHandler {
+	return &ApiTokenHandler{apiTokenService: apiTokenService}
+}
+
+// 个人访问令牌
+func (h *ApiTokenHandler) CreateUserToken(c *gin.Context) {
+	var req request.CreateApiTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := h.apiTokenService.CreateUserToken(c.Request.Context(), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, token)
+}
+
+func (h *ApiTokenHandler) ListUserTokens(c *gin.Context) {
+	tokens, err := h.apiTokenService.ListUserTokens(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, tokens)
+}
+
+func (h *ApiTokenHandler) RevokeUserToken(c *gin.Context) {
+	tokenID, err := strconv.ParseUint(c.Param("tokenId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的令牌ID")
+		return
+	}
+
+	if err := h.apiTokenService.RevokeUserToken(c.Request.Context(), middleware.GetUserID(c), uint(tokenID)); err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// 项目部署令牌
+func (h *ApiTokenHandler) CreateDeployToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	var req request.CreateDeployTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	token, err := h.apiTokenService.CreateDeployToken(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, token)
+}
+
+func (h *ApiTokenHandler) ListDeployTokens(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	tokens, err := h.apiTokenService.ListDeployTokens(c.Request.Context(), uint(id))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, tokens)
+}
+
+func (h *ApiTokenHandler) RevokeDeployToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	tokenID, err := strconv.ParseUint(c.Param("tokenId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的令牌ID")
+		return
+	}
+
+	if err := h.apiTokenService.RevokeDeployToken(c.Request.Context(), uint(id), uint(tokenID)); err != nil {
+		h.errorResponse(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+func (h *ApiTokenHandler) errorResponse(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrApiTokenNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,541 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// logKeepAliveInterval 没有新日志时发送注释行的间隔，避免连接被代理断开
+const logKeepAliveInterval = 15 * time.Second
+
+// logPollInterval 跟踪日志时查询新日志的间隔，测试中会调小
+var logPollInterval = time.Second
+
+type DeployHandler struct {
+	deployService service.DeployService
+	maxUploadSize int64
+}
+
+func NewDeployHandler(deployService service.DeployService, maxUploadSize int64) *DeployHandler {
+	return &DeployHandler{
+		deployService: deployService,
+		maxUploadSize: maxUploadSize,
+	}
+}
+
+// CreateDeploy 通过远程地址创建部署
+func (h *DeployHandler) CreateDeploy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	var req request.CreateProjectDeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !middleware.CanAccessEnv(c, req.ProjectEnvID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "部署令牌无权访问该环境")
+		return
+	}
+
+	deploy, err := h.deployService.CreateDeploy(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// UploadArtifact 上传压缩包创建部署
+func (h *DeployHandler) UploadArtifact(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	// 为 multipart 的其他字段预留 1MB
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize+1<<20)
+
+	var req request.UploadDeployRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.bindErrorResponse(c, err)
+		return
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		h.bindErrorResponse(c, err)
+		return
+	}
+	defer file.Close()
+	req.Filename = header.Filename
+
+	deploy, err := h.deployService.UploadArtifact(c.Request.Context(), uint(id), uint(envID), middleware.GetUserID(c), &req, file)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// CreateBuild 从代码仓库构建部署
+func (h *DeployHandler) CreateBuild(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	var req request.CreateBuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deploy, err := h.deployService.CreateBuild(c.Request.Context(), uint(id), uint(envID), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// CreateManifestDeploy 增量上传：提交文件清单创建部署，返回需要上传的文件内容
+func (h *DeployHandler) CreateManifestDeploy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	var req request.CreateManifestDeployRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.deployService.CreateManifestDeploy(c.Request.Context(), uint(id), uint(envID), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// GetMissingBlobs 获取增量上传的部署仍需上传的文件内容
+func (h *DeployHandler) GetMissingBlobs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	result, err := h.deployService.GetMissingBlobs(c.Request.Context(), uint(id), uint(deployID))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// UploadBlob 上传一份文件内容，请求体为文件的原始内容，路径中的 sha256 须与内容一致
+func (h *DeployHandler) UploadBlob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	if err := h.deployService.UploadBlob(c.Request.Context(), uint(id), uint(deployID), c.Param("checksum"), c.Request.Body); err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// FinalizeManifestDeploy 文件内容全部上传后提交增量上传的部署
+func (h *DeployHandler) FinalizeManifestDeploy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	deploy, err := h.deployService.FinalizeManifestDeploy(c.Request.Context(), uint(id), uint(deployID))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// GetDeploy 获取部署详情及状态变更记录
+func (h *DeployHandler) GetDeploy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	deploy, ok := h.accessibleDeploy(c, uint(id), uint(deployID))
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// ListDeployFiles 获取部署的文件清单
+func (h *DeployHandler) ListDeployFiles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	files, err := h.deployService.ListFiles(c.Request.Context(), uint(id), uint(deployID))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, files)
+}
+
+// DiffDeploys 比较两个部署的文件，列出从 deployId 切换到 targetId 时的变化
+func (h *DeployHandler) DiffDeploys(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	targetID, err := strconv.ParseUint(c.Param("targetId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) || !h.canAccessDeploy(c, uint(id), uint(targetID)) {
+		return
+	}
+
+	diff, err := h.deployService.DiffDeploys(c.Request.Context(), uint(id), uint(deployID), uint(targetID))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, diff)
+}
+
+// ActivateDeploy 激活部署
+func (h *DeployHandler) ActivateDeploy(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	// 部署令牌只能激活其绑定环境中的部署
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	deploy, err := h.deployService.ActivateDeploy(c.Request.Context(), uint(id), uint(deployID), middleware.GetUserID(c))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// RollbackEnv 回滚环境到上一个或指定的部署
+func (h *DeployHandler) RollbackEnv(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	// 请求体可以为空
+	var req request.RollbackEnvRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deploy, err := h.deployService.RollbackEnv(c.Request.Context(), uint(id), uint(envID), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+// ListActivations 环境的激活历史
+func (h *DeployHandler) ListActivations(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	activations, err := h.deployService.ListActivations(c.Request.Context(), uint(id), uint(envID))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, activations)
+}
+
+// GetDeployLogs 获取部署日志，follow=1 时通过 SSE 推送新日志，直到部署处理结束。
+// 断线重连时根据 Last-Event-ID 或 after 参数从上次的位置继续
+func (h *DeployHandler) GetDeployLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	deployID, err := strconv.ParseUint(c.Param("deployId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的部署ID")
+		return
+	}
+
+	after := c.GetHeader("Last-Event-ID")
+	if after == "" {
+		after = c.DefaultQuery("after", "0")
+	}
+	afterID, err := strconv.ParseUint(after, 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的日志ID")
+		return
+	}
+
+	if !h.canAccessDeploy(c, uint(id), uint(deployID)) {
+		return
+	}
+
+	if c.Query("follow") != "1" {
+		logs, _, err := h.deployService.ListLogs(c.Request.Context(), uint(id), uint(deployID), uint(afterID))
+		if err != nil {
+			utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+			return
+		}
+		utils.SuccessResponse(c, logs)
+		return
+	}
+
+	h.streamLogs(c, uint(id), uint(deployID), uint(afterID))
+}
+
+// streamLogs 以 SSE 推送日志：log 事件为一条日志，事件ID为日志ID；
+// 部署处理结束后发送 end 事件，内容为部署详情，然后关闭连接
+func (h *DeployHandler) streamLogs(c *gin.Context, projectID, deployID, afterID uint) {
+	// 日志流持续时间不确定，取消服务器的写超时
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	lastWrite := time.Now()
+	for {
+		logs, finished, err := h.deployService.ListLogs(ctx, projectID, deployID, afterID)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.SSEvent("error", err.Error())
+			}
+			return
+		}
+
+		for _, log := range logs {
+			c.Render(-1, sse.Event{
+				Id:    strconv.FormatUint(uint64(log.ID), 10),
+				Event: "log",
+				Data:  log,
+			})
+			afterID = log.ID
+		}
+
+		if finished {
+			deploy, err := h.deployService.GetDeploy(ctx, projectID, deployID)
+			if err != nil {
+				c.SSEvent("error", err.Error())
+				return
+			}
+			c.SSEvent("end", deploy)
+			return
+		}
+
+		if len(logs) > 0 {
+			lastWrite = time.Now()
+		} else if time.Since(lastWrite) >= logKeepAliveInterval {
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			lastWrite = time.Now()
+		}
+		c.Writer.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// canAccessDeploy 部署令牌只能访问其绑定环境中的部署，无权访问时写入错误响应
+func (h *DeployHandler) canAccessDeploy(c *gin.Context, projectID, deployID uint) bool {
+	_, ok := h.accessibleDeploy(c, projectID, deployID)
+	return ok
+}
+
+// accessibleDeploy 获取当前请求有权访问的部署，部署不存在或无权访问时写入错误响应
+func (h *DeployHandler) accessibleDeploy(c *gin.Context, projectID, deployID uint) (*response.ProjectDeployResponse, bool) {
+	deploy, err := h.deployService.GetDeploy(c.Request.Context(), projectID, deployID)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return nil, false
+	}
+	if !middleware.CanAccessEnv(c, deploy.ProjectEnvID) {
+		utils.ErrorResponse(c, http.StatusForbidden, "部署令牌无权访问该环境")
+		return nil, false
+	}
+	return deploy, true
+}
+
+func (h *DeployHandler) bindErrorResponse(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, service.ErrArtifactTooLarge.Error())
+		return
+	}
+	if errors.Is(err, http.ErrMissingFile) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "请上传部署产物压缩包（file 字段）")
+		return
+	}
+	utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+}
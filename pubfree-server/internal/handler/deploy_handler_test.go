@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeDeployService 保存部署 1 的日志，finished 为 true 时部署已处理结束
+type fakeDeployService struct {
+	service.DeployService
+
+	mu       sync.Mutex
+	logs     []*response.DeployLogResponse
+	finished bool
+	listErr  error
+	polled   chan struct{} // 每次 ListLogs 后发送一次，可以为空
+}
+
+func (s *fakeDeployService) GetDeploy(ctx context.Context, projectID, deployID uint) (*response.ProjectDeployResponse, error) {
+	if projectID != 1 || deployID != 1 {
+		return nil, service.ErrDeployNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := model.DeployStatusBuilding
+	if s.finished {
+		status = model.DeployStatusReady
+	}
+	return &response.ProjectDeployResponse{ID: 1, ProjectID: 1, ProjectEnvID: 1, Status: status}, nil
+}
+
+func (s *fakeDeployService) ListLogs(ctx context.Context, projectID, deployID, afterID uint) ([]*response.DeployLogResponse, bool, error) {
+	s.mu.Lock()
+	defer func() {
+		s.mu.Unlock()
+		if s.polled != nil {
+			select {
+			case s.polled <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	if s.listErr != nil {
+		return nil, false, s.listErr
+	}
+	var logs []*response.DeployLogResponse
+	for _, log := range s.logs {
+		if log.ID > afterID {
+			logs = append(logs, log)
+		}
+	}
+	return logs, s.finished, nil
+}
+
+func (s *fakeDeployService) append(message string, finished bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, &response.DeployLogResponse{ID: uint(len(s.logs) + 1), Level: "info", Message: message})
+	s.finished = finished
+}
+
+// sseEvent 解析后的一个 SSE 事件，注释行记为 Event ":"
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// readEvent 读取下一个以空行结束的事件
+func readEvent(r *bufio.Reader) (*sseEvent, error) {
+	ev := &sseEvent{}
+	lines := 0
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if lines == 0 {
+				continue
+			}
+			return ev, nil
+		}
+		lines++
+		field, value, _ := strings.Cut(line, ":")
+		switch field {
+		case "":
+			ev.Event = ":"
+		case "id":
+			ev.ID = value
+		case "event":
+			ev.Event = value
+		case "data":
+			ev.Data = value
+		}
+	}
+}
+
+// startLogStream 启动服务并以跟踪模式请求部署 1 的日志，done 在处理函数返回后关闭
+func startLogStream(t *testing.T, ctx context.Context, svc *fakeDeployService, query string, header http.Header) (*http.Response, <-chan struct{}) {
+	t.Helper()
+	old := logPollInterval
+	logPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { logPollInterval = old })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	h := NewDeployHandler(svc, 0)
+	done := make(chan struct{})
+	r.GET("/projects/:id/deploys/:deployId/logs", func(c *gin.Context) {
+		defer close(done)
+		h.GetDeployLogs(c)
+	})
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/projects/1/deploys/1/logs?follow=1"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp, done
+}
+
+func waitDone(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("日志流未结束")
+	}
+}
+
+func TestStreamLogsCatchUp(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		header http.Header
+		want   []string
+	}{
+		{name: "全部日志", want: []string{"1", "2", "3"}},
+		{name: "after 参数", query: "&after=1", want: []string{"2", "3"}},
+		{name: "Last-Event-ID 优先", query: "&after=0", header: http.Header{"Last-Event-Id": {"2"}}, want: []string{"3"}},
+		{name: "没有新日志", query: "&after=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &fakeDeployService{}
+			svc.append("clone", false)
+			svc.append("build", false)
+			svc.append("done", true)
+
+			resp, done := startLogStream(t, context.Background(), svc, tt.query, tt.header)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("状态码 = %d", resp.StatusCode)
+			}
+			if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if resp.Header.Get("Cache-Control") != "no-cache" {
+				t.Errorf("Cache-Control = %q", resp.Header.Get("Cache-Control"))
+			}
+
+			body := bufio.NewReader(resp.Body)
+			for _, id := range tt.want {
+				ev, err := readEvent(body)
+				if err != nil {
+					t.Fatalf("读取日志 %s: %v", id, err)
+				}
+				if ev.Event != "log" || ev.ID != id {
+					t.Fatalf("事件 = %+v, 期望日志 %s", ev, id)
+				}
+				var log response.DeployLogResponse
+				if err := json.Unmarshal([]byte(ev.Data), &log); err != nil {
+					t.Fatalf("日志内容 %q: %v", ev.Data, err)
+				}
+				if want := svc.logs[log.ID-1].Message; log.Message != want {
+					t.Errorf("日志 %s 内容 = %q, 期望 %q", id, log.Message, want)
+				}
+			}
+
+			ev, err := readEvent(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var deploy response.ProjectDeployResponse
+			if ev.Event != "end" || json.Unmarshal([]byte(ev.Data), &deploy) != nil || deploy.Status != model.DeployStatusReady {
+				t.Fatalf("结束事件 = %+v", ev)
+			}
+			if _, err := readEvent(body); err == nil {
+				t.Error("end 事件后连接应关闭")
+			}
+			waitDone(t, done)
+		})
+	}
+}
+
+func TestStreamLogsFollow(t *testing.T) {
+	svc := &fakeDeployService{polled: make(chan struct{}, 1)}
+	svc.append("clone", false)
+
+	resp, done := startLogStream(t, context.Background(), svc, "", nil)
+	body := bufio.NewReader(resp.Body)
+	if ev, err := readEvent(body); err != nil || ev.Event != "log" || ev.ID != "1" {
+		t.Fatalf("事件 = %+v, %v", ev, err)
+	}
+
+	// 处理过程中产生的日志在下次查询时推送，部署结束后发送 end 事件
+	<-svc.polled
+	svc.append("build", false)
+	if ev, err := readEvent(body); err != nil || ev.Event != "log" || ev.ID != "2" {
+		t.Fatalf("事件 = %+v, %v", ev, err)
+	}
+	svc.append("done", true)
+	if ev, err := readEvent(body); err != nil || ev.Event != "log" || ev.ID != "3" {
+		t.Fatalf("事件 = %+v, %v", ev, err)
+	}
+	if ev, err := readEvent(body); err != nil || ev.Event != "end" {
+		t.Fatalf("事件 = %+v, %v", ev, err)
+	}
+	waitDone(t, done)
+}
+
+func TestStreamLogsClientDisconnect(t *testing.T) {
+	svc := &fakeDeployService{polled: make(chan struct{}, 1)}
+	svc.append("clone", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	resp, done := startLogStream(t, ctx, svc, "", nil)
+	if ev, err := readEvent(bufio.NewReader(resp.Body)); err != nil || ev.Event != "log" {
+		t.Fatalf("事件 = %+v, %v", ev, err)
+	}
+
+	// 部署仍在处理中，客户端断开后处理函数应停止查询并返回
+	<-svc.polled
+	cancel()
+	waitDone(t, done)
+}
+
+func TestStreamLogsError(t *testing.T) {
+	svc := &fakeDeployService{listErr: errors.New("数据库不可用")}
+	resp, done := startLogStream(t, context.Background(), svc, "", nil)
+	ev, err := readEvent(bufio.NewReader(resp.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Event != "error" || !strings.Contains(ev.Data, "数据库不可用") {
+		t.Errorf("事件 = %+v", ev)
+	}
+	waitDone(t, done)
+}
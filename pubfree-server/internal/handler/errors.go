@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"pubfree-platform/pubfree-server/internal/service"
+)
+
+// errorStatus 将业务错误映射为HTTP状态码，无法识别时返回 fallback
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrProjectNotFound), errors.Is(err, service.ErrGroupNotFound),
+		errors.Is(err, service.ErrEnvNotFound), errors.Is(err, service.ErrDeployNotFound),
+		errors.Is(err, service.ErrGrayRuleNotFound), errors.Is(err, service.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrUploadLocked):
+		return http.StatusLocked
+	case errors.Is(err, service.ErrDeployNotReady), errors.Is(err, service.ErrNoRollbackTarget),
+		errors.Is(err, service.ErrDomainTaken), errors.Is(err, service.ErrGrayRuleConflict),
+		errors.Is(err, service.ErrNoGrayDeploy), errors.Is(err, service.ErrNoManifest),
+		errors.Is(err, service.ErrNotUploading), errors.Is(err, service.ErrBlobsMissing),
+		errors.Is(err, service.ErrUploadOffset), errors.Is(err, service.ErrUploadIncomplete):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidArtifact), errors.Is(err, service.ErrInvalidGrayRule),
+		errors.Is(err, service.ErrBuildDisabled), errors.Is(err, service.ErrBuildNotSet),
+		errors.Is(err, service.ErrInvalidCacheRule):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrArtifactTooLarge), errors.Is(err, service.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrDeployBusy):
+		return http.StatusServiceUnavailable
+	default:
+		return fallback
+	}
+}
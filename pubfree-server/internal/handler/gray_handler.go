@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"net/http"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GrayHandler struct {
+	grayService service.GrayService
+}
+
+func NewGrayHandler(grayService service.GrayService) *GrayHandler {
+	return &GrayHandler{grayService: grayService}
+}
+
+// GetGrayRule 获取灰度环境的分流规则
+func (h *GrayHandler) GetGrayRule(c *gin.Context) {
+	id, envID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	rule, err := h.grayService.GetRule(c.Request.Context(), id, envID)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, rule)
+}
+
+// SaveGrayRule 配置灰度环境的分流规则
+func (h *GrayHandler) SaveGrayRule(c *gin.Context) {
+	id, envID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	var req request.SaveGrayRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := h.grayService.SaveRule(c.Request.Context(), id, envID, middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, rule)
+}
+
+// UpdateGrayPercentage 调整灰度流量比例
+func (h *GrayHandler) UpdateGrayPercentage(c *gin.Context) {
+	id, envID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	var req request.UpdateGrayPercentageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rule, err := h.grayService.UpdatePercentage(c.Request.Context(), id, envID, middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, rule)
+}
+
+// PromoteGray 将灰度部署发布到生产环境
+func (h *GrayHandler) PromoteGray(c *gin.Context) {
+	id, envID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	deploy, err := h.grayService.Promote(c.Request.Context(), id, envID, middleware.GetUserID(c))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, deploy)
+}
+
+func (h *GrayHandler) parseIDs(c *gin.Context) (uint, uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return 0, 0, false
+	}
+
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return 0, 0, false
+	}
+
+	return uint(id), uint(envID), true
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/middleware"
 	"pubfree-platform/pubfree-server/internal/service"
 	"pubfree-platform/pubfree-server/pkg/utils"
 	"strconv"
@@ -25,8 +26,7 @@ func (h *GroupHandler) CreateGroup(c *gin.Context) {
 		return
 	}
 
-	// 从token中获取用户ID，这里简化处理
-	userID := uint(1) // 应该从JWT token中获取
+	userID := middleware.GetUserID(c)
 
 	group, err := h.groupService.CreateGroup(c.Request.Context(), userID, &req)
 	if err != nil {
@@ -132,9 +132,9 @@ func (h *GroupHandler) AddGroupMember(c *gin.Context) {
 		return
 	}
 
-	member, err := h.groupService.AddGroupMember(c.Request.Context(), uint(id), &req)
+	member, err := h.groupService.AddGroupMember(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -154,8 +154,8 @@ func (h *GroupHandler) RemoveGroupMember(c *gin.Context) {
 		return
 	}
 
-	if err := h.groupService.RemoveGroupMember(c.Request.Context(), uint(id), uint(userID)); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if err := h.groupService.RemoveGroupMember(c.Request.Context(), uint(id), middleware.GetUserID(c), uint(userID)); err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetaHandler struct{}
+
+func NewMetaHandler() *MetaHandler {
+	return &MetaHandler{}
+}
+
+// GetEnums 返回前后端共享的枚举定义
+func (h *MetaHandler) GetEnums(c *gin.Context) {
+	resp := response.EnumsResponse{}
+	for _, role := range model.Roles {
+		resp.Role = append(resp.Role, enumOption(int(role), role.Meta()))
+	}
+	for _, envType := range model.EnvTypes {
+		resp.EnvType = append(resp.EnvType, enumOption(int(envType), envType.Meta()))
+	}
+	for _, targetType := range model.TargetTypes {
+		resp.TargetType = append(resp.TargetType, enumOption(int(targetType), targetType.Meta()))
+	}
+
+	utils.SuccessResponse(c, resp)
+}
+
+func enumOption(value int, meta model.EnumMeta) response.EnumOption {
+	return response.EnumOption{
+		Value:       value,
+		Name:        meta.Name,
+		Label:       meta.Label,
+		Description: meta.Description,
+	}
+}
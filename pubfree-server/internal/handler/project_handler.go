@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/middleware"
 	"pubfree-platform/pubfree-server/internal/service"
 	"pubfree-platform/pubfree-server/pkg/utils"
 	"strconv"
@@ -25,12 +26,11 @@ func (h *ProjectHandler) CreateProject(c *gin.Context) {
 		return
 	}
 
-	// 从token中获取用户ID，这里简化处理
-	userID := uint(1) // 应该从JWT token中获取
+	userID := middleware.GetUserID(c)
 
 	project, err := h.projectService.CreateProject(c.Request.Context(), userID, &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -66,9 +66,9 @@ func (h *ProjectHandler) UpdateProject(c *gin.Context) {
 		return
 	}
 
-	project, err := h.projectService.UpdateProject(c.Request.Context(), uint(id), &req)
+	project, err := h.projectService.UpdateProject(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -135,9 +135,9 @@ func (h *ProjectHandler) AddProjectMember(c *gin.Context) {
 		return
 	}
 
-	member, err := h.projectService.AddProjectMember(c.Request.Context(), uint(id), &req)
+	member, err := h.projectService.AddProjectMember(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
@@ -157,14 +157,36 @@ func (h *ProjectHandler) RemoveProjectMember(c *gin.Context) {
 		return
 	}
 
-	if err := h.projectService.RemoveProjectMember(c.Request.Context(), uint(id), uint(userID)); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	if err := h.projectService.RemoveProjectMember(c.Request.Context(), uint(id), middleware.GetUserID(c), uint(userID)); err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
 	utils.SuccessResponse(c, nil)
 }
 
+func (h *ProjectHandler) TransferProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	var req request.TransferProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	project, err := h.projectService.TransferProject(c.Request.Context(), uint(id), middleware.GetUserID(c), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, project)
+}
+
 // 项目环境相关
 func (h *ProjectHandler) CreateProjectEnv(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -179,8 +201,7 @@ func (h *ProjectHandler) CreateProjectEnv(c *gin.Context) {
 		return
 	}
 
-	// 从token中获取用户ID
-	userID := uint(1)
+	userID := middleware.GetUserID(c)
 
 	env, err := h.projectService.CreateProjectEnv(c.Request.Context(), uint(id), userID, &req)
 	if err != nil {
@@ -207,71 +228,127 @@ func (h *ProjectHandler) GetProjectEnvs(c *gin.Context) {
 	utils.SuccessResponse(c, envs)
 }
 
-// 项目域名相关
-func (h *ProjectHandler) CreateProjectDomain(c *gin.Context) {
+// UpdateProjectBuild 更新项目的构建配置
+func (h *ProjectHandler) UpdateProjectBuild(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
 		return
 	}
 
-	var req request.CreateProjectDomainRequest
+	var req request.UpdateBuildSettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	domain, err := h.projectService.CreateProjectDomain(c.Request.Context(), uint(id), &req)
+	project, err := h.projectService.UpdateProjectBuild(c.Request.Context(), uint(id), &req)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, domain)
+	utils.SuccessResponse(c, project)
 }
 
-func (h *ProjectHandler) GetProjectDomains(c *gin.Context) {
+// UpdateProjectEnvRouting 更新环境的路由设置
+func (h *ProjectHandler) UpdateProjectEnvRouting(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
 		return
 	}
 
-	domains, err := h.projectService.GetProjectDomains(c.Request.Context(), uint(id))
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
 		return
 	}
 
-	utils.SuccessResponse(c, domains)
+	var req request.UpdateEnvRoutingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	env, err := h.projectService.UpdateProjectEnvRouting(c.Request.Context(), uint(id), uint(envID), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, env)
 }
 
-// 项目部署相关
-func (h *ProjectHandler) CreateProjectDeploy(c *gin.Context) {
+// UpdateProjectEnvCache 更新环境的缓存设置
+func (h *ProjectHandler) UpdateProjectEnvCache(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
 		return
 	}
 
-	var req request.CreateProjectDeployRequest
+	envID, err := strconv.ParseUint(c.Param("envId"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的环境ID")
+		return
+	}
+
+	var req request.UpdateEnvCacheRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// 从token中获取用户ID
-	userID := uint(1)
+	env, err := h.projectService.UpdateProjectEnvCache(c.Request.Context(), uint(id), uint(envID), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, env)
+}
 
-	deploy, err := h.projectService.CreateProjectDeploy(c.Request.Context(), uint(id), userID, &req)
+// 项目域名相关
+func (h *ProjectHandler) CreateProjectDomain(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	var req request.CreateProjectDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain, err := h.projectService.CreateProjectDomain(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, domain)
+}
+
+func (h *ProjectHandler) GetProjectDomains(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+		return
+	}
+
+	domains, err := h.projectService.GetProjectDomains(c.Request.Context(), uint(id))
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utils.SuccessResponse(c, deploy)
+	utils.SuccessResponse(c, domains)
 }
 
+// 项目部署相关
 func (h *ProjectHandler) GetProjectDeploys(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
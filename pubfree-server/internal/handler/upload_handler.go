@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"path"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// tusVersion 支持的 tus 协议版本
+	tusVersion = "1.0.0"
+	// tusExtensions 支持的 tus 扩展
+	tusExtensions = "creation,expiration,termination"
+	// tusContentType PATCH 请求体的类型
+	tusContentType = "application/offset+octet-stream"
+)
+
+// UploadHandler 实现 tus 1.0 断点续传协议，响应通过状态码和响应头表达，只有出错时返回JSON
+type UploadHandler struct {
+	uploadService service.UploadService
+}
+
+func NewUploadHandler(uploadService service.UploadService) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+	}
+}
+
+// Options 返回支持的协议版本、扩展和上传大小上限
+func (h *UploadHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(h.uploadService.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload 创建上传，Upload-Length 为文件总大小，Upload-Metadata 中可带 filename
+func (h *UploadHandler) CreateUpload(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不支持延迟指定上传大小")
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的 Upload-Length")
+		return
+	}
+	metadata, err := parseMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的 Upload-Metadata")
+		return
+	}
+
+	upload, err := h.uploadService.Create(c.Request.Context(), middleware.GetUserID(c), length, metadata["filename"])
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	c.Header("Location", path.Join(c.Request.URL.Path, upload.ID))
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset 查询已接收的字节数，客户端据此继续上传
+func (h *UploadHandler) GetUploadOffset(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	upload, err := h.uploadService.Get(c.Request.Context(), middleware.GetUserID(c), c.Param("uploadId"))
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Cache-Control", "no-store")
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusOK)
+}
+
+// WriteUpload 从 Upload-Offset 处追加请求体中的内容
+func (h *UploadHandler) WriteUpload(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if c.ContentType() != tusContentType {
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, "Content-Type 须为 "+tusContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的 Upload-Offset")
+		return
+	}
+
+	upload, err := h.uploadService.Write(c.Request.Context(), middleware.GetUserID(c), c.Param("uploadId"), offset, c.Request.Body)
+	if err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	setUploadHeaders(c, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUpload 终止上传并删除已接收的内容
+func (h *UploadHandler) DeleteUpload(c *gin.Context) {
+	if !h.checkVersion(c) {
+		return
+	}
+
+	if err := h.uploadService.Delete(c.Request.Context(), middleware.GetUserID(c), c.Param("uploadId")); err != nil {
+		utils.ErrorResponse(c, errorStatus(err, http.StatusInternalServerError), err.Error())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// checkVersion 设置 Tus-Resumable 响应头，客户端的协议版本不受支持时写入 412 响应
+func (h *UploadHandler) checkVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "不支持的 tus 协议版本")
+		return false
+	}
+	return true
+}
+
+// setUploadHeaders 写入已接收的字节数，未完成的上传同时写入过期时间
+func setUploadHeaders(c *gin.Context, upload *model.Upload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.IsComplete() {
+		c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseMetadata 解析 Upload-Metadata：逗号分隔的键值对，键与 base64 编码的值以空格分隔，值可以省略
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("键不能为空")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"net/http"
 	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/middleware"
 	"pubfree-platform/pubfree-server/internal/service"
 	"pubfree-platform/pubfree-server/pkg/utils"
 	"strconv"
@@ -57,6 +58,11 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if middleware.GetUserID(c) != uint(id) {
+		utils.ErrorResponse(c, http.StatusForbidden, "只能修改自己的账号")
+		return
+	}
+
 	var req request.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
@@ -79,6 +85,11 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
+	if middleware.GetUserID(c) != uint(id) {
+		utils.ErrorResponse(c, http.StatusForbidden, "只能删除自己的账号")
+		return
+	}
+
 	if err := h.userService.DeleteUser(c.Request.Context(), uint(id)); err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
@@ -115,3 +126,55 @@ func (h *UserHandler) Login(c *gin.Context) {
 
 	utils.SuccessResponse(c, result)
 }
+
+func (h *UserHandler) RefreshToken(c *gin.Context) {
+	var req request.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.userService.RefreshToken(c.Request.Context(), &req)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+func (h *UserHandler) Logout(c *gin.Context) {
+	var req request.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.userService.Logout(c.Request.Context(), &req); err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
+
+// RevokeSessions 吊销用户的全部会话，只允许用户本人操作
+func (h *UserHandler) RevokeSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
+		return
+	}
+
+	if middleware.GetUserID(c) != uint(id) {
+		utils.ErrorResponse(c, http.StatusForbidden, "只能吊销自己的会话")
+		return
+	}
+
+	if err := h.userService.RevokeSessions(c.Request.Context(), uint(id)); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, nil)
+}
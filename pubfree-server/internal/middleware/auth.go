@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ContextUserIDKey 当前登录用户ID在gin.Context中的键
+	ContextUserIDKey = "user_id"
+	// ContextUserNameKey 当前登录用户名在gin.Context中的键
+	ContextUserNameKey = "user_name"
+	// ContextSessionIDKey 当前会话ID在gin.Context中的键
+	ContextSessionIDKey = "session_id"
+	// ContextApiTokenKey 通过API令牌认证时，令牌记录在gin.Context中的键
+	ContextApiTokenKey = "api_token"
+)
+
+// Auth 校验请求头中的Bearer令牌，并将当前用户写入上下文。
+// 令牌可以是登录签发的JWT，也可以是个人访问令牌或部署令牌
+func Auth(tokenService service.TokenService, apiTokenService service.ApiTokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := extractToken(c)
+		if tokenString == "" {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "未登录或缺少访问令牌")
+			c.Abort()
+			return
+		}
+
+		if service.IsApiToken(tokenString) {
+			token, err := apiTokenService.Authenticate(c.Request.Context(), tokenString)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+				c.Abort()
+				return
+			}
+
+			c.Set(ContextUserIDKey, token.UserID)
+			c.Set(ContextUserNameKey, token.User.Name)
+			c.Set(ContextApiTokenKey, token)
+			c.Next()
+			return
+		}
+
+		claims, err := tokenService.ParseAccessToken(c.Request.Context(), tokenString)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "访问令牌无效或已过期")
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextUserIDKey, claims.UserID)
+		c.Set(ContextUserNameKey, claims.Name)
+		c.Set(ContextSessionIDKey, claims.SessionID)
+		c.Next()
+	}
+}
+
+// GetUserID 获取当前登录用户ID，未登录时返回0
+func GetUserID(c *gin.Context) uint {
+	return c.GetUint(ContextUserIDKey)
+}
+
+// GetApiToken 获取当前请求使用的API令牌，通过登录会话访问时返回nil
+func GetApiToken(c *gin.Context) *model.ApiToken {
+	if value, ok := c.Get(ContextApiTokenKey); ok {
+		if token, ok := value.(*model.ApiToken); ok {
+			return token
+		}
+	}
+	return nil
+}
+
+// extractToken 从 Authorization: Bearer <token> 或 Token 请求头中读取令牌
+func extractToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		parts := strings.SplitN(auth, " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			return strings.TrimSpace(parts[1])
+		}
+		return ""
+	}
+	return strings.TrimSpace(c.GetHeader("Token"))
+}
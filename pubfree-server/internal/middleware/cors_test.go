@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		headers    map[string]string
+		wantStatus int
+		wantOrigin string
+	}{
+		{"预检请求直接返回", http.MethodOptions, map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PATCH"}, http.StatusNoContent, "https://app.example.com"},
+		{"tus 能力探测交给路由", http.MethodOptions, map[string]string{"Origin": "https://app.example.com", "Tus-Resumable": "1.0.0"}, http.StatusOK, "https://app.example.com"},
+		{"非浏览器的 OPTIONS 交给路由", http.MethodOptions, map[string]string{"Access-Control-Request-Method": "PATCH"}, http.StatusOK, ""},
+		{"跨域请求", http.MethodGet, map[string]string{"Origin": "https://app.example.com"}, http.StatusOK, "https://app.example.com"},
+		{"同源请求", http.MethodGet, nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(CORS())
+			r.Handle(tt.method, "/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("状态码 = %d, 期望 %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, 期望 %q", got, tt.wantOrigin)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actionScope API令牌执行各操作所需的权限范围
+func actionScope(action service.Action) string {
+	switch action {
+	case service.ActionView:
+		return model.ApiTokenScopeRead
+	case service.ActionDeploy:
+		return model.ApiTokenScopeDeploy
+	default:
+		return model.ApiTokenScopeWrite
+	}
+}
+
+// ProjectPermission 校验当前用户在路由 :id 项目上的角色是否允许执行操作。
+// 部署令牌至多具备 Developer 的权限，且创建者须仍有权执行该操作，创建者被移出项目或降级后令牌随之失效
+func ProjectPermission(permissionService service.PermissionService, action service.Action) gin.HandlerFunc {
+	scope := actionScope(action)
+	return func(c *gin.Context) {
+		projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的项目ID")
+			c.Abort()
+			return
+		}
+
+		if !checkProjectScope(c, uint(projectID), scope) {
+			return
+		}
+
+		if token := GetApiToken(c); token != nil && token.Kind == model.ApiTokenKindDeploy {
+			if !service.RoleAllows(model.RoleDeveloper, action) {
+				abortForbidden(c, service.ErrForbidden.Error())
+				return
+			}
+		}
+
+		// 使用API令牌时当前用户为令牌的创建者
+		err = permissionService.CheckProject(c.Request.Context(), uint(projectID), GetUserID(c), action)
+		if !abortOnPermissionError(c, err) {
+			c.Next()
+		}
+	}
+}
+
+// GroupPermission 校验当前用户在路由 :id 空间上的角色是否允许执行操作，部署令牌一律拒绝
+func GroupPermission(permissionService service.PermissionService, action service.Action) gin.HandlerFunc {
+	scope := actionScope(action)
+	return func(c *gin.Context) {
+		groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的空间ID")
+			c.Abort()
+			return
+		}
+
+		if !checkScope(c, scope) {
+			return
+		}
+
+		err = permissionService.CheckGroup(c.Request.Context(), uint(groupID), GetUserID(c), action)
+		if !abortOnPermissionError(c, err) {
+			c.Next()
+		}
+	}
+}
+
+// PlatformAdmin 仅允许平台管理员访问，部署令牌一律拒绝，个人令牌需要写权限
+func PlatformAdmin(permissionService service.PermissionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkScope(c, model.ApiTokenScopeWrite) {
+			return
+		}
+		if !permissionService.IsPlatformAdmin(GetUserID(c)) {
+			abortForbidden(c, "仅平台管理员可以执行该操作")
+			return
+		}
+		c.Next()
+	}
+}
+
+// abortOnPermissionError 根据权限校验结果中止请求，返回是否已中止
+func abortOnPermissionError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, service.ErrForbidden):
+		utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrProjectNotFound), errors.Is(err, service.ErrGroupNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+	}
+	c.Abort()
+	return true
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakePermissionService roles 为用户在项目 1 中的角色，其余方法未使用
+type fakePermissionService struct {
+	service.PermissionService
+	admins map[uint]bool
+	roles  map[uint]model.Role
+}
+
+func (f *fakePermissionService) CheckProject(ctx context.Context, projectID, userID uint, action service.Action) error {
+	if projectID != 1 {
+		return service.ErrProjectNotFound
+	}
+	if !service.RoleAllows(f.roles[userID], action) {
+		return service.ErrForbidden
+	}
+	return nil
+}
+
+func (f *fakePermissionService) IsPlatformAdmin(userID uint) bool {
+	return f.admins[userID]
+}
+
+func TestPlatformAdmin(t *testing.T) {
+	permissionService := &fakePermissionService{admins: map[uint]bool{1: true}}
+
+	tests := []struct {
+		name   string
+		userID uint
+		token  *model.ApiToken
+		want   int
+	}{
+		{"平台管理员", 1, nil, http.StatusOK},
+		{"普通用户", 2, nil, http.StatusForbidden},
+		{"管理员的个人令牌", 1, &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "write"}, http.StatusOK},
+		{"管理员的只读令牌", 1, &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, http.StatusForbidden},
+		{"管理员的部署令牌", 1, &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: "write"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setUser := func(c *gin.Context) { c.Set(ContextUserIDKey, tt.userID) }
+			if got := serve(tt.token, "/", "/", setUser, PlatformAdmin(permissionService)); got != tt.want {
+				t.Errorf("状态码 = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectPermission(t *testing.T) {
+	permissionService := &fakePermissionService{roles: map[uint]model.Role{
+		1: model.RoleOwner,
+		2: model.RoleDeveloper,
+		3: model.RoleGuest,
+	}}
+	deployToken := func(creator uint) *model.ApiToken {
+		return &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: "deploy", UserID: creator, ProjectID: uintPtr(1)}
+	}
+
+	tests := []struct {
+		name   string
+		userID uint
+		token  *model.ApiToken
+		action service.Action
+		path   string
+		want   int
+	}{
+		{"成员部署", 2, nil, service.ActionDeploy, "/projects/1", http.StatusOK},
+		{"访客不能部署", 3, nil, service.ActionDeploy, "/projects/1", http.StatusForbidden},
+		{"非成员", 4, nil, service.ActionView, "/projects/1", http.StatusForbidden},
+		{"项目不存在", 1, nil, service.ActionView, "/projects/2", http.StatusNotFound},
+		{"部署令牌", 2, deployToken(2), service.ActionDeploy, "/projects/1", http.StatusOK},
+		{"Owner 创建的部署令牌不能修改项目", 1, deployToken(1), service.ActionUpdate, "/projects/1", http.StatusForbidden},
+		{"创建者被降级为访客", 3, deployToken(3), service.ActionDeploy, "/projects/1", http.StatusForbidden},
+		{"创建者被移出项目", 4, deployToken(4), service.ActionDeploy, "/projects/1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setUser := func(c *gin.Context) { c.Set(ContextUserIDKey, tt.userID) }
+			if got := serve(tt.token, "/projects/:id", tt.path, setUser, ProjectPermission(permissionService, tt.action)); got != tt.want {
+				t.Errorf("状态码 = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
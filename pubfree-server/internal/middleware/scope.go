@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope 校验API令牌的权限范围，用于非项目路由，部署令牌一律拒绝。
+// 通过登录会话访问时不做限制
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if checkScope(c, scope) {
+			c.Next()
+		}
+	}
+}
+
+func checkScope(c *gin.Context, scope string) bool {
+	token := GetApiToken(c)
+	if token == nil {
+		return true
+	}
+
+	if token.Kind != model.ApiTokenKindUser || !token.HasScope(scope) {
+		abortForbidden(c, "API令牌无权访问该接口")
+		return false
+	}
+	return true
+}
+
+// checkProjectScope 校验项目路由（:id 为项目ID）的API令牌权限范围，
+// 部署令牌只能访问绑定的项目，路由中带有 :envId 时还需匹配绑定的环境
+func checkProjectScope(c *gin.Context, projectID uint, scope string) bool {
+	token := GetApiToken(c)
+	if token == nil {
+		return true
+	}
+
+	if !token.HasScope(scope) {
+		abortForbidden(c, "API令牌无权访问该接口")
+		return false
+	}
+
+	if token.Kind == model.ApiTokenKindDeploy {
+		if token.ProjectID == nil || *token.ProjectID != projectID {
+			abortForbidden(c, "部署令牌无权访问该项目")
+			return false
+		}
+
+		if envParam := c.Param("envId"); envParam != "" {
+			envID, err := strconv.ParseUint(envParam, 10, 32)
+			if err != nil || !CanAccessEnv(c, uint(envID)) {
+				abortForbidden(c, "部署令牌无权访问该环境")
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RequireDeployScope 校验API令牌具备部署权限，部署令牌和个人令牌均可访问，用于不属于具体项目的部署相关接口。
+// 项目和环境的权限在使用时再校验
+func RequireDeployScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := GetApiToken(c); token != nil && !token.HasScope(model.ApiTokenScopeDeploy) {
+			abortForbidden(c, "API令牌无权访问该接口")
+			return
+		}
+		c.Next()
+	}
+}
+
+// SessionOnly 仅允许登录会话访问，用于令牌管理等敏感操作
+func SessionOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetApiToken(c) != nil {
+			abortForbidden(c, "该接口不支持使用API令牌访问")
+			return
+		}
+		c.Next()
+	}
+}
+
+// CanAccessEnv 判断当前请求能否操作指定环境，用于环境ID位于请求体中的场景
+func CanAccessEnv(c *gin.Context, envID uint) bool {
+	token := GetApiToken(c)
+	if token == nil || token.Kind != model.ApiTokenKindDeploy || token.ProjectEnvID == nil {
+		return true
+	}
+	return *token.ProjectEnvID == envID
+}
+
+func abortForbidden(c *gin.Context, message string) {
+	utils.ErrorResponse(c, http.StatusForbidden, message)
+	c.Abort()
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+// serve 以指定的API令牌（nil 表示登录会话）请求挂载了 handlers 的路由，返回响应状态码
+func serve(token *model.ApiToken, route, path string, handlers ...gin.HandlerFunc) int {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if token != nil {
+			c.Set(ContextApiTokenKey, token)
+		}
+	})
+	handlers = append(handlers, func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET(route, handlers...)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w.Code
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name  string
+		token *model.ApiToken
+		scope string
+		want  int
+	}{
+		{"登录会话", nil, model.ApiTokenScopeWrite, http.StatusOK},
+		{"个人令牌具备权限", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, model.ApiTokenScopeRead, http.StatusOK},
+		{"write 包含 read", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "write"}, model.ApiTokenScopeRead, http.StatusOK},
+		{"个人令牌缺少权限", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, model.ApiTokenScopeWrite, http.StatusForbidden},
+		{"部署令牌一律拒绝", &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: "write"}, model.ApiTokenScopeRead, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.token, "/", "/", RequireScope(tt.scope)); got != tt.want {
+				t.Errorf("状态码 = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckProjectScope(t *testing.T) {
+	deployToken := func(scopes string, projectID uint, envID *uint) *model.ApiToken {
+		return &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: scopes, ProjectID: uintPtr(projectID), ProjectEnvID: envID}
+	}
+	check := func(scope string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			projectID, _ := strconv.ParseUint(c.Param("id"), 10, 32)
+			if checkProjectScope(c, uint(projectID), scope) {
+				c.Next()
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token *model.ApiToken
+		scope string
+		path  string
+		want  int
+	}{
+		{"登录会话", nil, model.ApiTokenScopeWrite, "/projects/1/envs/2", http.StatusOK},
+		{"个人令牌可访问任意项目", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, model.ApiTokenScopeRead, "/projects/9/envs/2", http.StatusOK},
+		{"个人令牌缺少权限", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, model.ApiTokenScopeDeploy, "/projects/1/envs/2", http.StatusForbidden},
+		{"部署令牌访问绑定的项目", deployToken("deploy", 1, nil), model.ApiTokenScopeDeploy, "/projects/1/envs/2", http.StatusOK},
+		{"部署令牌访问其他项目", deployToken("deploy", 1, nil), model.ApiTokenScopeDeploy, "/projects/2/envs/2", http.StatusForbidden},
+		{"部署令牌缺少权限", deployToken("deploy", 1, nil), model.ApiTokenScopeWrite, "/projects/1/envs/2", http.StatusForbidden},
+		{"部署令牌访问绑定的环境", deployToken("deploy", 1, uintPtr(2)), model.ApiTokenScopeDeploy, "/projects/1/envs/2", http.StatusOK},
+		{"部署令牌访问其他环境", deployToken("deploy", 1, uintPtr(2)), model.ApiTokenScopeDeploy, "/projects/1/envs/3", http.StatusForbidden},
+		{"无效的环境ID", deployToken("deploy", 1, uintPtr(2)), model.ApiTokenScopeDeploy, "/projects/1/envs/abc", http.StatusForbidden},
+		{"未绑定项目的部署令牌", &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: "deploy"}, model.ApiTokenScopeDeploy, "/projects/1/envs/2", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.token, "/projects/:id/envs/:envId", tt.path, check(tt.scope)); got != tt.want {
+				t.Errorf("状态码 = %d, 期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireDeployScopeAndSessionOnly(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       *model.ApiToken
+		deployWant  int
+		sessionWant int
+	}{
+		{"登录会话", nil, http.StatusOK, http.StatusOK},
+		{"部署令牌", &model.ApiToken{Kind: model.ApiTokenKindDeploy, Scopes: "deploy"}, http.StatusOK, http.StatusForbidden},
+		{"个人令牌具备写权限", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "write"}, http.StatusOK, http.StatusForbidden},
+		{"只读令牌", &model.ApiToken{Kind: model.ApiTokenKindUser, Scopes: "read"}, http.StatusForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(tt.token, "/", "/", RequireDeployScope()); got != tt.deployWant {
+				t.Errorf("RequireDeployScope 状态码 = %d, 期望 %d", got, tt.deployWant)
+			}
+			if got := serve(tt.token, "/", "/", SessionOnly()); got != tt.sessionWant {
+				t.Errorf("SessionOnly 状态码 = %d, 期望 %d", got, tt.sessionWant)
+			}
+		})
+	}
+}
+
+func TestCanAccessEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		token *model.ApiToken
+		envID uint
+		want  bool
+	}{
+		{"登录会话", nil, 3, true},
+		{"个人令牌", &model.ApiToken{Kind: model.ApiTokenKindUser}, 3, true},
+		{"未绑定环境的部署令牌", &model.ApiToken{Kind: model.ApiTokenKindDeploy, ProjectID: uintPtr(1)}, 3, true},
+		{"绑定的环境", &model.ApiToken{Kind: model.ApiTokenKindDeploy, ProjectID: uintPtr(1), ProjectEnvID: uintPtr(3)}, 3, true},
+		{"其他环境", &model.ApiToken{Kind: model.ApiTokenKindDeploy, ProjectID: uintPtr(1), ProjectEnvID: uintPtr(3)}, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			if tt.token != nil {
+				c.Set(ContextApiTokenKey, tt.token)
+			}
+			if got := CanAccessEnv(c, tt.envID); got != tt.want {
+				t.Errorf("CanAccessEnv = %v, 期望 %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ApiTokenKindUser 个人访问令牌，代表创建者本人
+	ApiTokenKindUser = "user"
+	// ApiTokenKindDeploy 部署令牌，只能访问绑定的项目和环境
+	ApiTokenKindDeploy = "deploy"
+)
+
+const (
+	ApiTokenScopeRead   = "read"
+	ApiTokenScopeWrite  = "write"
+	ApiTokenScopeDeploy = "deploy"
+)
+
+// ApiToken 长期有效的API令牌，数据库中只保存令牌摘要
+type ApiToken struct {
+	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string         `gorm:"type:varchar(128);not null" json:"name"`
+	Kind         string         `gorm:"type:varchar(16);not null" json:"kind"`
+	TokenPrefix  string         `gorm:"type:varchar(16);not null" json:"token_prefix"`
+	TokenHash    string         `gorm:"type:char(64);not null;uniqueIndex:uk_token_hash" json:"-"`
+	Scopes       string         `gorm:"type:varchar(255);not null" json:"scopes"`
+	UserID       uint           `gorm:"not null;index:idx_user_id" json:"user_id"`
+	ProjectID    *uint          `gorm:"default:null;index:idx_project_id" json:"project_id"`
+	ProjectEnvID *uint          `gorm:"default:null" json:"project_env_id"`
+	ExpiresAt    *time.Time     `gorm:"default:null" json:"expires_at"`
+	LastUsedAt   *time.Time     `gorm:"default:null" json:"last_used_at"`
+	RevokedAt    *time.Time     `gorm:"default:null" json:"revoked_at"`
+	IsDel        int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
+	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 关联
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (ApiToken) TableName() string {
+	return "api_token"
+}
+
+// ScopeList 返回令牌的权限范围列表
+func (t *ApiToken) ScopeList() []string {
+	if t.Scopes == "" {
+		return nil
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// HasScope 判断令牌是否具备指定权限，write 包含全部权限
+func (t *ApiToken) HasScope(scope string) bool {
+	for _, s := range t.ScopeList() {
+		if s == scope || s == ApiTokenScopeWrite {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValid 判断令牌当前是否可用
+func (t *ApiToken) IsValid(now time.Time) bool {
+	if t.RevokedAt != nil || t.IsDel != 0 {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,9 @@
+package model
+
+// BuildSettings 项目的构建配置，配置后可从代码仓库构建部署
+type BuildSettings struct {
+	RepoURL   string `json:"repo_url"`
+	Branch    string `json:"branch"`     // 为空时使用仓库默认分支
+	Command   string `json:"command"`    // 在仓库根目录执行，如 npm ci && npm run build
+	OutputDir string `json:"output_dir"` // 构建产物目录，相对仓库根目录，如 dist
+}
@@ -0,0 +1,26 @@
+package model
+
+import "pubfree-platform/pubfree-server/pkg/rules"
+
+// CacheSettings 环境的缓存设置，由静态站点网关执行。
+// 未匹配到规则时，文件名带内容哈希的资源长期缓存，其他文件每次向网关确认是否变化
+type CacheSettings struct {
+	// Rules 按顺序匹配请求路径，第一条匹配的规则生效，优先于产物 _headers 中的 Cache-Control
+	Rules []CacheRule `json:"rules"`
+}
+
+// CacheRule 路径匹配时使用的 Cache-Control
+type CacheRule struct {
+	Path         string `json:"path"` // 路径模式，语法与 _headers 相同
+	CacheControl string `json:"cache_control"`
+}
+
+// Match 返回第一条与请求路径匹配的规则，没有时返回 nil
+func (s *CacheSettings) Match(p string) *CacheRule {
+	for i := range s.Rules {
+		if rules.Match(s.Rules[i].Path, p) {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// 激活方式
+const (
+	ActivationActionActivate = "activate"
+	ActivationActionRollback = "rollback"
+	ActivationActionPromote  = "promote" // 灰度环境的部署发布到生产环境
+)
+
+// DeployActivation 环境的激活历史，每次切换激活部署记录一条，只增不改
+type DeployActivation struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID    uint      `gorm:"not null;index:idx_project_id" json:"project_id"`
+	ProjectEnvID uint      `gorm:"not null;index:idx_project_env_id" json:"project_env_id"`
+	FromDeployID *uint     `gorm:"default:null" json:"from_deploy_id"` // 切换前的激活部署，首次激活时为空
+	ToDeployID   uint      `gorm:"not null" json:"to_deploy_id"`
+	Action       string    `gorm:"type:varchar(16);not null" json:"action"`
+	OperatorID   uint      `gorm:"not null" json:"operator_id"`
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+
+	// 关联
+	Operator User `gorm:"foreignKey:OperatorID" json:"operator,omitempty"`
+}
+
+func (DeployActivation) TableName() string {
+	return "deploy_activation"
+}
@@ -0,0 +1,43 @@
+package model
+
+import "pubfree-platform/pubfree-server/pkg/compress"
+
+// DeployFile 部署的文件清单，部署处理完成时写入，只增不改。
+// 从其他部署复制而来的部署没有自己的清单，使用源部署的清单
+type DeployFile struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeployID    uint   `gorm:"not null;uniqueIndex:uk_deploy_path,priority:1" json:"deploy_id"`
+	Path        string `gorm:"type:varchar(512);not null;uniqueIndex:uk_deploy_path,priority:2" json:"path"` // 相对站点根目录的路径
+	Size        int64  `gorm:"not null" json:"size"`
+	Checksum    string `gorm:"type:char(64);not null" json:"checksum"` // 文件内容的 sha256
+	ContentType string `gorm:"type:varchar(128);not null;default:''" json:"content_type"`
+	// 预压缩版本的大小，0 表示没有该版本：文件不值得压缩，或压缩后没有变小
+	GzipSize   int64 `gorm:"not null;default:0" json:"gzip_size"`
+	BrotliSize int64 `gorm:"not null;default:0" json:"brotli_size"`
+}
+
+func (DeployFile) TableName() string {
+	return "deploy_file"
+}
+
+// EncodedSize 指定编码的预压缩版本大小，没有该版本时返回 0
+func (f *DeployFile) EncodedSize(encoding string) int64 {
+	switch encoding {
+	case compress.Gzip:
+		return f.GzipSize
+	case compress.Brotli:
+		return f.BrotliSize
+	default:
+		return 0
+	}
+}
+
+// SetEncodedSize 记录指定编码的预压缩版本大小
+func (f *DeployFile) SetEncodedSize(encoding string, size int64) {
+	switch encoding {
+	case compress.Gzip:
+		f.GzipSize = size
+	case compress.Brotli:
+		f.BrotliSize = size
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// 部署日志级别
+const (
+	DeployLogLevelInfo  = "info"
+	DeployLogLevelError = "error"
+)
+
+// DeployLog 部署处理过程中每个步骤的日志，按 ID 顺序读取，只增不改
+type DeployLog struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeployID  uint      `gorm:"not null;index:idx_deploy_id" json:"deploy_id"`
+	Level     string    `gorm:"type:varchar(8);not null" json:"level"`
+	Message   string    `gorm:"type:varchar(1024);not null" json:"message"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP(3);type:datetime(3)" json:"created_at"`
+}
+
+func (DeployLog) TableName() string {
+	return "deploy_log"
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// 部署状态
+const (
+	DeployStatusUploading  = "uploading"  // 增量上传：已提交文件清单，等待上传服务端缺少的文件内容
+	DeployStatusPending    = "pending"    // 等待后台任务处理
+	DeployStatusFetching   = "fetching"   // 下载远程产物
+	DeployStatusBuilding   = "building"   // 克隆代码仓库并执行构建命令
+	DeployStatusExtracting = "extracting" // 解压并写入存储
+	DeployStatusValidating = "validating" // 校验产物内容、解析规则文件
+	DeployStatusReady      = "ready"      // 产物已就绪，可以激活
+	DeployStatusActive     = "active"     // 环境当前激活的部署
+	DeployStatusFailed     = "failed"     // 处理失败，原因见 FailReason
+	DeployStatusSuperseded = "superseded" // 曾经激活，已被其他部署替换，可以回滚到该部署
+)
+
+// ErrInvalidDeployTransition 部署当前状态不允许切换到目标状态
+var ErrInvalidDeployTransition = errors.New("部署状态不允许该操作")
+
+// deployTransitions 部署状态机中允许的状态切换
+var deployTransitions = map[string][]string{
+	DeployStatusUploading:  {DeployStatusPending, DeployStatusFailed},
+	DeployStatusPending:    {DeployStatusFetching, DeployStatusBuilding, DeployStatusExtracting, DeployStatusValidating, DeployStatusFailed},
+	DeployStatusFetching:   {DeployStatusExtracting, DeployStatusFailed},
+	DeployStatusBuilding:   {DeployStatusExtracting, DeployStatusFailed},
+	DeployStatusExtracting: {DeployStatusValidating, DeployStatusFailed},
+	DeployStatusValidating: {DeployStatusReady, DeployStatusFailed},
+	DeployStatusReady:      {DeployStatusActive},
+	DeployStatusActive:     {DeployStatusSuperseded},
+	DeployStatusSuperseded: {DeployStatusActive},
+}
+
+// CanTransitDeploy 判断部署能否从 from 切换到 to
+func CanTransitDeploy(from, to string) bool {
+	for _, status := range deployTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// DeployStatusesInProgress 后台任务处理中的状态。
+// 等待上传的部署不在其中，服务重启不影响客户端继续上传
+var DeployStatusesInProgress = []string{
+	DeployStatusPending,
+	DeployStatusFetching,
+	DeployStatusBuilding,
+	DeployStatusExtracting,
+	DeployStatusValidating,
+}
+
+// IsDeployInProgress 部署仍在后台处理中，处理结束后状态不会再回到这些状态
+func IsDeployInProgress(status string) bool {
+	for _, s := range DeployStatusesInProgress {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDeployable 产物已就绪，可以激活或回滚到该部署
+func IsDeployable(status string) bool {
+	return status == DeployStatusReady || status == DeployStatusActive || status == DeployStatusSuperseded
+}
+
+// DeployTransition 部署的状态变更记录，只增不改，保存进入每个状态的时间和失败原因
+type DeployTransition struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeployID   uint      `gorm:"not null;index:idx_deploy_id" json:"deploy_id"`
+	FromStatus string    `gorm:"type:varchar(16);not null;default:''" json:"from_status"` // 创建部署时为空
+	ToStatus   string    `gorm:"type:varchar(16);not null" json:"to_status"`
+	Reason     *string   `gorm:"type:varchar(512)" json:"reason"`
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP(3);type:datetime(3)" json:"created_at"`
+}
+
+func (DeployTransition) TableName() string {
+	return "deploy_transition"
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 以下枚举与前端 src/interface 中的定义保持一致，可通过 GET /api/v1/meta/enums 获取。
+// JSON 序列化时输出数值，反序列化时同时接受数值和名称（如 3 或 "prod"）
+
+// EnumMeta 枚举值的展示信息
+type EnumMeta struct {
+	Name        string
+	Label       string
+	Description string
+}
+
+// Role 成员角色，数值越大权限越高
+type Role int
+
+const (
+	RoleGuest     Role = 1 << 4
+	RoleDeveloper Role = 1 << 5
+	RoleMaster    Role = 1 << 6
+	RoleOwner     Role = 1 << 7
+)
+
+// Roles 全部角色，按权限从高到低排列
+var Roles = []Role{RoleOwner, RoleMaster, RoleDeveloper, RoleGuest}
+
+var roleMeta = map[Role]EnumMeta{
+	RoleGuest:     {Name: "guest", Label: "Guest", Description: "可浏览项目，不支持任何修改"},
+	RoleDeveloper: {Name: "developer", Label: "Developer", Description: "日常项目操作权限，比如发布，创建工作区等"},
+	RoleMaster:    {Name: "master", Label: "Master", Description: "可添加修改项目成员与角色，生产审批变更"},
+	RoleOwner:     {Name: "owner", Label: "Owner", Description: "可以删除、删除项目成员、转移项目、修改项目详情"},
+}
+
+// LegacyRole 角色改为位标记之前成员表中的取值对应的角色。创建者写入 1（管理员），对应 Owner；
+// 添加成员时可传的其他取值（2-10）没有定义含义，当时也没有权限控制，对应可以发布的 Developer。
+// 不是旧取值时返回 false
+func LegacyRole(value int) (Role, bool) {
+	switch {
+	case value == 1:
+		return RoleOwner, true
+	case value > 1 && value < int(RoleGuest):
+		return RoleDeveloper, true
+	}
+	return 0, false
+}
+
+func (r Role) Meta() EnumMeta               { return roleMeta[r] }
+func (r Role) String() string               { return enumString(r, roleMeta) }
+func (r Role) IsValid() bool                { _, ok := roleMeta[r]; return ok }
+func (r Role) MarshalJSON() ([]byte, error) { return json.Marshal(int(r)) }
+func (r *Role) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, r, roleMeta)
+}
+
+// EnvType 环境（工作区）类型
+type EnvType int8
+
+const (
+	EnvTypeTest EnvType = 0
+	EnvTypeBeta EnvType = 1
+	EnvTypeGray EnvType = 2
+	EnvTypeProd EnvType = 3
+)
+
+// EnvTypes 全部环境类型
+var EnvTypes = []EnvType{EnvTypeTest, EnvTypeBeta, EnvTypeGray, EnvTypeProd}
+
+var envTypeMeta = map[EnvType]EnumMeta{
+	EnvTypeTest: {Name: "test", Label: "测试"},
+	EnvTypeBeta: {Name: "beta", Label: "预发"},
+	EnvTypeGray: {Name: "gray", Label: "灰度"},
+	EnvTypeProd: {Name: "prod", Label: "生产"},
+}
+
+func (t EnvType) Meta() EnumMeta               { return envTypeMeta[t] }
+func (t EnvType) String() string               { return enumString(t, envTypeMeta) }
+func (t EnvType) IsValid() bool                { _, ok := envTypeMeta[t]; return ok }
+func (t EnvType) MarshalJSON() ([]byte, error) { return json.Marshal(int(t)) }
+func (t *EnvType) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, t, envTypeMeta)
+}
+
+// envTypeStorageOffset 数据库中沿用最初的取值 1-4（测试、预发、灰度、生产），比接口中的取值大 1，
+// 读写数据库时转换，已有的环境数据无需迁移
+const envTypeStorageOffset = 1
+
+func (t EnvType) Value() (driver.Value, error) {
+	if !t.IsValid() {
+		return nil, fmt.Errorf("无效的环境类型: %d", t)
+	}
+	return int64(t) + envTypeStorageOffset, nil
+}
+
+func (t *EnvType) Scan(value interface{}) error {
+	var n sql.NullInt64
+	if err := n.Scan(value); err != nil {
+		return err
+	}
+	if !n.Valid {
+		return fmt.Errorf("环境类型不能为空")
+	}
+	*t = EnvType(n.Int64 - envTypeStorageOffset)
+	return nil
+}
+
+// TargetType 部署产物来源
+type TargetType int8
+
+const (
+	TargetTypeZip      TargetType = 0 // 上传的压缩包
+	TargetTypeURL      TargetType = 1 // 远程地址
+	TargetTypeGit      TargetType = 2 // 从代码仓库构建
+	TargetTypeManifest TargetType = 3 // 增量上传：提交文件清单，只上传服务端缺少的文件内容
+)
+
+// TargetTypes 全部产物来源
+var TargetTypes = []TargetType{TargetTypeZip, TargetTypeURL, TargetTypeGit, TargetTypeManifest}
+
+var targetTypeMeta = map[TargetType]EnumMeta{
+	TargetTypeZip:      {Name: "zip", Label: "压缩包"},
+	TargetTypeURL:      {Name: "url", Label: "远程地址"},
+	TargetTypeGit:      {Name: "git", Label: "代码仓库"},
+	TargetTypeManifest: {Name: "manifest", Label: "增量上传"},
+}
+
+func (t TargetType) Meta() EnumMeta               { return targetTypeMeta[t] }
+func (t TargetType) String() string               { return enumString(t, targetTypeMeta) }
+func (t TargetType) IsValid() bool                { _, ok := targetTypeMeta[t]; return ok }
+func (t TargetType) MarshalJSON() ([]byte, error) { return json.Marshal(int(t)) }
+func (t *TargetType) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, t, targetTypeMeta)
+}
+
+type enumValue interface {
+	~int | ~int8
+}
+
+func enumString[T enumValue](v T, meta map[T]EnumMeta) string {
+	if m, ok := meta[v]; ok {
+		return m.Name
+	}
+	return fmt.Sprintf("unknown(%d)", int(v))
+}
+
+// unmarshalEnum 解析数值或名称形式的枚举值，名称不区分大小写
+func unmarshalEnum[T enumValue](data []byte, target *T, meta map[T]EnumMeta) error {
+	var number int
+	if err := json.Unmarshal(data, &number); err == nil {
+		if _, ok := meta[T(number)]; !ok || int(T(number)) != number {
+			return fmt.Errorf("无效的枚举值: %d", number)
+		}
+		*target = T(number)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("枚举值必须是数字或字符串: %s", string(data))
+	}
+	for value, m := range meta {
+		if strings.EqualFold(m.Name, name) {
+			*target = value
+			return nil
+		}
+	}
+	return fmt.Errorf("无效的枚举值: %q", name)
+}
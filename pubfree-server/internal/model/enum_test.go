@@ -0,0 +1,106 @@
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestEnvTypeStorage(t *testing.T) {
+	tests := []struct {
+		envType EnvType
+		stored  int64
+	}{
+		{EnvTypeTest, 1},
+		{EnvTypeBeta, 2},
+		{EnvTypeGray, 3},
+		{EnvTypeProd, 4},
+	}
+	for _, tt := range tests {
+		value, err := tt.envType.Value()
+		if err != nil {
+			t.Fatalf("%s: Value 出错: %v", tt.envType, err)
+		}
+		if value != tt.stored {
+			t.Errorf("%s: 存储值 = %v, 期望 %d", tt.envType, value, tt.stored)
+		}
+
+		// MySQL 驱动可能以整数或文本返回 tinyint
+		for _, raw := range []interface{}{tt.stored, []byte(strconv.FormatInt(tt.stored, 10))} {
+			var scanned EnvType
+			if err := scanned.Scan(raw); err != nil {
+				t.Fatalf("%s: Scan(%v) 出错: %v", tt.envType, raw, err)
+			}
+			if scanned != tt.envType {
+				t.Errorf("Scan(%v) = %s, 期望 %s", raw, scanned, tt.envType)
+			}
+		}
+	}
+
+	if _, err := EnvType(9).Value(); err == nil {
+		t.Error("无效的环境类型应当无法写入数据库")
+	}
+	var scanned EnvType
+	if err := scanned.Scan(nil); err == nil {
+		t.Error("空的环境类型应当返回错误")
+	}
+}
+
+func TestEnvTypeJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    EnvType
+		wantErr bool
+	}{
+		{input: `0`, want: EnvTypeTest},
+		{input: `3`, want: EnvTypeProd},
+		{input: `"gray"`, want: EnvTypeGray},
+		{input: `"PROD"`, want: EnvTypeProd},
+		{input: `4`, wantErr: true},
+		{input: `"staging"`, wantErr: true},
+		{input: `true`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got EnvType
+		err := json.Unmarshal([]byte(tt.input), &got)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Unmarshal(%s) err = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Unmarshal(%s) = %s, 期望 %s", tt.input, got, tt.want)
+		}
+	}
+
+	data, err := json.Marshal(EnvTypeProd)
+	if err != nil || string(data) != "3" {
+		t.Errorf("Marshal(EnvTypeProd) = %s, %v, 期望 3", data, err)
+	}
+}
+
+func TestLegacyRole(t *testing.T) {
+	tests := []struct {
+		value int
+		want  Role
+		ok    bool
+	}{
+		{1, RoleOwner, true},
+		{2, RoleDeveloper, true},
+		{10, RoleDeveloper, true},
+		{15, RoleDeveloper, true},
+		{0, 0, false},
+		{int(RoleGuest), 0, false},
+		{int(RoleOwner), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := LegacyRole(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("LegacyRole(%d) = %v, %v, 期望 %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+		// 转换后的角色不再被视为旧取值
+		if ok {
+			if _, again := LegacyRole(int(got)); again {
+				t.Errorf("LegacyRole(%d) 转换结果仍是旧取值", tt.value)
+			}
+		}
+	}
+}
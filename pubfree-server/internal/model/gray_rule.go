@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// GrayRule 灰度分流规则：将生产环境域名上一定比例的流量分给灰度环境当前激活的部署
+type GrayRule struct {
+	ID         uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID  uint `gorm:"not null;index:idx_project_id" json:"project_id"`
+	ProdEnvID  uint `gorm:"not null;uniqueIndex:uk_prod_env_id" json:"prod_env_id"`
+	GrayEnvID  uint `gorm:"not null;uniqueIndex:uk_gray_env_id" json:"gray_env_id"`
+	Percentage int  `gorm:"not null;default:0" json:"percentage"` // 进入灰度的流量比例，0-100
+	// ForceHeader 请求头名称，值为 1/true/gray 时强制进入灰度，0/false/prod 时强制留在生产
+	ForceHeader string `gorm:"type:varchar(64);not null;default:''" json:"force_header"`
+	// ForceCookie cookie 名称，取值规则同 ForceHeader
+	ForceCookie string `gorm:"type:varchar(64);not null;default:''" json:"force_cookie"`
+	// UserIDCookie 站点保存用户ID的 cookie 名称，用户ID在 AllowUserIDs 中时进入灰度
+	UserIDCookie string    `gorm:"type:varchar(64);not null;default:''" json:"user_id_cookie"`
+	AllowUserIDs []string  `gorm:"type:json;serializer:json" json:"allow_user_ids"`
+	OperatorID   uint      `gorm:"not null" json:"operator_id"` // 最后修改规则的用户
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (GrayRule) TableName() string {
+	return "gray_rule"
+}
@@ -10,7 +10,7 @@ type GroupMember struct {
 	ID        uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	GroupID   uint           `gorm:"not null;index:idx_group_id" json:"group_id"`
 	UserID    uint           `gorm:"not null" json:"user_id"`
-	Role      int8           `gorm:"type:int(8);not null" json:"role"`
+	Role      Role           `gorm:"type:int;not null" json:"role"`
 	IsDel     int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
 	CreatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
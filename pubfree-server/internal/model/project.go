@@ -14,6 +14,7 @@ type Project struct {
 	OwnerID      uint           `gorm:"not null" json:"owner_id"`
 	GroupID      *uint          `gorm:"default:null" json:"group_id"`
 	CreateUserID uint           `gorm:"not null" json:"create_user_id"`
+	Build        *BuildSettings `gorm:"type:json;serializer:json" json:"build"` // 未配置时不能从代码仓库构建
 	IsDel        int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
 	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
@@ -10,7 +10,7 @@ type ProjectDomain struct {
 	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
 	ProjectID    uint           `gorm:"not null;index:idx_project_id" json:"project_id"`
 	ProjectEnvID uint           `gorm:"not null" json:"project_env_id"`
-	Host         string         `gorm:"type:varchar(255);not null" json:"host"`
+	Host         string         `gorm:"type:varchar(255);not null;index:idx_host" json:"host"` // 小写、不含端口
 	IsDel        int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
 	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
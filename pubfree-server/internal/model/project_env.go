@@ -7,15 +7,17 @@ import (
 )
 
 type ProjectEnv struct {
-	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	ProjectID    uint           `gorm:"not null;index:idx_project_id" json:"project_id"`
-	Name         string         `gorm:"type:varchar(128);not null" json:"name"`
-	EnvType      int8           `gorm:"type:tinyint(2);not null" json:"env_type"`
-	CreateUserID uint           `gorm:"not null" json:"create_user_id"`
-	IsDel        int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
-	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProjectID    uint            `gorm:"not null;index:idx_project_id" json:"project_id"`
+	Name         string          `gorm:"type:varchar(128);not null" json:"name"`
+	EnvType      EnvType         `gorm:"type:tinyint(2);not null" json:"env_type"`
+	Routing      RoutingSettings `gorm:"type:json;serializer:json" json:"routing"`
+	Cache        CacheSettings   `gorm:"type:json;serializer:json" json:"cache"`
+	CreateUserID uint            `gorm:"not null" json:"create_user_id"`
+	IsDel        int8            `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
+	CreatedAt    time.Time       `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time       `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt  `gorm:"index" json:"-"`
 
 	// 关联
 	Project    Project            `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
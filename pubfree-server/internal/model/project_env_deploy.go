@@ -3,6 +3,8 @@ package model
 import (
 	"time"
 
+	"pubfree-platform/pubfree-server/pkg/rules"
+
 	"gorm.io/gorm"
 )
 
@@ -11,15 +13,27 @@ type ProjectEnvDeploy struct {
 	ProjectID    uint           `gorm:"not null;index:idx_project_id" json:"project_id"`
 	ProjectEnvID uint           `gorm:"not null;index:idx_project_env_id" json:"project_env_id"`
 	Remark       *string        `gorm:"type:varchar(255)" json:"remark"`
-	TargetType   int8           `gorm:"type:tinyint(2);not null" json:"target_type"`
+	TargetType   TargetType     `gorm:"type:tinyint(2);not null" json:"target_type"`
 	Target       string         `gorm:"type:varchar(512);not null" json:"target"`
-	CreateUserID uint           `gorm:"not null" json:"create_user_id"`
-	ActionUserID uint           `gorm:"not null" json:"action_user_id"`
-	IsActive     *int8          `gorm:"type:tinyint(2);default:0" json:"is_active"`
-	IsDel        int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
-	CreatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	Checksum     string         `gorm:"type:char(64);not null;default:''" json:"checksum"` // 产物压缩包的 sha256
+	Size         int64          `gorm:"not null;default:0" json:"size"`                    // 产物压缩包大小（字节）
+	FileCount    int            `gorm:"not null;default:0" json:"file_count"`
+	Status       string         `gorm:"type:varchar(16);not null;default:'pending'" json:"status"` // 见 deploy_status.go 中的状态机
+	FailReason   *string        `gorm:"type:varchar(512)" json:"fail_reason"`
+	Rules        *rules.RuleSet `gorm:"type:json;serializer:json" json:"rules"` // 产物中 _redirects 与 _headers 的解析结果
+	// LeaseExpiresAt 后台任务的租约到期时间，排队和处理期间由持有任务的实例定期续约，过期说明实例已退出
+	LeaseExpiresAt *time.Time `gorm:"default:null" json:"-"`
+	// SourceDeployID 从其他环境的部署复制而来时（如灰度发布到生产），文件沿用源部署的存储位置
+	SourceDeployID *uint `gorm:"default:null" json:"source_deploy_id"`
+	// ContentAddressed 文件按内容寻址存储，由文件清单指向内容；为 false 的旧部署文件位于 DeployPrefix 下
+	ContentAddressed bool           `gorm:"not null;default:false" json:"-"`
+	CreateUserID     uint           `gorm:"not null" json:"create_user_id"`
+	ActionUserID     uint           `gorm:"not null" json:"action_user_id"`
+	IsActive         *int8          `gorm:"type:tinyint(2);default:0" json:"is_active"`
+	IsDel            int8           `gorm:"type:tinyint(2);not null;default:0" json:"is_del"`
+	CreatedAt        time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt        time.Time      `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// 关联
 	Project    Project    `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
@@ -31,3 +45,11 @@ type ProjectEnvDeploy struct {
 func (ProjectEnvDeploy) TableName() string {
 	return "project_env_deploy"
 }
+
+// StorageDeployID 部署文件实际所属的部署ID
+func (d *ProjectEnvDeploy) StorageDeployID() uint {
+	if d.SourceDeployID != nil {
+		return *d.SourceDeployID
+	}
+	return d.ID
+}
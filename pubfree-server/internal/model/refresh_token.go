@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// RefreshToken 刷新令牌，同一次登录产生的令牌共享 SessionID，
+// 每次刷新都会轮换出新令牌并通过 ReplacedByID 串联
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint       `gorm:"not null;index:idx_user_id" json:"user_id"`
+	SessionID    string     `gorm:"type:varchar(64);not null;index:idx_session_id" json:"session_id"`
+	TokenHash    string     `gorm:"type:char(64);not null;uniqueIndex:uk_token_hash" json:"-"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	ReplacedByID *uint      `gorm:"default:null" json:"replaced_by_id"`
+	RevokedAt    *time.Time `gorm:"default:null" json:"revoked_at"`
+	CreatedAt    time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_token"
+}
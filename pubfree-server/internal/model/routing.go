@@ -0,0 +1,20 @@
+package model
+
+// 结尾斜杠策略
+const (
+	TrailingSlashAuto   = ""       // 目录地址缺少斜杠时重定向补上，其他保持原样
+	TrailingSlashAdd    = "add"    // 页面地址统一带斜杠，如 /about -> /about/
+	TrailingSlashRemove = "remove" // 页面地址统一去掉斜杠，如 /about/ -> /about
+)
+
+// RoutingSettings 环境的路由设置，由静态站点网关执行
+type RoutingSettings struct {
+	// SPAFallback 未匹配到文件的页面路径返回 /index.html，带扩展名的资源仍然返回404
+	SPAFallback bool `json:"spa_fallback"`
+	// TrailingSlash 结尾斜杠策略
+	TrailingSlash string `json:"trailing_slash"`
+	// CleanURLs /about 返回 /about.html，并将 /about.html 重定向到 /about
+	CleanURLs bool `json:"clean_urls"`
+	// NotFoundPage 产物中的自定义404页面，为空时使用 /404.html
+	NotFoundPage string `json:"not_found_page"`
+}
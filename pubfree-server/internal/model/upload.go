@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Upload tus 协议的断点续传上传，内容保存在上传目录中。
+// 上传完成后可用于创建部署，过期后连同内容一起被清理
+type Upload struct {
+	ID        string    `gorm:"type:char(32);primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index:idx_user_id" json:"user_id"`
+	Filename  string    `gorm:"type:varchar(255);not null;default:''" json:"filename"` // Upload-Metadata 中的 filename
+	Length    int64     `gorm:"not null" json:"length"`
+	Offset    int64     `gorm:"not null;default:0" json:"offset"` // 已接收的字节数
+	ExpiresAt time.Time `gorm:"not null;index:idx_expires_at" json:"expires_at"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+func (Upload) TableName() string {
+	return "upload"
+}
+
+// IsComplete 内容已全部接收
+func (u *Upload) IsComplete() bool {
+	return u.Offset >= u.Length
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type DeployActivationRepository interface {
+	// ListByProjectEnvID 按时间倒序返回环境的激活历史，limit 为0时返回全部
+	ListByProjectEnvID(ctx context.Context, projectEnvID uint, limit int) ([]*model.DeployActivation, error)
+}
+
+type deployActivationRepository struct {
+	db *gorm.DB
+}
+
+func NewDeployActivationRepository(db *gorm.DB) DeployActivationRepository {
+	return &deployActivationRepository{db: db}
+}
+
+func (r *deployActivationRepository) ListByProjectEnvID(ctx context.Context, projectEnvID uint, limit int) ([]*model.DeployActivation, error) {
+	var activations []*model.DeployActivation
+	query := r.db.WithContext(ctx).
+		Where("project_env_id = ?", projectEnvID).
+		Preload("Operator").
+		Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&activations).Error
+	return activations, err
+}
+
+type DeployLogRepository interface {
+	Create(ctx context.Context, log *model.DeployLog) error
+	// ListByDeployID 按顺序返回部署中 ID 大于 afterID 的日志，最多 limit 条
+	ListByDeployID(ctx context.Context, deployID, afterID uint, limit int) ([]*model.DeployLog, error)
+}
+
+type deployLogRepository struct {
+	db *gorm.DB
+}
+
+func NewDeployLogRepository(db *gorm.DB) DeployLogRepository {
+	return &deployLogRepository{db: db}
+}
+
+func (r *deployLogRepository) Create(ctx context.Context, log *model.DeployLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+func (r *deployLogRepository) ListByDeployID(ctx context.Context, deployID, afterID uint, limit int) ([]*model.DeployLog, error) {
+	var logs []*model.DeployLog
+	err := r.db.WithContext(ctx).
+		Where("deploy_id = ? AND id > ?", deployID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+type DeployFileRepository interface {
+	// CreateBatch 批量写入部署的文件清单
+	CreateBatch(ctx context.Context, files []*model.DeployFile) error
+	// ListByDeployID 按路径排序返回部署的文件清单
+	ListByDeployID(ctx context.Context, deployID uint) ([]*model.DeployFile, error)
+}
+
+type deployFileRepository struct {
+	db *gorm.DB
+}
+
+func NewDeployFileRepository(db *gorm.DB) DeployFileRepository {
+	return &deployFileRepository{db: db}
+}
+
+func (r *deployFileRepository) CreateBatch(ctx context.Context, files []*model.DeployFile) error {
+	if len(files) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(files, 500).Error
+}
+
+func (r *deployFileRepository) ListByDeployID(ctx context.Context, deployID uint) ([]*model.DeployFile, error) {
+	var files []*model.DeployFile
+	err := r.db.WithContext(ctx).
+		Where("deploy_id = ?", deployID).
+		Order("path ASC").
+		Find(&files).Error
+	return files, err
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+func createTestEnv(t *testing.T, db *gorm.DB) *model.ProjectEnv {
+	t.Helper()
+	env := &model.ProjectEnv{ProjectID: 1, Name: "prod", EnvType: model.EnvTypeProd, CreateUserID: 1}
+	if err := db.Create(env).Error; err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func createTestDeploy(t *testing.T, repo ProjectDeployRepository, env *model.ProjectEnv, status string) *model.ProjectEnvDeploy {
+	t.Helper()
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    env.ProjectID,
+		ProjectEnvID: env.ID,
+		Target:       "site.zip",
+		Status:       status,
+		CreateUserID: 1,
+		ActionUserID: 1,
+	}
+	if err := repo.Create(context.Background(), deploy); err != nil {
+		t.Fatal(err)
+	}
+	return deploy
+}
+
+// activeDeploys 返回环境中 is_active = 1 的部署ID
+func activeDeploys(t *testing.T, db *gorm.DB, envID uint) []uint {
+	t.Helper()
+	var ids []uint
+	if err := db.Model(&model.ProjectEnvDeploy{}).
+		Where("project_env_id = ? AND is_active = 1", envID).
+		Pluck("id", &ids).Error; err != nil {
+		t.Fatal(err)
+	}
+	return ids
+}
+
+func TestActivate(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repo := NewProjectDeployRepository(db)
+	env := createTestEnv(t, db)
+
+	first := createTestDeploy(t, repo, env, model.DeployStatusReady)
+	second := createTestDeploy(t, repo, env, model.DeployStatusReady)
+
+	if ok, err := repo.Activate(ctx, first, 1, model.ActivationActionActivate); err != nil || !ok {
+		t.Fatalf("激活第一个部署 ok = %v, err = %v", ok, err)
+	}
+	if ok, err := repo.Activate(ctx, second, 2, model.ActivationActionActivate); err != nil || !ok {
+		t.Fatalf("激活第二个部署 ok = %v, err = %v", ok, err)
+	}
+
+	if ids := activeDeploys(t, db, env.ID); len(ids) != 1 || ids[0] != second.ID {
+		t.Fatalf("激活的部署 = %v, 期望 [%d]", ids, second.ID)
+	}
+	previous, err := repo.GetByID(ctx, first.ID)
+	if err != nil || previous.Status != model.DeployStatusSuperseded {
+		t.Errorf("原先激活的部署状态 = %s, err = %v, 期望 superseded", previous.Status, err)
+	}
+	current, err := repo.GetByID(ctx, second.ID)
+	if err != nil || current.Status != model.DeployStatusActive || current.ActionUserID != 2 {
+		t.Errorf("激活的部署 status = %s, action_user_id = %d, err = %v", current.Status, current.ActionUserID, err)
+	}
+
+	transitions, err := repo.ListTransitions(ctx, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, transition := range transitions {
+		statuses = append(statuses, transition.FromStatus+"->"+transition.ToStatus)
+	}
+	if len(statuses) != 3 || statuses[1] != "ready->active" || statuses[2] != "active->superseded" {
+		t.Errorf("状态变更记录 = %v", statuses)
+	}
+
+	activations, err := NewDeployActivationRepository(db).ListByProjectEnvID(ctx, env.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(activations) != 2 {
+		t.Fatalf("激活记录 %d 条, 期望 2 条", len(activations))
+	}
+	latest := activations[0]
+	if latest.ToDeployID != second.ID || latest.FromDeployID == nil || *latest.FromDeployID != first.ID || latest.OperatorID != 2 {
+		t.Errorf("最新的激活记录 = %+v", latest)
+	}
+	if activations[1].FromDeployID != nil {
+		t.Errorf("首次激活不应有 FromDeployID: %+v", activations[1])
+	}
+
+	// 重复激活当前部署不产生新的记录
+	if ok, err := repo.Activate(ctx, second, 2, model.ActivationActionActivate); err != nil || !ok {
+		t.Fatalf("重复激活 ok = %v, err = %v", ok, err)
+	}
+	if activations, _ := NewDeployActivationRepository(db).ListByProjectEnvID(ctx, env.ID, 0); len(activations) != 2 {
+		t.Errorf("重复激活后激活记录 %d 条, 期望 2 条", len(activations))
+	}
+}
+
+func TestActivateRejectsUnavailableDeploy(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repo := NewProjectDeployRepository(db)
+	env := createTestEnv(t, db)
+	active := createTestDeploy(t, repo, env, model.DeployStatusReady)
+	if ok, err := repo.Activate(ctx, active, 1, model.ActivationActionActivate); err != nil || !ok {
+		t.Fatalf("ok = %v, err = %v", ok, err)
+	}
+
+	deleted := createTestDeploy(t, repo, env, model.DeployStatusReady)
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	otherEnv := createTestEnv(t, db)
+	wrongEnv := createTestDeploy(t, repo, otherEnv, model.DeployStatusReady)
+	wrongEnv.ProjectEnvID = env.ID
+
+	tests := []struct {
+		name   string
+		deploy *model.ProjectEnvDeploy
+	}{
+		{"处理中", createTestDeploy(t, repo, env, model.DeployStatusExtracting)},
+		{"失败", createTestDeploy(t, repo, env, model.DeployStatusFailed)},
+		{"已删除", deleted},
+		{"不属于该环境", wrongEnv},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := repo.Activate(ctx, tt.deploy, 1, model.ActivationActionActivate)
+			if err != nil || ok {
+				t.Errorf("ok = %v, err = %v, 期望不能激活", ok, err)
+			}
+			if ids := activeDeploys(t, db, env.ID); len(ids) != 1 || ids[0] != active.ID {
+				t.Errorf("激活的部署 = %v, 期望仍为 [%d]", ids, active.ID)
+			}
+		})
+	}
+}
+
+func TestActivateConcurrent(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repo := NewProjectDeployRepository(db)
+	env := createTestEnv(t, db)
+
+	deploys := make([]*model.ProjectEnvDeploy, 4)
+	for i := range deploys {
+		deploys[i] = createTestDeploy(t, repo, env, model.DeployStatusReady)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(deploy *model.ProjectEnvDeploy) {
+			defer wg.Done()
+			if ok, err := repo.Activate(ctx, deploy, 1, model.ActivationActionActivate); err != nil || !ok {
+				errs <- err
+			}
+		}(deploys[i%len(deploys)])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("并发激活失败: %v", err)
+	}
+
+	ids := activeDeploys(t, db, env.ID)
+	if len(ids) != 1 {
+		t.Fatalf("激活的部署 = %v, 期望只有一个", ids)
+	}
+
+	// 激活串行执行：每条记录的 FromDeployID 都是上一条记录激活的部署
+	activations, err := NewDeployActivationRepository(db).ListByProjectEnvID(ctx, env.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activations[0].ToDeployID != ids[0] {
+		t.Errorf("最新的激活记录指向 %d, 当前激活的部署为 %d", activations[0].ToDeployID, ids[0])
+	}
+	for i := 0; i+1 < len(activations); i++ {
+		from := activations[i].FromDeployID
+		if from == nil || *from != activations[i+1].ToDeployID {
+			t.Errorf("激活记录 %d 的 FromDeployID = %v, 期望 %d", activations[i].ID, from, activations[i+1].ToDeployID)
+		}
+	}
+	var active int64
+	db.Model(&model.ProjectEnvDeploy{}).
+		Where("project_env_id = ? AND status = ?", env.ID, model.DeployStatusActive).
+		Count(&active)
+	if active != 1 {
+		t.Errorf("状态为 active 的部署 %d 个, 期望 1 个", active)
+	}
+}
+
+func TestFailStaleUploading(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+	repo := NewProjectDeployRepository(db)
+	env := createTestEnv(t, db)
+
+	stale := createTestDeploy(t, repo, env, model.DeployStatusUploading)
+	touched := createTestDeploy(t, repo, env, model.DeployStatusUploading)
+	fresh := createTestDeploy(t, repo, env, model.DeployStatusUploading)
+	pending := createTestDeploy(t, repo, env, model.DeployStatusPending)
+	for _, deploy := range []*model.ProjectEnvDeploy{stale, touched, pending} {
+		if err := db.Model(deploy).UpdateColumn("updated_at", gorm.Expr("DATE_SUB(NOW(), INTERVAL 2 HOUR)")).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 仍在上传的部署刷新更新时间后不会过期
+	if err := repo.TouchUploading(ctx, touched.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := repo.FailStaleUploading(ctx, time.Hour, "expired")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].ID != stale.ID {
+		t.Fatalf("失败的部署 = %v, 期望只有 %d", failed, stale.ID)
+	}
+
+	want := map[uint]string{
+		stale.ID:   model.DeployStatusFailed,
+		touched.ID: model.DeployStatusUploading,
+		fresh.ID:   model.DeployStatusUploading,
+		pending.ID: model.DeployStatusPending,
+	}
+	for id, status := range want {
+		deploy, err := repo.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deploy.Status != status {
+			t.Errorf("部署 %d 状态 = %s, 期望 %s", id, deploy.Status, status)
+		}
+	}
+
+	// 已失败的部署不再处理
+	if failed, err := repo.FailStaleUploading(ctx, time.Hour, "expired"); err != nil || len(failed) != 0 {
+		t.Errorf("再次处理 = %v, %v", failed, err)
+	}
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type GrayRuleRepository interface {
+	// Save 创建或更新规则
+	Save(ctx context.Context, rule *model.GrayRule) error
+	GetByGrayEnvID(ctx context.Context, grayEnvID uint) (*model.GrayRule, error)
+	GetByProdEnvID(ctx context.Context, prodEnvID uint) (*model.GrayRule, error)
+}
+
+type grayRuleRepository struct {
+	db *gorm.DB
+}
+
+func NewGrayRuleRepository(db *gorm.DB) GrayRuleRepository {
+	return &grayRuleRepository{db: db}
+}
+
+func (r *grayRuleRepository) Save(ctx context.Context, rule *model.GrayRule) error {
+	return r.db.WithContext(ctx).Save(rule).Error
+}
+
+func (r *grayRuleRepository) GetByGrayEnvID(ctx context.Context, grayEnvID uint) (*model.GrayRule, error) {
+	var rule model.GrayRule
+	err := r.db.WithContext(ctx).
+		Where("gray_env_id = ?", grayEnvID).
+		First(&rule).Error
+	return &rule, err
+}
+
+func (r *grayRuleRepository) GetByProdEnvID(ctx context.Context, prodEnvID uint) (*model.GrayRule, error) {
+	var rule model.GrayRule
+	err := r.db.WithContext(ctx).
+		Where("prod_env_id = ?", prodEnvID).
+		First(&rule).Error
+	return &rule, err
+}
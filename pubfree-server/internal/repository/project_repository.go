@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	"pubfree-platform/pubfree-server/internal/model"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ProjectRepository interface {
@@ -12,6 +17,9 @@ type ProjectRepository interface {
 	GetByID(ctx context.Context, id uint) (*model.Project, error)
 	GetByName(ctx context.Context, name string) (*model.Project, error)
 	Update(ctx context.Context, project *model.Project) error
+	// Transfer 在同一事务中转移所有者：新所有者的成员角色变为 Owner，原所有者降为 Master，
+	// 原所有者不是成员时补一条成员记录。所有者已被并发修改时返回 false
+	Transfer(ctx context.Context, projectID, fromUserID, toUserID uint) (bool, error)
 	Delete(ctx context.Context, id uint) error
 	List(ctx context.Context, offset, limit int) ([]*model.Project, error)
 	ListByGroup(ctx context.Context, groupID uint, offset, limit int) ([]*model.Project, error)
@@ -57,6 +65,51 @@ func (r *projectRepository) Update(ctx context.Context, project *model.Project)
 	return r.db.WithContext(ctx).Save(project).Error
 }
 
+func (r *projectRepository) Transfer(ctx context.Context, projectID, fromUserID, toUserID uint) (bool, error) {
+	transferred := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 条件更新保证并发转移时只有一个请求生效
+		result := tx.Model(&model.Project{}).
+			Where("id = ? AND owner_id = ? AND is_del = 0", projectID, fromUserID).
+			Update("owner_id", toUserID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil
+		}
+
+		result = tx.Model(&model.ProjectMember{}).
+			Where("project_id = ? AND user_id = ? AND is_del = 0", projectID, toUserID).
+			Update("role", model.RoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		result = tx.Model(&model.ProjectMember{}).
+			Where("project_id = ? AND user_id = ? AND is_del = 0", projectID, fromUserID).
+			Update("role", model.RoleMaster)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := tx.Create(&model.ProjectMember{
+				ProjectID: projectID,
+				UserID:    fromUserID,
+				Role:      model.RoleMaster,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		transferred = true
+		return nil
+	})
+	return transferred, err
+}
+
 func (r *projectRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Model(&model.Project{}).Where("id = ?", id).Update("is_del", 1).Error
 }
@@ -110,8 +163,12 @@ func (r *projectRepository) Count(ctx context.Context) (int64, error) {
 type GroupMemberRepository interface {
 	Create(ctx context.Context, member *model.GroupMember) error
 	GetByID(ctx context.Context, id uint) (*model.GroupMember, error)
+	GetByGroupIDAndUserID(ctx context.Context, groupID, userID uint) (*model.GroupMember, error)
 	ListByGroupID(ctx context.Context, groupID uint) ([]*model.GroupMember, error)
+	Update(ctx context.Context, member *model.GroupMember) error
 	DeleteByGroupIDAndUserID(ctx context.Context, groupID, userID uint) error
+	// MigrateLegacyRoles 将角色改为位标记之前的取值转换为对应的角色，返回转换的记录数
+	MigrateLegacyRoles(ctx context.Context) (int64, error)
 }
 
 type groupMemberRepository struct {
@@ -135,6 +192,15 @@ func (r *groupMemberRepository) GetByID(ctx context.Context, id uint) (*model.Gr
 	return &member, err
 }
 
+func (r *groupMemberRepository) GetByGroupIDAndUserID(ctx context.Context, groupID, userID uint) (*model.GroupMember, error) {
+	var member model.GroupMember
+	err := r.db.WithContext(ctx).
+		Where("group_id = ? AND user_id = ? AND is_del = 0", groupID, userID).
+		Preload("User").
+		First(&member).Error
+	return &member, err
+}
+
 func (r *groupMemberRepository) ListByGroupID(ctx context.Context, groupID uint) ([]*model.GroupMember, error) {
 	var members []*model.GroupMember
 	err := r.db.WithContext(ctx).
@@ -144,6 +210,10 @@ func (r *groupMemberRepository) ListByGroupID(ctx context.Context, groupID uint)
 	return members, err
 }
 
+func (r *groupMemberRepository) Update(ctx context.Context, member *model.GroupMember) error {
+	return r.db.WithContext(ctx).Save(member).Error
+}
+
 func (r *groupMemberRepository) DeleteByGroupIDAndUserID(ctx context.Context, groupID, userID uint) error {
 	return r.db.WithContext(ctx).
 		Model(&model.GroupMember{}).
@@ -151,12 +221,41 @@ func (r *groupMemberRepository) DeleteByGroupIDAndUserID(ctx context.Context, gr
 		Update("is_del", 1).Error
 }
 
+func (r *groupMemberRepository) MigrateLegacyRoles(ctx context.Context) (int64, error) {
+	return migrateLegacyRoles(ctx, r.db, &model.GroupMember{})
+}
+
+// migrateLegacyRoles 逐个旧取值更新为新角色，新角色的取值均大于旧取值，重复执行不会再次转换。
+// 包括已删除的成员，恢复时角色仍然有效
+func migrateLegacyRoles(ctx context.Context, db *gorm.DB, member interface{}) (int64, error) {
+	var migrated int64
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for value := 1; value < int(model.RoleGuest); value++ {
+			role, ok := model.LegacyRole(value)
+			if !ok {
+				continue
+			}
+			result := tx.Unscoped().Model(member).Where("role = ?", value).Update("role", role)
+			if result.Error != nil {
+				return result.Error
+			}
+			migrated += result.RowsAffected
+		}
+		return nil
+	})
+	return migrated, err
+}
+
 // 项目成员Repository
 type ProjectMemberRepository interface {
 	Create(ctx context.Context, member *model.ProjectMember) error
 	GetByID(ctx context.Context, id uint) (*model.ProjectMember, error)
+	GetByProjectIDAndUserID(ctx context.Context, projectID, userID uint) (*model.ProjectMember, error)
 	ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectMember, error)
+	Update(ctx context.Context, member *model.ProjectMember) error
 	DeleteByProjectIDAndUserID(ctx context.Context, projectID, userID uint) error
+	// MigrateLegacyRoles 将角色改为位标记之前的取值转换为对应的角色，返回转换的记录数
+	MigrateLegacyRoles(ctx context.Context) (int64, error)
 }
 
 type projectMemberRepository struct {
@@ -180,6 +279,15 @@ func (r *projectMemberRepository) GetByID(ctx context.Context, id uint) (*model.
 	return &member, err
 }
 
+func (r *projectMemberRepository) GetByProjectIDAndUserID(ctx context.Context, projectID, userID uint) (*model.ProjectMember, error) {
+	var member model.ProjectMember
+	err := r.db.WithContext(ctx).
+		Where("project_id = ? AND user_id = ? AND is_del = 0", projectID, userID).
+		Preload("User").
+		First(&member).Error
+	return &member, err
+}
+
 func (r *projectMemberRepository) ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectMember, error) {
 	var members []*model.ProjectMember
 	err := r.db.WithContext(ctx).
@@ -189,6 +297,10 @@ func (r *projectMemberRepository) ListByProjectID(ctx context.Context, projectID
 	return members, err
 }
 
+func (r *projectMemberRepository) Update(ctx context.Context, member *model.ProjectMember) error {
+	return r.db.WithContext(ctx).Save(member).Error
+}
+
 func (r *projectMemberRepository) DeleteByProjectIDAndUserID(ctx context.Context, projectID, userID uint) error {
 	return r.db.WithContext(ctx).
 		Model(&model.ProjectMember{}).
@@ -196,11 +308,16 @@ func (r *projectMemberRepository) DeleteByProjectIDAndUserID(ctx context.Context
 		Update("is_del", 1).Error
 }
 
+func (r *projectMemberRepository) MigrateLegacyRoles(ctx context.Context) (int64, error) {
+	return migrateLegacyRoles(ctx, r.db, &model.ProjectMember{})
+}
+
 // 项目环境Repository
 type ProjectEnvRepository interface {
 	Create(ctx context.Context, env *model.ProjectEnv) error
 	GetByID(ctx context.Context, id uint) (*model.ProjectEnv, error)
 	ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectEnv, error)
+	Update(ctx context.Context, env *model.ProjectEnv) error
 	Delete(ctx context.Context, id uint) error
 }
 
@@ -234,6 +351,10 @@ func (r *projectEnvRepository) ListByProjectID(ctx context.Context, projectID ui
 	return envs, err
 }
 
+func (r *projectEnvRepository) Update(ctx context.Context, env *model.ProjectEnv) error {
+	return r.db.WithContext(ctx).Save(env).Error
+}
+
 func (r *projectEnvRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Model(&model.ProjectEnv{}).Where("id = ?", id).Update("is_del", 1).Error
 }
@@ -242,6 +363,7 @@ func (r *projectEnvRepository) Delete(ctx context.Context, id uint) error {
 type ProjectDomainRepository interface {
 	Create(ctx context.Context, domain *model.ProjectDomain) error
 	GetByID(ctx context.Context, id uint) (*model.ProjectDomain, error)
+	GetByHost(ctx context.Context, host string) (*model.ProjectDomain, error)
 	ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectDomain, error)
 	Delete(ctx context.Context, id uint) error
 }
@@ -266,6 +388,14 @@ func (r *projectDomainRepository) GetByID(ctx context.Context, id uint) (*model.
 	return &domain, err
 }
 
+func (r *projectDomainRepository) GetByHost(ctx context.Context, host string) (*model.ProjectDomain, error) {
+	var domain model.ProjectDomain
+	err := r.db.WithContext(ctx).
+		Where("host = ? AND is_del = 0", host).
+		First(&domain).Error
+	return &domain, err
+}
+
 func (r *projectDomainRepository) ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectDomain, error) {
 	var domains []*model.ProjectDomain
 	err := r.db.WithContext(ctx).
@@ -280,9 +410,30 @@ func (r *projectDomainRepository) Delete(ctx context.Context, id uint) error {
 
 // 项目部署Repository
 type ProjectDeployRepository interface {
+	// Create 创建部署并记录初始状态
 	Create(ctx context.Context, deploy *model.ProjectEnvDeploy) error
 	GetByID(ctx context.Context, id uint) (*model.ProjectEnvDeploy, error)
 	ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectEnvDeploy, error)
+	// GetActiveByEnvID 获取环境当前激活的部署
+	GetActiveByEnvID(ctx context.Context, projectEnvID uint) (*model.ProjectEnvDeploy, error)
+	// UpdateArtifact 更新产物的校验和、大小、文件数、规则和存储方式
+	UpdateArtifact(ctx context.Context, deploy *model.ProjectEnvDeploy) error
+	// Transition 切换部署状态并记录状态变更，reason 为失败原因。
+	// 当前状态不允许切换时返回 model.ErrInvalidDeployTransition，成功后更新 deploy.Status
+	Transition(ctx context.Context, deploy *model.ProjectEnvDeploy, to string, reason string) error
+	ListTransitions(ctx context.Context, deployID uint) ([]*model.DeployTransition, error)
+	// RenewLease 将处理中部署的租约延长到 ttl 之后，部署已处理结束时不做任何修改
+	RenewLease(ctx context.Context, id uint, ttl time.Duration) error
+	// FailExpired 将租约已过期的处理中部署标记为失败，返回处理的数量。
+	// 没有租约的部署（如续约前实例就已退出）在最后一次更新的 ttl 之后视为过期
+	FailExpired(ctx context.Context, ttl time.Duration, reason string) (int, error)
+	// FailStaleUploading 将超过 ttl 未更新的等待上传部署标记为失败，返回这些部署
+	FailStaleUploading(ctx context.Context, ttl time.Duration, reason string) ([]*model.ProjectEnvDeploy, error)
+	// TouchUploading 更新等待上传部署的更新时间，部署已提交或失败时不做任何修改
+	TouchUploading(ctx context.Context, id uint) error
+	// Activate 激活部署，同一环境中原先激活的部署变为 superseded，同时记录激活历史。
+	// 部署已删除或未就绪时返回 false
+	Activate(ctx context.Context, deploy *model.ProjectEnvDeploy, userID uint, action string) (bool, error)
 	Delete(ctx context.Context, id uint) error
 }
 
@@ -295,7 +446,12 @@ func NewProjectDeployRepository(db *gorm.DB) ProjectDeployRepository {
 }
 
 func (r *projectDeployRepository) Create(ctx context.Context, deploy *model.ProjectEnvDeploy) error {
-	return r.db.WithContext(ctx).Create(deploy).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(deploy).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.DeployTransition{DeployID: deploy.ID, ToStatus: deploy.Status}).Error
+	})
 }
 
 func (r *projectDeployRepository) GetByID(ctx context.Context, id uint) (*model.ProjectEnvDeploy, error) {
@@ -314,6 +470,208 @@ func (r *projectDeployRepository) ListByProjectID(ctx context.Context, projectID
 	return deploys, err
 }
 
+func (r *projectDeployRepository) GetActiveByEnvID(ctx context.Context, projectEnvID uint) (*model.ProjectEnvDeploy, error) {
+	var deploy model.ProjectEnvDeploy
+	err := r.db.WithContext(ctx).
+		Where("project_env_id = ? AND is_active = 1 AND is_del = 0", projectEnvID).
+		First(&deploy).Error
+	return &deploy, err
+}
+
+func (r *projectDeployRepository) UpdateArtifact(ctx context.Context, deploy *model.ProjectEnvDeploy) error {
+	return r.db.WithContext(ctx).
+		Model(deploy).
+		Select("checksum", "size", "file_count", "rules", "content_addressed").
+		Updates(deploy).Error
+}
+
+func (r *projectDeployRepository) Transition(ctx context.Context, deploy *model.ProjectEnvDeploy, to string, reason string) error {
+	return r.transition(ctx, deploy, to, reason, nil)
+}
+
+// transition 切换部署状态，scope 为锁定部署时附加的条件，不满足时返回 gorm.ErrRecordNotFound
+func (r *projectDeployRepository) transition(ctx context.Context, deploy *model.ProjectEnvDeploy, to string, reason string, scope func(*gorm.DB) *gorm.DB) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status")
+		if scope != nil {
+			query = scope(query)
+		}
+		var current model.ProjectEnvDeploy
+		if err := query.First(&current, deploy.ID).Error; err != nil {
+			return err
+		}
+		if !model.CanTransitDeploy(current.Status, to) {
+			return fmt.Errorf("%w: %s -> %s", model.ErrInvalidDeployTransition, current.Status, to)
+		}
+
+		var failReason *string
+		if reason != "" {
+			failReason = &reason
+		}
+		if err := tx.Model(&model.ProjectEnvDeploy{}).
+			Where("id = ?", deploy.ID).
+			Updates(map[string]interface{}{"status": to, "fail_reason": failReason}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.DeployTransition{
+			DeployID:   deploy.ID,
+			FromStatus: current.Status,
+			ToStatus:   to,
+			Reason:     failReason,
+		}).Error; err != nil {
+			return err
+		}
+
+		deploy.Status = to
+		deploy.FailReason = failReason
+		return nil
+	})
+}
+
+func (r *projectDeployRepository) ListTransitions(ctx context.Context, deployID uint) ([]*model.DeployTransition, error) {
+	var transitions []*model.DeployTransition
+	err := r.db.WithContext(ctx).
+		Where("deploy_id = ?", deployID).
+		Order("id ASC").
+		Find(&transitions).Error
+	return transitions, err
+}
+
+func (r *projectDeployRepository) RenewLease(ctx context.Context, id uint, ttl time.Duration) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ProjectEnvDeploy{}).
+		Where("id = ? AND status IN ?", id, model.DeployStatusesInProgress).
+		UpdateColumn("lease_expires_at", gorm.Expr("DATE_ADD(NOW(), INTERVAL ? SECOND)", int(ttl.Seconds()))).Error
+}
+
+func (r *projectDeployRepository) FailExpired(ctx context.Context, ttl time.Duration, reason string) (int, error) {
+	// 租约时间由数据库生成，与数据库时间比较，不受各实例时钟偏差的影响
+	failed, err := r.failWhere(ctx, reason, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status IN ?", model.DeployStatusesInProgress).
+			Where("lease_expires_at < NOW() OR (lease_expires_at IS NULL AND updated_at < DATE_SUB(NOW(), INTERVAL ? SECOND))", int(ttl.Seconds()))
+	})
+	return len(failed), err
+}
+
+func (r *projectDeployRepository) FailStaleUploading(ctx context.Context, ttl time.Duration, reason string) ([]*model.ProjectEnvDeploy, error) {
+	return r.failWhere(ctx, reason, func(db *gorm.DB) *gorm.DB {
+		return db.Where("status = ? AND updated_at < DATE_SUB(NOW(), INTERVAL ? SECOND)", model.DeployStatusUploading, int(ttl.Seconds()))
+	})
+}
+
+// failWhere 将满足 scope 的部署逐个标记为失败，返回已处理的部署
+func (r *projectDeployRepository) failWhere(ctx context.Context, reason string, scope func(*gorm.DB) *gorm.DB) ([]*model.ProjectEnvDeploy, error) {
+	var deploys []*model.ProjectEnvDeploy
+	if err := scope(r.db.WithContext(ctx).Select("id", "status")).Find(&deploys).Error; err != nil {
+		return nil, err
+	}
+
+	failed := make([]*model.ProjectEnvDeploy, 0, len(deploys))
+	for _, deploy := range deploys {
+		// 锁定后再次检查条件，查询后可能已被续约或处理结束
+		err := r.transition(ctx, deploy, model.DeployStatusFailed, reason, scope)
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, model.ErrInvalidDeployTransition) {
+			continue
+		}
+		if err != nil {
+			return failed, err
+		}
+		failed = append(failed, deploy)
+	}
+	return failed, nil
+}
+
+func (r *projectDeployRepository) TouchUploading(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ProjectEnvDeploy{}).
+		Where("id = ? AND status = ?", id, model.DeployStatusUploading).
+		UpdateColumn("updated_at", gorm.Expr("NOW()")).Error
+}
+
+func (r *projectDeployRepository) Activate(ctx context.Context, deploy *model.ProjectEnvDeploy, userID uint, action string) (bool, error) {
+	activated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁定环境行，同一环境的激活操作串行执行，保证最多只有一个激活部署
+		var env model.ProjectEnv
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&env, deploy.ProjectEnvID).Error; err != nil {
+			return err
+		}
+
+		// 加锁后重新读取部署，确认仍可激活
+		var target model.ProjectEnvDeploy
+		err := tx.Where("id = ? AND project_env_id = ? AND is_del = 0", deploy.ID, deploy.ProjectEnvID).
+			First(&target).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !model.IsDeployable(target.Status) {
+			return nil
+		}
+
+		activated = true
+		if target.IsActive != nil && *target.IsActive == 1 {
+			// 本来就是激活状态，无需切换
+			return nil
+		}
+
+		var previous []*model.ProjectEnvDeploy
+		if err := tx.Select("id", "status").
+			Where("project_env_id = ? AND is_active = 1", deploy.ProjectEnvID).
+			Find(&previous).Error; err != nil {
+			return err
+		}
+
+		activation := &model.DeployActivation{
+			ProjectID:    deploy.ProjectID,
+			ProjectEnvID: deploy.ProjectEnvID,
+			ToDeployID:   deploy.ID,
+			Action:       action,
+			OperatorID:   userID,
+		}
+		if len(previous) > 0 {
+			ids := make([]uint, 0, len(previous))
+			for _, p := range previous {
+				ids = append(ids, p.ID)
+			}
+			if err := tx.Model(&model.ProjectEnvDeploy{}).
+				Where("id IN ?", ids).
+				Updates(map[string]interface{}{"is_active": 0, "status": model.DeployStatusSuperseded}).Error; err != nil {
+				return err
+			}
+			for _, p := range previous {
+				if err := tx.Create(&model.DeployTransition{
+					DeployID:   p.ID,
+					FromStatus: p.Status,
+					ToStatus:   model.DeployStatusSuperseded,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			activation.FromDeployID = &previous[0].ID
+		}
+
+		if err := tx.Model(&model.ProjectEnvDeploy{}).
+			Where("id = ?", deploy.ID).
+			Updates(map[string]interface{}{"is_active": 1, "status": model.DeployStatusActive, "action_user_id": userID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&model.DeployTransition{
+			DeployID:   deploy.ID,
+			FromStatus: target.Status,
+			ToStatus:   model.DeployStatusActive,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(activation).Error
+	})
+	return activated, err
+}
+
 func (r *projectDeployRepository) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Model(&model.ProjectEnvDeploy{}).Where("id = ?", id).Update("is_del", 1).Error
 }
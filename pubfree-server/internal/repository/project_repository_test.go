@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+)
+
+func TestMigrateLegacyRoles(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t)
+
+	members := []*model.ProjectMember{
+		{ProjectID: 1, UserID: 1, Role: 1},
+		{ProjectID: 1, UserID: 2, Role: 3},
+		{ProjectID: 1, UserID: 3, Role: 10},
+		{ProjectID: 1, UserID: 4, Role: model.RoleGuest},
+		{ProjectID: 1, UserID: 5, Role: model.RoleMaster},
+		{ProjectID: 1, UserID: 6, Role: 1, IsDel: 1},
+	}
+	for _, member := range members {
+		if err := db.Create(member).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	groupMember := &model.GroupMember{GroupID: 1, UserID: 1, Role: 1}
+	if err := db.Create(groupMember).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := NewProjectMemberRepository(db).MigrateLegacyRoles(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated != 4 {
+		t.Errorf("转换了 %d 个项目成员, 期望 4", migrated)
+	}
+	want := []model.Role{model.RoleOwner, model.RoleDeveloper, model.RoleDeveloper, model.RoleGuest, model.RoleMaster, model.RoleOwner}
+	for i, member := range members {
+		var got model.ProjectMember
+		if err := db.Unscoped().First(&got, member.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != want[i] {
+			t.Errorf("用户 %d 的角色 = %v, 期望 %v", member.UserID, got.Role, want[i])
+		}
+	}
+
+	if migrated, err := NewGroupMemberRepository(db).MigrateLegacyRoles(ctx); err != nil || migrated != 1 {
+		t.Errorf("转换了 %d 个空间成员, err = %v", migrated, err)
+	}
+	var got model.GroupMember
+	if err := db.First(&got, groupMember.ID).Error; err != nil || got.Role != model.RoleOwner {
+		t.Errorf("空间成员角色 = %v, err = %v", got.Role, err)
+	}
+
+	// 重复执行不再转换
+	if migrated, err := NewProjectMemberRepository(db).MigrateLegacyRoles(ctx); err != nil || migrated != 0 {
+		t.Errorf("重复执行转换了 %d 个成员, err = %v", migrated, err)
+	}
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"os"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var testModels = []interface{}{
+	&model.User{},
+	&model.Group{},
+	&model.GroupMember{},
+	&model.Project{},
+	&model.ProjectMember{},
+	&model.ProjectEnv{},
+	&model.ProjectDomain{},
+	&model.ProjectEnvDeploy{},
+	&model.RefreshToken{},
+	&model.ApiToken{},
+	&model.DeployActivation{},
+	&model.GrayRule{},
+	&model.DeployTransition{},
+	&model.DeployLog{},
+	&model.DeployFile{},
+	&model.Upload{},
+}
+
+// testDB 连接 PUBFREE_TEST_MYSQL_DSN 指定的 MySQL 数据库，未设置时跳过测试。
+// 每个测试开始前清空全部表，须指向专用的测试数据库
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("PUBFREE_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("未设置 PUBFREE_TEST_MYSQL_DSN")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		// 测试直接写入子表，不需要先创建关联的记录
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(testModels...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	for _, m := range testModels {
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(m).Error; err != nil {
+			t.Fatalf("清空测试数据失败: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
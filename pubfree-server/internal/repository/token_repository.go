@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"pubfree-platform/pubfree-server/internal/model"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+var errRotateConflict = errors.New("refresh token already rotated")
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// Rotate 将旧令牌标记为已被替换并写入新令牌，旧令牌已被使用或吊销时返回 false
+	Rotate(ctx context.Context, oldID uint, next *model.RefreshToken) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	// RevokeByUserID 吊销用户全部未吊销的令牌，返回受影响的会话ID
+	RevokeByUserID(ctx context.Context, userID uint) ([]string, error)
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *model.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	return &token, err
+}
+
+func (r *refreshTokenRepository) Rotate(ctx context.Context, oldID uint, next *model.RefreshToken) (bool, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		// 条件更新保证同一个刷新令牌只能被使用一次
+		result := tx.Model(&model.RefreshToken{}).
+			Where("id = ? AND replaced_by_id IS NULL AND revoked_at IS NULL", oldID).
+			Update("replaced_by_id", next.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errRotateConflict
+		}
+		return nil
+	})
+	if errors.Is(err, errRotateConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *refreshTokenRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	return r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *refreshTokenRepository) RevokeByUserID(ctx context.Context, userID uint) ([]string, error) {
+	var sessionIDs []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Distinct().
+			Pluck("session_id", &sessionIDs).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&model.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error
+	})
+	return sessionIDs, err
+}
+
+func (r *refreshTokenRepository) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NOT NULL", sessionID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SessionRevocationCache 会话吊销状态缓存，用于在每次请求时快速校验访问令牌。
+// 已吊销和未吊销都会缓存，未命中时由调用方查询数据库后回填
+type SessionRevocationCache interface {
+	Set(ctx context.Context, sessionID string, revoked bool, ttl time.Duration) error
+	// Get 读取缓存的吊销状态，found 为 false 表示未命中
+	Get(ctx context.Context, sessionID string) (revoked, found bool, err error)
+}
+
+type redisSessionRevocationCache struct {
+	client *redis.Client
+}
+
+func NewRedisSessionRevocationCache(client *redis.Client) SessionRevocationCache {
+	return &redisSessionRevocationCache{client: client}
+}
+
+func (c *redisSessionRevocationCache) key(sessionID string) string {
+	return "pubfree:revoked_session:" + sessionID
+}
+
+func (c *redisSessionRevocationCache) Set(ctx context.Context, sessionID string, revoked bool, ttl time.Duration) error {
+	value := "0"
+	if revoked {
+		value = "1"
+	}
+	return c.client.Set(ctx, c.key(sessionID), value, ttl).Err()
+}
+
+func (c *redisSessionRevocationCache) Get(ctx context.Context, sessionID string) (bool, bool, error) {
+	value, err := c.client.Get(ctx, c.key(sessionID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return value == "1", true, nil
+}
+
+// API令牌Repository
+type ApiTokenRepository interface {
+	Create(ctx context.Context, token *model.ApiToken) error
+	GetByID(ctx context.Context, id uint) (*model.ApiToken, error)
+	GetByHash(ctx context.Context, tokenHash string) (*model.ApiToken, error)
+	ListByUserID(ctx context.Context, userID uint, kind string) ([]*model.ApiToken, error)
+	ListByProjectID(ctx context.Context, projectID uint, kind string) ([]*model.ApiToken, error)
+	Revoke(ctx context.Context, id uint) error
+	TouchLastUsed(ctx context.Context, id uint, usedAt time.Time) error
+}
+
+type apiTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewApiTokenRepository(db *gorm.DB) ApiTokenRepository {
+	return &apiTokenRepository{db: db}
+}
+
+func (r *apiTokenRepository) Create(ctx context.Context, token *model.ApiToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *apiTokenRepository) GetByID(ctx context.Context, id uint) (*model.ApiToken, error) {
+	var token model.ApiToken
+	err := r.db.WithContext(ctx).
+		Where("is_del = 0").
+		First(&token, id).Error
+	return &token, err
+}
+
+func (r *apiTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*model.ApiToken, error) {
+	var token model.ApiToken
+	err := r.db.WithContext(ctx).
+		Where("token_hash = ? AND is_del = 0", tokenHash).
+		Preload("User").
+		First(&token).Error
+	return &token, err
+}
+
+func (r *apiTokenRepository) ListByUserID(ctx context.Context, userID uint, kind string) ([]*model.ApiToken, error) {
+	var tokens []*model.ApiToken
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND kind = ? AND is_del = 0", userID, kind).
+		Order("id DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *apiTokenRepository) ListByProjectID(ctx context.Context, projectID uint, kind string) ([]*model.ApiToken, error) {
+	var tokens []*model.ApiToken
+	err := r.db.WithContext(ctx).
+		Where("project_id = ? AND kind = ? AND is_del = 0", projectID, kind).
+		Order("id DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *apiTokenRepository) Revoke(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ApiToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *apiTokenRepository) TouchLastUsed(ctx context.Context, id uint, usedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.ApiToken{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error
+}
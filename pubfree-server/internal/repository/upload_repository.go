@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/model"
+
+	"gorm.io/gorm"
+)
+
+type UploadRepository interface {
+	Create(ctx context.Context, upload *model.Upload) error
+	GetByID(ctx context.Context, id string) (*model.Upload, error)
+	// UpdateProgress 更新已接收的字节数和过期时间
+	UpdateProgress(ctx context.Context, upload *model.Upload) error
+	Delete(ctx context.Context, id string) error
+	// ListExpired 返回在 before 之前过期的上传，最多 limit 条
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]*model.Upload, error)
+}
+
+type uploadRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadRepository(db *gorm.DB) UploadRepository {
+	return &uploadRepository{db: db}
+}
+
+func (r *uploadRepository) Create(ctx context.Context, upload *model.Upload) error {
+	return r.db.WithContext(ctx).Create(upload).Error
+}
+
+func (r *uploadRepository) GetByID(ctx context.Context, id string) (*model.Upload, error) {
+	var upload model.Upload
+	err := r.db.WithContext(ctx).
+		Where("id = ?", id).
+		First(&upload).Error
+	return &upload, err
+}
+
+func (r *uploadRepository) UpdateProgress(ctx context.Context, upload *model.Upload) error {
+	return r.db.WithContext(ctx).
+		Model(upload).
+		Select("offset", "expires_at").
+		Updates(upload).Error
+}
+
+func (r *uploadRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).
+		Where("id = ?", id).
+		Delete(&model.Upload{}).Error
+}
+
+func (r *uploadRepository) ListExpired(ctx context.Context, before time.Time, limit int) ([]*model.Upload, error) {
+	var uploads []*model.Upload
+	err := r.db.WithContext(ctx).
+		Where("expires_at < ?", before).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&uploads).Error
+	return uploads, err
+}
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupApiTokenRoutes 令牌管理只允许通过登录会话操作，避免令牌自我扩散
+func SetupApiTokenRoutes(r *gin.RouterGroup, apiTokenHandler *handler.ApiTokenHandler, permissionService service.PermissionService) {
+	tokenGroup := r.Group("/tokens", middleware.SessionOnly())
+	{
+		tokenGroup.POST("", apiTokenHandler.CreateUserToken)
+		tokenGroup.GET("", apiTokenHandler.ListUserTokens)
+		tokenGroup.DELETE("/:tokenId", apiTokenHandler.RevokeUserToken)
+	}
+
+	deployTokenGroup := r.Group("/projects/:id/deploy-tokens",
+		middleware.SessionOnly(),
+		middleware.ProjectPermission(permissionService, service.ActionManageTokens),
+	)
+	{
+		deployTokenGroup.POST("", apiTokenHandler.CreateDeployToken)
+		deployTokenGroup.GET("", apiTokenHandler.ListDeployTokens)
+		deployTokenGroup.DELETE("/:tokenId", apiTokenHandler.RevokeDeployToken)
+	}
+}
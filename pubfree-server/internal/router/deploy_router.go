@@ -0,0 +1,43 @@
+package router
+
+import (
+	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupDeployRoutes(r *gin.RouterGroup, deployHandler *handler.DeployHandler, permissionService service.PermissionService) {
+	can := func(action service.Action) gin.HandlerFunc {
+		return middleware.ProjectPermission(permissionService, action)
+	}
+
+	projectGroup := r.Group("/projects/:id")
+	{
+		// 通过远程地址创建部署
+		projectGroup.POST("/deploys", can(service.ActionDeploy), deployHandler.CreateDeploy)
+		projectGroup.GET("/deploys/:deployId", can(service.ActionView), deployHandler.GetDeploy)
+		projectGroup.GET("/deploys/:deployId/logs", can(service.ActionView), deployHandler.GetDeployLogs)
+		projectGroup.GET("/deploys/:deployId/files", can(service.ActionView), deployHandler.ListDeployFiles)
+		projectGroup.GET("/deploys/:deployId/diff/:targetId", can(service.ActionView), deployHandler.DiffDeploys)
+		projectGroup.POST("/deploys/:deployId/activate", can(service.ActionDeploy), deployHandler.ActivateDeploy)
+
+		// 增量上传：查询缺少的文件内容、逐个上传、全部上传后提交
+		projectGroup.GET("/deploys/:deployId/missing", can(service.ActionDeploy), deployHandler.GetMissingBlobs)
+		projectGroup.PUT("/deploys/:deployId/blobs/:checksum", can(service.ActionDeploy), deployHandler.UploadBlob)
+		projectGroup.POST("/deploys/:deployId/finalize", can(service.ActionDeploy), deployHandler.FinalizeManifestDeploy)
+	}
+
+	envGroup := r.Group("/projects/:id/envs/:envId")
+	{
+		// 部署产物上传
+		envGroup.POST("/deploys/upload", can(service.ActionDeploy), deployHandler.UploadArtifact)
+		envGroup.POST("/deploys/manifest", can(service.ActionDeploy), deployHandler.CreateManifestDeploy)
+		envGroup.POST("/builds", can(service.ActionDeploy), deployHandler.CreateBuild)
+
+		// 回滚与激活历史
+		envGroup.POST("/rollback", can(service.ActionDeploy), deployHandler.RollbackEnv)
+		envGroup.GET("/activations", can(service.ActionView), deployHandler.ListActivations)
+	}
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"net/http"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/gateway"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+// SetupGateway 初始化静态站点网关，按 Host 返回对应环境当前激活的部署
+func SetupGateway(db *gorm.DB, store storage.Storage, cfg *config.Config) http.Handler {
+	projectRepo := repository.NewProjectRepository(db)
+	projectDomainRepo := repository.NewProjectDomainRepository(db)
+	projectEnvRepo := repository.NewProjectEnvRepository(db)
+	projectDeployRepo := repository.NewProjectDeployRepository(db)
+	grayRuleRepo := repository.NewGrayRuleRepository(db)
+	deployFileRepo := repository.NewDeployFileRepository(db)
+
+	siteService := service.NewSiteService(projectRepo, projectDomainRepo, projectEnvRepo, projectDeployRepo, grayRuleRepo, deployFileRepo, cfg.Gateway.Preview.BaseDomain)
+
+	return gateway.New(siteService, store, cfg.Gateway.CacheTTL)
+}
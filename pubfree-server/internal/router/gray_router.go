@@ -0,0 +1,26 @@
+package router
+
+import (
+	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetupGrayRoutes(r *gin.RouterGroup, grayHandler *handler.GrayHandler, permissionService service.PermissionService) {
+	can := func(action service.Action) gin.HandlerFunc {
+		return middleware.ProjectPermission(permissionService, action)
+	}
+
+	// :envId 为灰度环境
+	grayGroup := r.Group("/projects/:id/envs/:envId/gray")
+	{
+		grayGroup.GET("", can(service.ActionView), grayHandler.GetGrayRule)
+		grayGroup.PUT("", can(service.ActionManageEnv), grayHandler.SaveGrayRule)
+
+		// 逐步放量与全量发布
+		grayGroup.PUT("/percentage", can(service.ActionDeploy), grayHandler.UpdateGrayPercentage)
+		grayGroup.POST("/promote", can(service.ActionDeploy), grayHandler.PromoteGray)
+	}
+}
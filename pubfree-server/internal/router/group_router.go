@@ -2,22 +2,29 @@ package router
 
 import (
 	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupGroupRoutes(r *gin.RouterGroup, groupHandler *handler.GroupHandler) {
+func SetupGroupRoutes(r *gin.RouterGroup, groupHandler *handler.GroupHandler, permissionService service.PermissionService) {
+	can := func(action service.Action) gin.HandlerFunc {
+		return middleware.GroupPermission(permissionService, action)
+	}
+
 	groupGroup := r.Group("/groups")
 	{
-		groupGroup.POST("", groupHandler.CreateGroup)
-		groupGroup.GET("/:id", groupHandler.GetGroup)
-		groupGroup.PUT("/:id", groupHandler.UpdateGroup)
-		groupGroup.DELETE("/:id", groupHandler.DeleteGroup)
-		groupGroup.GET("", groupHandler.ListGroups)
+		groupGroup.POST("", middleware.RequireScope(model.ApiTokenScopeWrite), groupHandler.CreateGroup)
+		groupGroup.GET("/:id", can(service.ActionView), groupHandler.GetGroup)
+		groupGroup.PUT("/:id", can(service.ActionUpdate), groupHandler.UpdateGroup)
+		groupGroup.DELETE("/:id", can(service.ActionDelete), groupHandler.DeleteGroup)
+		groupGroup.GET("", middleware.RequireScope(model.ApiTokenScopeRead), groupHandler.ListGroups)
 
 		// 空间成员管理
-		groupGroup.GET("/:id/members", groupHandler.GetGroupMembers)
-		groupGroup.POST("/:id/members", groupHandler.AddGroupMember)
-		groupGroup.DELETE("/:id/members/:user_id", groupHandler.RemoveGroupMember)
+		groupGroup.GET("/:id/members", can(service.ActionView), groupHandler.GetGroupMembers)
+		groupGroup.POST("/:id/members", can(service.ActionManageMembers), groupHandler.AddGroupMember)
+		groupGroup.DELETE("/:id/members/:user_id", can(service.ActionManageMembers), groupHandler.RemoveGroupMember)
 	}
 }
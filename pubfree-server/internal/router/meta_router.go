@@ -0,0 +1,15 @@
+package router
+
+import (
+	"pubfree-platform/pubfree-server/internal/handler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupMetaRoutes 元数据路由，无需登录即可访问
+func SetupMetaRoutes(r *gin.RouterGroup, metaHandler *handler.MetaHandler) {
+	metaGroup := r.Group("/meta")
+	{
+		metaGroup.GET("/enums", metaHandler.GetEnums)
+	}
+}
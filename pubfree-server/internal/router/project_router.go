@@ -2,35 +2,46 @@ package router
 
 import (
 	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupProjectRoutes(r *gin.RouterGroup, projectHandler *handler.ProjectHandler) {
+func SetupProjectRoutes(r *gin.RouterGroup, projectHandler *handler.ProjectHandler, permissionService service.PermissionService) {
+	can := func(action service.Action) gin.HandlerFunc {
+		return middleware.ProjectPermission(permissionService, action)
+	}
+
 	projectGroup := r.Group("/projects")
 	{
-		projectGroup.POST("", projectHandler.CreateProject)
-		projectGroup.GET("/:id", projectHandler.GetProject)
-		projectGroup.PUT("/:id", projectHandler.UpdateProject)
-		projectGroup.DELETE("/:id", projectHandler.DeleteProject)
-		projectGroup.GET("", projectHandler.ListProjects)
+		projectGroup.POST("", middleware.RequireScope(model.ApiTokenScopeWrite), projectHandler.CreateProject)
+		projectGroup.GET("/:id", can(service.ActionView), projectHandler.GetProject)
+		projectGroup.PUT("/:id", can(service.ActionUpdate), projectHandler.UpdateProject)
+		projectGroup.DELETE("/:id", can(service.ActionDelete), projectHandler.DeleteProject)
+		projectGroup.GET("", middleware.RequireScope(model.ApiTokenScopeRead), projectHandler.ListProjects)
+		projectGroup.POST("/:id/transfer", can(service.ActionTransfer), projectHandler.TransferProject)
+		// 构建命令在服务器上执行，只允许平台管理员修改
+		projectGroup.PUT("/:id/build", middleware.PlatformAdmin(permissionService), projectHandler.UpdateProjectBuild)
 
 		// 项目成员管理
-		projectGroup.GET("/:id/members", projectHandler.GetProjectMembers)
-		projectGroup.POST("/:id/members", projectHandler.AddProjectMember)
-		projectGroup.DELETE("/:id/members/:user_id", projectHandler.RemoveProjectMember)
+		projectGroup.GET("/:id/members", can(service.ActionView), projectHandler.GetProjectMembers)
+		projectGroup.POST("/:id/members", can(service.ActionManageMembers), projectHandler.AddProjectMember)
+		projectGroup.DELETE("/:id/members/:user_id", can(service.ActionManageMembers), projectHandler.RemoveProjectMember)
 
 		// 项目环境管理
-		projectGroup.POST("/:id/envs", projectHandler.CreateProjectEnv)
-		projectGroup.GET("/:id/envs", projectHandler.GetProjectEnvs)
+		projectGroup.POST("/:id/envs", can(service.ActionManageEnv), projectHandler.CreateProjectEnv)
+		projectGroup.GET("/:id/envs", can(service.ActionView), projectHandler.GetProjectEnvs)
+		projectGroup.PUT("/:id/envs/:envId/routing", can(service.ActionManageEnv), projectHandler.UpdateProjectEnvRouting)
+		projectGroup.PUT("/:id/envs/:envId/cache", can(service.ActionManageEnv), projectHandler.UpdateProjectEnvCache)
 
 		// 项目域名管理
-		projectGroup.POST("/:id/domains", projectHandler.CreateProjectDomain)
-		projectGroup.GET("/:id/domains", projectHandler.GetProjectDomains)
+		projectGroup.POST("/:id/domains", can(service.ActionManageEnv), projectHandler.CreateProjectDomain)
+		projectGroup.GET("/:id/domains", can(service.ActionView), projectHandler.GetProjectDomains)
 
 		// 项目部署管理
-		projectGroup.POST("/:id/deploys", projectHandler.CreateProjectDeploy)
-		projectGroup.GET("/:id/deploys", projectHandler.GetProjectDeploys)
+		projectGroup.GET("/:id/deploys", can(service.ActionView), projectHandler.GetProjectDeploys)
 
 	}
 }
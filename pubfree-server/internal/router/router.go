@@ -1,16 +1,33 @@
 package router
 
 import (
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/request"
 	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
 	"pubfree-platform/pubfree-server/internal/repository"
 	"pubfree-platform/pubfree-server/internal/service"
+	"pubfree-platform/pubfree-server/pkg/builder"
+	"pubfree-platform/pubfree-server/pkg/logger"
+	"pubfree-platform/pubfree-server/pkg/storage"
+	"pubfree-platform/pubfree-server/pkg/workerpool"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	r := gin.Default()
+// SetupRouter 初始化路由，rdb 为 nil 时不使用Redis缓存，pool 用于执行部署的后台任务
+func SetupRouter(db *gorm.DB, rdb *redis.Client, store storage.Storage, pool *workerpool.Pool, cfg *config.Config) *gin.Engine {
+	r := gin.New()
+
+	// 中间件须在注册路由之前添加，否则不会作用于已注册的路由
+	r.Use(logger.GinLogger(), logger.GinRecovery(), middleware.CORS())
+
+	// 注册自定义参数校验
+	if err := request.RegisterValidators(); err != nil {
+		panic(err)
+	}
 
 	// 初始化repositories
 	userRepo := repository.NewUserRepository(db)
@@ -21,24 +38,82 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 	projectEnvRepo := repository.NewProjectEnvRepository(db)
 	projectDomainRepo := repository.NewProjectDomainRepository(db)
 	projectDeployRepo := repository.NewProjectDeployRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	apiTokenRepo := repository.NewApiTokenRepository(db)
+	activationRepo := repository.NewDeployActivationRepository(db)
+	grayRuleRepo := repository.NewGrayRuleRepository(db)
+	deployLogRepo := repository.NewDeployLogRepository(db)
+	deployFileRepo := repository.NewDeployFileRepository(db)
+	uploadRepo := repository.NewUploadRepository(db)
+
+	var revocationCache repository.SessionRevocationCache
+	if rdb != nil {
+		revocationCache = repository.NewRedisSessionRevocationCache(rdb)
+	}
 
 	// 初始化services
-	userService := service.NewUserService(userRepo)
-	groupService := service.NewGroupService(groupRepo, groupMemberRepo)
-	projectService := service.NewProjectService(projectRepo, projectMemberRepo, projectEnvRepo, projectDomainRepo, projectDeployRepo)
+	tokenService := service.NewTokenService(userRepo, refreshTokenRepo, revocationCache, cfg.JWT)
+	apiTokenService := service.NewApiTokenService(apiTokenRepo, projectEnvRepo)
+	userService := service.NewUserService(userRepo, tokenService)
+	permissionService := service.NewPermissionService(projectRepo, projectMemberRepo, groupRepo, groupMemberRepo, cfg.App.Admins)
+	groupService := service.NewGroupService(groupRepo, groupMemberRepo, permissionService)
+	previewService := service.NewPreviewService(projectRepo, cfg.Gateway.Preview)
+	projectService := service.NewProjectService(projectRepo, projectMemberRepo, projectEnvRepo, projectDomainRepo, projectDeployRepo, permissionService, previewService)
+	uploadService := service.NewUploadService(uploadRepo, cfg.Upload, cfg.Deploy.MaxArtifactMB<<20)
+	deployService := service.NewDeployService(projectRepo, projectEnvRepo, projectDeployRepo, activationRepo, deployLogRepo, deployFileRepo, previewService, uploadService, store, pool, newBuilder(cfg.Build), cfg.Deploy)
+	grayService := service.NewGrayService(grayRuleRepo, projectEnvRepo, projectDeployRepo, previewService)
 
 	// 初始化handlers
 	userHandler := handler.NewUserHandler(userService)
 	groupHandler := handler.NewGroupHandler(groupService)
 	projectHandler := handler.NewProjectHandler(projectService)
+	apiTokenHandler := handler.NewApiTokenHandler(apiTokenService)
+	metaHandler := handler.NewMetaHandler()
+	deployHandler := handler.NewDeployHandler(deployService, cfg.Deploy.MaxArtifactMB<<20)
+	grayHandler := handler.NewGrayHandler(grayService)
+	uploadHandler := handler.NewUploadHandler(uploadService)
 
 	// 设置路由
+	auth := middleware.Auth(tokenService, apiTokenService)
 	api := r.Group("/api/v1")
 	{
-		SetupUserRoutes(api, userHandler)
-		SetupGroupRoutes(api, groupHandler)
-		SetupProjectRoutes(api, projectHandler)
+		SetupAuthRoutes(api, userHandler)
+		SetupMetaRoutes(api, metaHandler)
+		SetupUploadRoutes(api, uploadHandler, auth)
+	}
+
+	// 以下路由均需要登录
+	authorized := api.Group("", auth)
+	{
+		SetupUserRoutes(authorized, userHandler)
+		SetupGroupRoutes(authorized, groupHandler, permissionService)
+		SetupProjectRoutes(authorized, projectHandler, permissionService)
+		SetupApiTokenRoutes(authorized, apiTokenHandler, permissionService)
+		SetupDeployRoutes(authorized, deployHandler, permissionService)
+		SetupGrayRoutes(authorized, grayHandler, permissionService)
 	}
 
 	return r
 }
+
+// newBuilder 未开启构建时返回 nil，构建用户配置有误时不支持构建
+func newBuilder(cfg config.BuildConfig) *builder.Runner {
+	if !cfg.Enabled {
+		return nil
+	}
+	runner, err := builder.New(builder.Options{
+		WorkDir:        cfg.WorkDir,
+		Timeout:        cfg.Timeout,
+		MaxMemoryMB:    cfg.MaxMemoryMB,
+		MaxFileMB:      cfg.MaxFileMB,
+		MaxLogLines:    cfg.MaxLogLines,
+		AllowLocalRepo: cfg.AllowLocalRepo,
+		User:           cfg.User,
+		RunAsServer:    cfg.RunAsServer,
+	})
+	if err != nil {
+		logger.Logger.Errorf("构建初始化失败，将不支持从代码仓库构建: %v", err)
+		return nil
+	}
+	return runner
+}
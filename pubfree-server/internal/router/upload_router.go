@@ -0,0 +1,22 @@
+package router
+
+import (
+	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetupUploadRoutes tus 1.0 断点续传接口，上传完成后通过 upload_id 创建部署。
+// OPTIONS 用于客户端探测服务端能力，按 tus 协议无需认证
+func SetupUploadRoutes(r *gin.RouterGroup, uploadHandler *handler.UploadHandler, auth gin.HandlerFunc) {
+	r.OPTIONS("/uploads", uploadHandler.Options)
+
+	uploadGroup := r.Group("/uploads", auth, middleware.RequireDeployScope())
+	{
+		uploadGroup.POST("", uploadHandler.CreateUpload)
+		uploadGroup.HEAD("/:uploadId", uploadHandler.GetUploadOffset)
+		uploadGroup.PATCH("/:uploadId", uploadHandler.WriteUpload)
+		uploadGroup.DELETE("/:uploadId", uploadHandler.DeleteUpload)
+	}
+}
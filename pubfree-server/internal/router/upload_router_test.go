@@ -0,0 +1,190 @@
+package router
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestUploadOptionsWithoutAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.CORS())
+	denied := func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) }
+	uploadHandler := handler.NewUploadHandler(service.NewUploadService(nil, config.UploadConfig{}, 1<<20))
+	SetupUploadRoutes(r.Group("/api/v1"), uploadHandler, denied)
+
+	tests := []struct {
+		method string
+		want   int
+	}{
+		{http.MethodOptions, http.StatusNoContent},
+		{http.MethodPost, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/uploads", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			req.Header.Set("Tus-Resumable", "1.0.0")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("状态码 = %d, 期望 %d", w.Code, tt.want)
+			}
+			if tt.method == http.MethodOptions && w.Header().Get("Tus-Max-Size") != "1048576" {
+				t.Errorf("OPTIONS 应返回 tus 能力, Tus-Max-Size = %q", w.Header().Get("Tus-Max-Size"))
+			}
+		})
+	}
+}
+
+// memUploadRepo 内存中的上传记录
+type memUploadRepo struct {
+	repository.UploadRepository
+
+	mu      sync.Mutex
+	uploads map[string]*model.Upload
+}
+
+func (r *memUploadRepo) Create(ctx context.Context, upload *model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved := *upload
+	r.uploads[upload.ID] = &saved
+	return nil
+}
+
+func (r *memUploadRepo) GetByID(ctx context.Context, id string) (*model.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *upload
+	return &found, nil
+}
+
+func (r *memUploadRepo) UpdateProgress(ctx context.Context, upload *model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads[upload.ID].Offset = upload.Offset
+	r.uploads[upload.ID].ExpiresAt = upload.ExpiresAt
+	return nil
+}
+
+func (r *memUploadRepo) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uploads, id)
+	return nil
+}
+
+func TestUploadProtocol(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	repo := &memUploadRepo{uploads: make(map[string]*model.Upload)}
+	uploadService := service.NewUploadService(repo, config.UploadConfig{Dir: t.TempDir(), Expiration: time.Hour}, 10)
+	auth := func(c *gin.Context) { c.Set(middleware.ContextUserIDKey, uint(1)) }
+	SetupUploadRoutes(r.Group("/api/v1"), handler.NewUploadHandler(uploadService), auth)
+
+	do := func(method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		for name, value := range headers {
+			if value == "" {
+				req.Header.Del(name)
+			} else {
+				req.Header.Set(name, value)
+			}
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Header().Get("Tus-Resumable") != "1.0.0" {
+			t.Errorf("%s %s 缺少 Tus-Resumable 响应头", method, target)
+		}
+		return w
+	}
+	expect := func(w *httptest.ResponseRecorder, status int, step string) {
+		t.Helper()
+		if w.Code != status {
+			t.Fatalf("%s: 状态码 = %d, 期望 %d (body %s)", step, w.Code, status, w.Body.String())
+		}
+	}
+
+	expect(do(http.MethodPost, "/api/v1/uploads", map[string]string{"Tus-Resumable": "", "Upload-Length": "10"}, ""),
+		http.StatusPreconditionFailed, "缺少协议版本")
+	expect(do(http.MethodPost, "/api/v1/uploads", map[string]string{"Upload-Length": "11"}, ""),
+		http.StatusRequestEntityTooLarge, "超过大小限制")
+	expect(do(http.MethodPost, "/api/v1/uploads", map[string]string{"Upload-Length": "-1"}, ""),
+		http.StatusBadRequest, "无效的大小")
+	expect(do(http.MethodPost, "/api/v1/uploads", map[string]string{"Upload-Defer-Length": "1"}, ""),
+		http.StatusBadRequest, "延迟指定大小")
+
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("site.zip")) + ",is_confidential"
+	w := do(http.MethodPost, "/api/v1/uploads", map[string]string{"Upload-Length": "10", "Upload-Metadata": metadata}, "")
+	expect(w, http.StatusCreated, "创建上传")
+	location := w.Header().Get("Location")
+	id := strings.TrimPrefix(location, "/api/v1/uploads/")
+	if id == location || repo.uploads[id] == nil || repo.uploads[id].Filename != "site.zip" {
+		t.Fatalf("Location = %q, 上传 = %+v", location, repo.uploads[id])
+	}
+	if w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Expires") == "" {
+		t.Errorf("创建上传的响应头 = %v", w.Header())
+	}
+
+	w = do(http.MethodHead, location, nil, "")
+	expect(w, http.StatusOK, "查询进度")
+	if w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Length") != "10" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("查询进度的响应头 = %v", w.Header())
+	}
+
+	patch := func(offset, body string) *httptest.ResponseRecorder {
+		return do(http.MethodPatch, location, map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": offset}, body)
+	}
+	expect(do(http.MethodPatch, location, map[string]string{"Content-Type": "application/octet-stream", "Upload-Offset": "0"}, "abcd"),
+		http.StatusUnsupportedMediaType, "错误的 Content-Type")
+	expect(patch("x", "abcd"), http.StatusBadRequest, "无效的偏移量")
+	expect(patch("5", "abcd"), http.StatusConflict, "偏移量不一致")
+
+	w = patch("0", "abcd")
+	expect(w, http.StatusNoContent, "写入")
+	if w.Header().Get("Upload-Offset") != "4" {
+		t.Errorf("写入后 Upload-Offset = %q, 期望 4", w.Header().Get("Upload-Offset"))
+	}
+	expect(patch("0", "abcd"), http.StatusConflict, "重复写入")
+
+	w = patch("4", "efghij")
+	expect(w, http.StatusNoContent, "完成写入")
+	if w.Header().Get("Upload-Offset") != "10" || w.Header().Get("Upload-Expires") != "" {
+		t.Errorf("完成后的响应头 = %v", w.Header())
+	}
+
+	// 过期的上传不能继续访问
+	repo.uploads[id].ExpiresAt = time.Now().Add(-time.Minute)
+	expect(do(http.MethodHead, location, nil, ""), http.StatusGone, "上传已过期")
+	expect(patch("10", "k"), http.StatusGone, "写入过期的上传")
+
+	expect(do(http.MethodHead, "/api/v1/uploads/missing", nil, ""), http.StatusNotFound, "上传不存在")
+
+	w = do(http.MethodPost, "/api/v1/uploads", map[string]string{"Upload-Length": "1"}, "")
+	expect(w, http.StatusCreated, "创建上传")
+	expect(do(http.MethodDelete, w.Header().Get("Location"), nil, ""), http.StatusNoContent, "终止上传")
+	expect(do(http.MethodHead, w.Header().Get("Location"), nil, ""), http.StatusNotFound, "终止后查询")
+}
@@ -2,23 +2,36 @@ package router
 
 import (
 	"pubfree-platform/pubfree-server/internal/handler"
+	"pubfree-platform/pubfree-server/internal/middleware"
+	"pubfree-platform/pubfree-server/internal/model"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SetupUserRoutes(r *gin.RouterGroup, userHandler *handler.UserHandler) {
+	read := middleware.RequireScope(model.ApiTokenScopeRead)
+	write := middleware.RequireScope(model.ApiTokenScopeWrite)
+
 	userGroup := r.Group("/users")
 	{
-		userGroup.POST("", userHandler.CreateUser)
-		userGroup.GET("/:id", userHandler.GetUser)
-		userGroup.PUT("/:id", userHandler.UpdateUser)
-		userGroup.DELETE("/:id", userHandler.DeleteUser)
-		userGroup.GET("", userHandler.ListUsers)
+		userGroup.POST("", write, userHandler.CreateUser)
+		userGroup.GET("/:id", read, userHandler.GetUser)
+		userGroup.PUT("/:id", middleware.SessionOnly(), userHandler.UpdateUser)
+		userGroup.DELETE("/:id", middleware.SessionOnly(), userHandler.DeleteUser)
+		userGroup.GET("", read, userHandler.ListUsers)
+
+		// 会话管理
+		userGroup.DELETE("/:id/sessions", middleware.SessionOnly(), userHandler.RevokeSessions)
 	}
+}
 
-	// 认证相关路由
+// SetupAuthRoutes 认证相关路由，无需登录即可访问
+func SetupAuthRoutes(r *gin.RouterGroup, userHandler *handler.UserHandler) {
 	authGroup := r.Group("/auth")
 	{
 		authGroup.POST("/login", userHandler.Login)
+		authGroup.POST("/register", userHandler.CreateUser)
+		authGroup.POST("/refresh", userHandler.RefreshToken)
+		authGroup.POST("/logout", userHandler.Logout)
 	}
 }
@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 明文令牌前缀，便于识别令牌类型以及被密钥扫描工具发现
+	userTokenPrefix   = "pft_"
+	deployTokenPrefix = "pfd_"
+
+	// 最近使用时间的更新间隔，避免每次请求都写库
+	tokenTouchInterval = time.Minute
+)
+
+var (
+	ErrApiTokenNotFound = errors.New("令牌不存在")
+	ErrInvalidApiToken  = errors.New("API令牌无效、已吊销或已过期")
+)
+
+type ApiTokenService interface {
+	// 个人访问令牌
+	CreateUserToken(ctx context.Context, userID uint, req *request.CreateApiTokenRequest) (*response.ApiTokenCreatedResponse, error)
+	ListUserTokens(ctx context.Context, userID uint) ([]*response.ApiTokenResponse, error)
+	RevokeUserToken(ctx context.Context, userID, tokenID uint) error
+
+	// 项目部署令牌
+	CreateDeployToken(ctx context.Context, projectID, userID uint, req *request.CreateDeployTokenRequest) (*response.ApiTokenCreatedResponse, error)
+	ListDeployTokens(ctx context.Context, projectID uint) ([]*response.ApiTokenResponse, error)
+	RevokeDeployToken(ctx context.Context, projectID, tokenID uint) error
+
+	// Authenticate 校验明文令牌并返回令牌记录
+	Authenticate(ctx context.Context, token string) (*model.ApiToken, error)
+}
+
+type apiTokenService struct {
+	apiTokenRepo   repository.ApiTokenRepository
+	projectEnvRepo repository.ProjectEnvRepository
+}
+
+func NewApiTokenService(apiTokenRepo repository.ApiTokenRepository, projectEnvRepo repository.ProjectEnvRepository) ApiTokenService {
+	return &apiTokenService{
+		apiTokenRepo:   apiTokenRepo,
+		projectEnvRepo: projectEnvRepo,
+	}
+}
+
+// IsApiToken 根据前缀判断是否为API令牌（而非JWT访问令牌）
+func IsApiToken(token string) bool {
+	return strings.HasPrefix(token, userTokenPrefix) || strings.HasPrefix(token, deployTokenPrefix)
+}
+
+func (s *apiTokenService) CreateUserToken(ctx context.Context, userID uint, req *request.CreateApiTokenRequest) (*response.ApiTokenCreatedResponse, error) {
+	token := &model.ApiToken{
+		Name:      req.Name,
+		Kind:      model.ApiTokenKindUser,
+		Scopes:    normalizeScopes(req.Scopes),
+		UserID:    userID,
+		ExpiresAt: expiresAfterDays(req.ExpiresIn),
+	}
+
+	return s.create(ctx, token, userTokenPrefix)
+}
+
+func (s *apiTokenService) ListUserTokens(ctx context.Context, userID uint) ([]*response.ApiTokenResponse, error) {
+	tokens, err := s.apiTokenRepo.ListByUserID(ctx, userID, model.ApiTokenKindUser)
+	if err != nil {
+		return nil, err
+	}
+	return s.modelsToResponse(tokens), nil
+}
+
+func (s *apiTokenService) RevokeUserToken(ctx context.Context, userID, tokenID uint) error {
+	token, err := s.apiTokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrApiTokenNotFound
+		}
+		return err
+	}
+	if token.Kind != model.ApiTokenKindUser || token.UserID != userID {
+		return ErrApiTokenNotFound
+	}
+
+	return s.apiTokenRepo.Revoke(ctx, tokenID)
+}
+
+func (s *apiTokenService) CreateDeployToken(ctx context.Context, projectID, userID uint, req *request.CreateDeployTokenRequest) (*response.ApiTokenCreatedResponse, error) {
+	if req.ProjectEnvID != nil {
+		env, err := s.projectEnvRepo.GetByID(ctx, *req.ProjectEnvID)
+		if err != nil || env.ProjectID != projectID {
+			return nil, errors.New("环境不存在或不属于该项目")
+		}
+	}
+
+	token := &model.ApiToken{
+		Name:         req.Name,
+		Kind:         model.ApiTokenKindDeploy,
+		Scopes:       normalizeScopes([]string{model.ApiTokenScopeRead, model.ApiTokenScopeDeploy}),
+		UserID:       userID,
+		ProjectID:    &projectID,
+		ProjectEnvID: req.ProjectEnvID,
+		ExpiresAt:    expiresAfterDays(req.ExpiresIn),
+	}
+
+	return s.create(ctx, token, deployTokenPrefix)
+}
+
+func (s *apiTokenService) ListDeployTokens(ctx context.Context, projectID uint) ([]*response.ApiTokenResponse, error) {
+	tokens, err := s.apiTokenRepo.ListByProjectID(ctx, projectID, model.ApiTokenKindDeploy)
+	if err != nil {
+		return nil, err
+	}
+	return s.modelsToResponse(tokens), nil
+}
+
+func (s *apiTokenService) RevokeDeployToken(ctx context.Context, projectID, tokenID uint) error {
+	token, err := s.apiTokenRepo.GetByID(ctx, tokenID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrApiTokenNotFound
+		}
+		return err
+	}
+	if token.Kind != model.ApiTokenKindDeploy || token.ProjectID == nil || *token.ProjectID != projectID {
+		return ErrApiTokenNotFound
+	}
+
+	return s.apiTokenRepo.Revoke(ctx, tokenID)
+}
+
+func (s *apiTokenService) Authenticate(ctx context.Context, plain string) (*model.ApiToken, error) {
+	token, err := s.apiTokenRepo.GetByHash(ctx, utils.HashToken(plain))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidApiToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !token.IsValid(now) || token.User.ID == 0 || token.User.IsDel != 0 {
+		return nil, ErrInvalidApiToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > tokenTouchInterval {
+		_ = s.apiTokenRepo.TouchLastUsed(ctx, token.ID, now)
+	}
+
+	return token, nil
+}
+
+func (s *apiTokenService) create(ctx context.Context, token *model.ApiToken, prefix string) (*response.ApiTokenCreatedResponse, error) {
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := prefix + secret
+
+	token.TokenHash = utils.HashToken(plain)
+	token.TokenPrefix = plain[:len(prefix)+8]
+
+	if err := s.apiTokenRepo.Create(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &response.ApiTokenCreatedResponse{
+		ApiTokenResponse: *s.modelToResponse(token),
+		Token:            plain,
+	}, nil
+}
+
+func (s *apiTokenService) modelsToResponse(tokens []*model.ApiToken) []*response.ApiTokenResponse {
+	var responses []*response.ApiTokenResponse
+	for _, token := range tokens {
+		responses = append(responses, s.modelToResponse(token))
+	}
+	return responses
+}
+
+func (s *apiTokenService) modelToResponse(token *model.ApiToken) *response.ApiTokenResponse {
+	return &response.ApiTokenResponse{
+		ID:           token.ID,
+		Name:         token.Name,
+		Kind:         token.Kind,
+		TokenPrefix:  token.TokenPrefix,
+		Scopes:       token.ScopeList(),
+		UserID:       token.UserID,
+		ProjectID:    token.ProjectID,
+		ProjectEnvID: token.ProjectEnvID,
+		ExpiresAt:    token.ExpiresAt,
+		LastUsedAt:   token.LastUsedAt,
+		RevokedAt:    token.RevokedAt,
+		CreatedAt:    token.CreatedAt,
+	}
+}
+
+// normalizeScopes 去重后以逗号拼接
+func normalizeScopes(scopes []string) string {
+	seen := make(map[string]bool, len(scopes))
+	var result []string
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+func expiresAfterDays(days int) *time.Time {
+	if days <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().AddDate(0, 0, days)
+	return &expiresAt
+}
@@ -0,0 +1,1467 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/archive"
+	"pubfree-platform/pubfree-server/pkg/builder"
+	"pubfree-platform/pubfree-server/pkg/compress"
+	"pubfree-platform/pubfree-server/pkg/logger"
+	"pubfree-platform/pubfree-server/pkg/rules"
+	"pubfree-platform/pubfree-server/pkg/storage"
+	"pubfree-platform/pubfree-server/pkg/workerpool"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEnvNotFound      = errors.New("环境不存在或不属于该项目")
+	ErrInvalidArtifact  = errors.New("无效的部署产物")
+	ErrArtifactTooLarge = errors.New("部署产物超过大小限制")
+	ErrDeployNotFound   = errors.New("部署不存在")
+	ErrDeployNotReady   = errors.New("部署尚未就绪，无法激活")
+	ErrNoRollbackTarget = errors.New("没有可回滚的部署")
+	ErrDeployBusy       = errors.New("部署任务过多，请稍后重试")
+	ErrBuildDisabled    = errors.New("服务未开启代码仓库构建")
+	ErrBuildNotSet      = errors.New("项目未配置构建")
+	ErrNoManifest       = errors.New("部署没有文件清单")
+	ErrNotUploading     = errors.New("部署不在等待上传状态")
+	ErrBlobsMissing     = errors.New("文件内容尚未全部上传")
+)
+
+// reasonShutdown 服务关闭导致部署任务被中断时记录的失败原因
+const reasonShutdown = "服务关闭，部署任务被中断"
+
+// ReasonLeaseExpired 处理部署的实例退出、租约过期时记录的失败原因
+const ReasonLeaseExpired = "处理部署的服务已退出，部署任务被中断"
+
+// ReasonUploadExpired 增量上传的部署超过有效期未提交时记录的失败原因
+const ReasonUploadExpired = "增量上传超过有效期未提交，部署已失效"
+
+// rollbackHistoryLimit 查找回滚目标时最多回溯的激活记录数
+const rollbackHistoryLimit = 100
+
+// logPageSize 单次读取部署日志的最大条数
+const logPageSize = 500
+
+// maxPathLength 部署中文件路径的长度上限，与 deploy_file.path 的长度一致
+const maxPathLength = 512
+
+type DeployService interface {
+	// CreateDeploy 通过远程地址或已完成的上传创建部署，产物由后台任务下载、解压，部署记录的状态随之更新
+	CreateDeploy(ctx context.Context, projectID, userID uint, req *request.CreateProjectDeployRequest) (*response.ProjectDeployResponse, error)
+	// UploadArtifact 接收 zip 或 tar.gz 压缩包并创建部署记录，解压由后台任务完成
+	UploadArtifact(ctx context.Context, projectID, envID, userID uint, req *request.UploadDeployRequest, file io.Reader) (*response.ProjectDeployResponse, error)
+	// CreateBuild 按项目的构建配置克隆代码并执行构建，构建产物与上传的压缩包一样解压、校验
+	CreateBuild(ctx context.Context, projectID, envID, userID uint, req *request.CreateBuildRequest) (*response.ProjectDeployResponse, error)
+	// CreateManifestDeploy 增量上传：按客户端提交的文件清单创建部署，返回服务端缺少的文件内容
+	CreateManifestDeploy(ctx context.Context, projectID, envID, userID uint, req *request.CreateManifestDeployRequest) (*response.ManifestDeployResponse, error)
+	// GetMissingBlobs 获取增量上传的部署仍需上传的文件内容，用于中断后继续上传
+	GetMissingBlobs(ctx context.Context, projectID, deployID uint) (*response.ManifestDeployResponse, error)
+	// UploadBlob 上传一份缺少的文件内容，内容须与清单中的 sha256 和大小一致
+	UploadBlob(ctx context.Context, projectID, deployID uint, checksum string, r io.Reader) error
+	// FinalizeManifestDeploy 文件内容全部上传后提交部署，校验和规则解析由后台任务完成
+	FinalizeManifestDeploy(ctx context.Context, projectID, deployID uint) (*response.ProjectDeployResponse, error)
+	// ExpireUploading 将最后一次上传后超过 ttl 仍未提交的增量上传部署标记为失败，并删除其文件清单，返回处理的数量
+	ExpireUploading(ctx context.Context, ttl time.Duration) (int, error)
+	// GetDeploy 获取部署及其状态变更记录
+	GetDeploy(ctx context.Context, projectID, deployID uint) (*response.ProjectDeployResponse, error)
+	// ActivateDeploy 激活部署，同一环境中原先激活的部署会被取消激活
+	ActivateDeploy(ctx context.Context, projectID, deployID, userID uint) (*response.ProjectDeployResponse, error)
+	// RollbackEnv 回滚环境，未指定部署时回到上一个激活的部署
+	RollbackEnv(ctx context.Context, projectID, envID, userID uint, req *request.RollbackEnvRequest) (*response.ProjectDeployResponse, error)
+	ListActivations(ctx context.Context, projectID, envID uint) ([]*response.DeployActivationResponse, error)
+	// ListFiles 获取部署的文件清单
+	ListFiles(ctx context.Context, projectID, deployID uint) ([]*response.DeployFileResponse, error)
+	// DiffDeploys 比较两个部署的文件清单，列出从 fromID 切换到 toID 时新增、删除和修改的文件
+	DiffDeploys(ctx context.Context, projectID, fromID, toID uint) (*response.DeployDiffResponse, error)
+	// ListLogs 获取部署中 ID 大于 afterID 的日志。finished 为 true 时部署已处理结束，不会再有新日志
+	ListLogs(ctx context.Context, projectID, deployID, afterID uint) (logs []*response.DeployLogResponse, finished bool, err error)
+}
+
+type deployService struct {
+	projectRepo       repository.ProjectRepository
+	projectEnvRepo    repository.ProjectEnvRepository
+	projectDeployRepo repository.ProjectDeployRepository
+	activationRepo    repository.DeployActivationRepository
+	deployLogRepo     repository.DeployLogRepository
+	deployFileRepo    repository.DeployFileRepository
+	previewService    PreviewService
+	uploadService     UploadService
+	storage           storage.Storage
+	pool              *workerpool.Pool
+	builder           *builder.Runner
+	config            config.DeployConfig
+	httpClient        *http.Client
+}
+
+// NewDeployService builder 为 nil 时不支持从代码仓库构建
+func NewDeployService(
+	projectRepo repository.ProjectRepository,
+	projectEnvRepo repository.ProjectEnvRepository,
+	projectDeployRepo repository.ProjectDeployRepository,
+	activationRepo repository.DeployActivationRepository,
+	deployLogRepo repository.DeployLogRepository,
+	deployFileRepo repository.DeployFileRepository,
+	previewService PreviewService,
+	uploadService UploadService,
+	store storage.Storage,
+	pool *workerpool.Pool,
+	runner *builder.Runner,
+	deployConfig config.DeployConfig,
+) DeployService {
+	return &deployService{
+		projectRepo:       projectRepo,
+		projectEnvRepo:    projectEnvRepo,
+		projectDeployRepo: projectDeployRepo,
+		activationRepo:    activationRepo,
+		deployLogRepo:     deployLogRepo,
+		deployFileRepo:    deployFileRepo,
+		previewService:    previewService,
+		uploadService:     uploadService,
+		storage:           store,
+		pool:              pool,
+		builder:           runner,
+		config:            deployConfig,
+		httpClient:        newDownloadClient(deployConfig.DownloadTimeout, deployConfig.AllowPrivateNetworks),
+	}
+}
+
+// BlobKey 文件内容在存储中的键，按 sha256 寻址，相同内容的文件在所有部署和项目间只保存一份
+func BlobKey(checksum string) string {
+	return storage.Join("blobs", "sha256", checksum[:2], checksum)
+}
+
+// EncodedBlobKey 文件内容预压缩版本在存储中的键，与原内容放在一起，同样在部署间共享
+func EncodedBlobKey(checksum, encoding string) string {
+	return BlobKey(checksum) + compress.Ext(encoding)
+}
+
+// pendingManifestKey 增量上传的部署在提交前，客户端文件清单在存储中的键
+func pendingManifestKey(deployID uint) string {
+	return storage.Join("manifests", strconv.FormatUint(uint64(deployID), 10)+".json")
+}
+
+// DeployPrefix 旧版部署文件在存储中的键前缀。引入按内容寻址的存储之前，
+// 每个部署的文件单独保存在该前缀下，没有文件清单
+func DeployPrefix(projectID, deployID uint) string {
+	return storage.Join("deploys", strconv.FormatUint(uint64(projectID), 10), strconv.FormatUint(uint64(deployID), 10)) + "/"
+}
+
+// artifact 已落盘的部署产物
+type artifact struct {
+	file      *os.File
+	size      int64
+	checksum  string
+	names     []string            // 解压时填充
+	root      string              // 全部文件共同的顶层目录，解压时去掉
+	keepRoot  bool                // 不去掉顶层目录，构建产物的目录结构与构建结果一致
+	files     []*model.DeployFile // 写入存储时生成的文件清单
+	reused    int                 // 内容已存在、无需重复写入的文件数
+	ruleFiles map[string][]byte   // 解压时收集的规则文件，校验阶段解析
+}
+
+func (a *artifact) Close() {
+	a.file.Close()
+	os.Remove(a.file.Name())
+}
+
+func (s *deployService) CreateDeploy(ctx context.Context, projectID, userID uint, req *request.CreateProjectDeployRequest) (*response.ProjectDeployResponse, error) {
+	if (req.Target == "") == (req.UploadID == "") {
+		return nil, fmt.Errorf("%w: 远程地址和上传ID须指定且只能指定一个", ErrInvalidArtifact)
+	}
+	if req.UploadID != "" {
+		if *req.TargetType != model.TargetTypeZip {
+			return nil, fmt.Errorf("%w: 使用上传ID时产物类型须为压缩包", ErrInvalidArtifact)
+		}
+		return s.createFromUpload(ctx, projectID, userID, req)
+	}
+
+	if *req.TargetType != model.TargetTypeURL {
+		return nil, fmt.Errorf("%w: 压缩包请通过上传接口提交", ErrInvalidArtifact)
+	}
+	if u, err := url.Parse(req.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%w: 仅支持 http 或 https 地址", ErrInvalidArtifact)
+	}
+
+	env, err := s.projectEnvRepo.GetByID(ctx, req.ProjectEnvID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    projectID,
+		ProjectEnvID: req.ProjectEnvID,
+		Remark:       req.Remark,
+		TargetType:   model.TargetTypeURL,
+		Target:       req.Target,
+		Status:       model.DeployStatusPending,
+		CreateUserID: userID,
+		ActionUserID: userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		return nil, err
+	}
+
+	checksum := strings.ToLower(req.Checksum)
+	if err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		return s.fetchURL(ctx, task, checksum)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(ctx, deploy), nil
+}
+
+// createFromUpload 使用已完成的断点续传上传创建部署，上传的内容复制后即删除该上传
+func (s *deployService) createFromUpload(ctx context.Context, projectID, userID uint, req *request.CreateProjectDeployRequest) (*response.ProjectDeployResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, req.ProjectEnvID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	upload, file, err := s.uploadService.Open(ctx, userID, strings.ToLower(req.UploadID))
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.receive(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if checksum := strings.ToLower(req.Checksum); checksum != "" && checksum != a.checksum {
+		a.Close()
+		return nil, fmt.Errorf("%w: 校验和不匹配: 期望 %s，实际 %s", ErrInvalidArtifact, checksum, a.checksum)
+	}
+
+	target := upload.Filename
+	if target == "" {
+		target = upload.ID
+	}
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    projectID,
+		ProjectEnvID: req.ProjectEnvID,
+		Remark:       req.Remark,
+		TargetType:   model.TargetTypeZip,
+		Target:       target,
+		Checksum:     a.checksum,
+		Size:         a.size,
+		Status:       model.DeployStatusPending,
+		CreateUserID: userID,
+		ActionUserID: userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		a.Close()
+		return nil, err
+	}
+	if err := s.uploadService.Delete(ctx, userID, upload.ID); err != nil {
+		logger.Logger.Warnf("删除已使用的上传失败 upload=%s: %v", upload.ID, err)
+	}
+
+	if err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		defer a.Close()
+		return s.process(ctx, task, a)
+	}); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return s.toResponse(ctx, deploy), nil
+}
+
+func (s *deployService) UploadArtifact(ctx context.Context, projectID, envID, userID uint, req *request.UploadDeployRequest, file io.Reader) (*response.ProjectDeployResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	// 上传的内容只能在请求中读取，先落盘再交给后台任务
+	a, err := s.receive(file)
+	if err != nil {
+		return nil, err
+	}
+
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    projectID,
+		ProjectEnvID: envID,
+		Remark:       req.Remark,
+		TargetType:   model.TargetTypeZip,
+		Target:       req.Filename,
+		Checksum:     a.checksum,
+		Size:         a.size,
+		Status:       model.DeployStatusPending,
+		CreateUserID: userID,
+		ActionUserID: userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	if err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		defer a.Close()
+		return s.process(ctx, task, a)
+	}); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return s.toResponse(ctx, deploy), nil
+}
+
+func (s *deployService) CreateBuild(ctx context.Context, projectID, envID, userID uint, req *request.CreateBuildRequest) (*response.ProjectDeployResponse, error) {
+	if s.builder == nil {
+		return nil, ErrBuildDisabled
+	}
+
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project.Build == nil {
+		return nil, ErrBuildNotSet
+	}
+
+	spec := builder.Spec{
+		RepoURL:   project.Build.RepoURL,
+		Branch:    project.Build.Branch,
+		Command:   project.Build.Command,
+		OutputDir: project.Build.OutputDir,
+	}
+	if req.Branch != "" {
+		spec.Branch = req.Branch
+	}
+
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    projectID,
+		ProjectEnvID: envID,
+		Remark:       req.Remark,
+		TargetType:   model.TargetTypeGit,
+		Target:       builder.Redact(spec.RepoURL),
+		Status:       model.DeployStatusPending,
+		CreateUserID: userID,
+		ActionUserID: userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		return nil, err
+	}
+
+	if err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		return s.build(ctx, task, spec)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(ctx, deploy), nil
+}
+
+func (s *deployService) CreateManifestDeploy(ctx context.Context, projectID, envID, userID uint, req *request.CreateManifestDeployRequest) (*response.ManifestDeployResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	files, err := s.checkManifest(req.Files)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return nil, err
+	}
+
+	// 部署的校验和为按路径排序的 sha256sum 格式清单的 sha256，内容相同的清单校验和相同
+	sorted := slices.Clone(files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	hash := sha256.New()
+	var size int64
+	for _, file := range sorted {
+		fmt.Fprintf(hash, "%s  %s\n", file.Checksum, file.Path)
+		size += file.Size
+	}
+
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:    projectID,
+		ProjectEnvID: envID,
+		Remark:       req.Remark,
+		TargetType:   model.TargetTypeManifest,
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		Size:         size,
+		Status:       model.DeployStatusUploading,
+		CreateUserID: userID,
+		ActionUserID: userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(ctx, pendingManifestKey(deploy.ID), bytes.NewReader(data), int64(len(data))); err != nil {
+		s.fail(context.Background(), deploy, err)
+		return nil, err
+	}
+
+	missing, err := s.missingBlobs(ctx, files)
+	if err != nil {
+		// 无法告知客户端需要上传的文件，部署不能继续，避免一直停留在等待上传状态
+		s.fail(context.Background(), deploy, err)
+		if err := s.storage.DeletePrefix(context.Background(), pendingManifestKey(deploy.ID)); err != nil {
+			logger.Logger.Warnf("清理增量上传的文件清单失败 deploy=%d: %v", deploy.ID, err)
+		}
+		return nil, err
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "已接收文件清单，共 %d 个文件，需上传 %d 份文件内容", len(files), len(missing))
+
+	return &response.ManifestDeployResponse{Deploy: s.toResponse(ctx, deploy), Missing: missing}, nil
+}
+
+func (s *deployService) GetMissingBlobs(ctx context.Context, projectID, deployID uint) (*response.ManifestDeployResponse, error) {
+	deploy, files, err := s.uploadingDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := s.missingBlobs(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	return &response.ManifestDeployResponse{Deploy: s.toResponse(ctx, deploy), Missing: missing}, nil
+}
+
+func (s *deployService) UploadBlob(ctx context.Context, projectID, deployID uint, checksum string, r io.Reader) error {
+	_, files, err := s.uploadingDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return err
+	}
+
+	checksum = strings.ToLower(checksum)
+	i := slices.IndexFunc(files, func(file *model.DeployFile) bool { return file.Checksum == checksum })
+	if i < 0 {
+		return fmt.Errorf("%w: 文件清单中没有 sha256 为 %s 的文件", ErrInvalidArtifact, checksum)
+	}
+	expected := files[i]
+
+	spool, err := os.CreateTemp("", "pubfree-file-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	// 多读一个字节，内容超过清单中的大小时校验不通过
+	file, err := spoolBlob(spool, expected.Path, io.LimitReader(r, expected.Size+1))
+	if err != nil {
+		return err
+	}
+	if file.Size != expected.Size || file.Checksum != checksum {
+		return fmt.Errorf("%w: 上传的内容与 sha256 或大小不一致", ErrInvalidArtifact)
+	}
+	if _, err := s.putBlob(ctx, spool, file); err != nil {
+		return err
+	}
+	// 刷新更新时间，持续上传的部署不会过期
+	return s.projectDeployRepo.TouchUploading(ctx, deployID)
+}
+
+func (s *deployService) ExpireUploading(ctx context.Context, ttl time.Duration) (int, error) {
+	deploys, err := s.projectDeployRepo.FailStaleUploading(ctx, ttl, ReasonUploadExpired)
+	for _, deploy := range deploys {
+		logger.Logger.Warnf("增量上传的部署已过期 deploy=%d", deploy.ID)
+		if err := s.storage.DeletePrefix(ctx, pendingManifestKey(deploy.ID)); err != nil {
+			logger.Logger.Warnf("清理增量上传的文件清单失败 deploy=%d: %v", deploy.ID, err)
+		}
+	}
+	return len(deploys), err
+}
+
+func (s *deployService) FinalizeManifestDeploy(ctx context.Context, projectID, deployID uint) (*response.ProjectDeployResponse, error) {
+	deploy, files, err := s.uploadingDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, err
+	}
+
+	missing, err := s.missingBlobs(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: 还有 %d 份文件内容未上传", ErrBlobsMissing, len(missing))
+	}
+
+	// 状态切换时会加锁检查当前状态，重复提交只有一次成功
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusPending, ""); err != nil {
+		if errors.Is(err, model.ErrInvalidDeployTransition) {
+			return nil, ErrNotUploading
+		}
+		return nil, err
+	}
+
+	if err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		return s.assemble(ctx, task, files)
+	}); err != nil {
+		return nil, err
+	}
+
+	return s.toResponse(ctx, deploy), nil
+}
+
+func (s *deployService) GetDeploy(ctx context.Context, projectID, deployID uint) (*response.ProjectDeployResponse, error) {
+	deploy, err := s.getDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions, err := s.projectDeployRepo.ListTransitions(ctx, deploy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := s.toResponse(ctx, deploy)
+	for _, transition := range transitions {
+		resp.Transitions = append(resp.Transitions, &response.DeployTransitionResponse{
+			FromStatus: transition.FromStatus,
+			ToStatus:   transition.ToStatus,
+			Reason:     transition.Reason,
+			CreatedAt:  transition.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+func (s *deployService) ActivateDeploy(ctx context.Context, projectID, deployID, userID uint) (*response.ProjectDeployResponse, error) {
+	deploy, err := s.getDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, err
+	}
+	if !model.IsDeployable(deploy.Status) {
+		return nil, ErrDeployNotReady
+	}
+
+	return s.activate(ctx, deploy, userID, model.ActivationActionActivate)
+}
+
+func (s *deployService) RollbackEnv(ctx context.Context, projectID, envID, userID uint, req *request.RollbackEnvRequest) (*response.ProjectDeployResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	var target *model.ProjectEnvDeploy
+	if req.DeployID != nil {
+		target, err = s.getDeploy(ctx, projectID, *req.DeployID)
+		if err != nil {
+			return nil, err
+		}
+		if target.ProjectEnvID != envID {
+			return nil, ErrDeployNotFound
+		}
+		if !model.IsDeployable(target.Status) {
+			return nil, ErrDeployNotReady
+		}
+	} else {
+		target, err = s.previousDeploy(ctx, projectID, envID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.activate(ctx, target, userID, model.ActivationActionRollback)
+}
+
+func (s *deployService) ListActivations(ctx context.Context, projectID, envID uint) ([]*response.DeployActivationResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	activations, err := s.activationRepo.ListByProjectEnvID(ctx, envID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []*response.DeployActivationResponse
+	for _, activation := range activations {
+		responses = append(responses, activationModelToResponse(activation))
+	}
+	return responses, nil
+}
+
+// previousDeploy 从激活历史中找出当前部署之前激活的部署。
+// 被回滚掉的部署不会再作为回滚目标，因此连续回滚会依次回到更早的版本
+func (s *deployService) previousDeploy(ctx context.Context, projectID, envID uint) (*model.ProjectEnvDeploy, error) {
+	activations, err := s.activationRepo.ListByProjectEnvID(ctx, envID, rollbackHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	if len(activations) == 0 {
+		return nil, ErrNoRollbackTarget
+	}
+
+	skip := map[uint]bool{activations[0].ToDeployID: true}
+	for _, activation := range activations {
+		if activation.Action == model.ActivationActionRollback && activation.FromDeployID != nil {
+			skip[*activation.FromDeployID] = true
+		}
+		if skip[activation.ToDeployID] {
+			continue
+		}
+
+		deploy, err := s.getDeploy(ctx, projectID, activation.ToDeployID)
+		if errors.Is(err, ErrDeployNotFound) {
+			skip[activation.ToDeployID] = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if model.IsDeployable(deploy.Status) {
+			return deploy, nil
+		}
+		skip[activation.ToDeployID] = true
+	}
+	return nil, ErrNoRollbackTarget
+}
+
+func (s *deployService) activate(ctx context.Context, deploy *model.ProjectEnvDeploy, userID uint, action string) (*response.ProjectDeployResponse, error) {
+	activated, err := s.projectDeployRepo.Activate(ctx, deploy, userID, action)
+	if err != nil {
+		return nil, err
+	}
+	if !activated {
+		return nil, ErrDeployNotReady
+	}
+
+	return s.GetDeploy(ctx, deploy.ProjectID, deploy.ID)
+}
+
+// getDeploy 获取项目下的部署，不属于该项目时视为不存在
+func (s *deployService) getDeploy(ctx context.Context, projectID, deployID uint) (*model.ProjectEnvDeploy, error) {
+	deploy, err := s.projectDeployRepo.GetByID(ctx, deployID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeployNotFound
+		}
+		return nil, err
+	}
+	if deploy.ProjectID != projectID {
+		return nil, ErrDeployNotFound
+	}
+	return deploy, nil
+}
+
+func (s *deployService) ListFiles(ctx context.Context, projectID, deployID uint) ([]*response.DeployFileResponse, error) {
+	deploy, err := s.getDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.manifest(ctx, deploy)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*response.DeployFileResponse, 0, len(files))
+	for _, file := range files {
+		responses = append(responses, &response.DeployFileResponse{
+			Path:        file.Path,
+			Size:        file.Size,
+			Checksum:    file.Checksum,
+			ContentType: file.ContentType,
+			GzipSize:    file.GzipSize,
+			BrotliSize:  file.BrotliSize,
+		})
+	}
+	return responses, nil
+}
+
+func (s *deployService) DiffDeploys(ctx context.Context, projectID, fromID, toID uint) (*response.DeployDiffResponse, error) {
+	from, err := s.getDeploy(ctx, projectID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getDeploy(ctx, projectID, toID)
+	if err != nil {
+		return nil, err
+	}
+	fromFiles, err := s.manifest(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := s.manifest(ctx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &response.DeployDiffResponse{
+		FromDeployID: from.ID,
+		ToDeployID:   to.ID,
+		Added:        []*response.DeployFileChange{},
+		Removed:      []*response.DeployFileChange{},
+		Changed:      []*response.DeployFileChange{},
+	}
+	previous := make(map[string]*model.DeployFile, len(fromFiles))
+	for _, file := range fromFiles {
+		previous[file.Path] = file
+	}
+
+	// 两份清单均按路径排序，结果也按路径排序
+	for _, file := range toFiles {
+		old, ok := previous[file.Path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, &response.DeployFileChange{Path: file.Path, ToSize: file.Size, SizeDelta: file.Size})
+		case old.Checksum != file.Checksum:
+			diff.Changed = append(diff.Changed, &response.DeployFileChange{Path: file.Path, FromSize: old.Size, ToSize: file.Size, SizeDelta: file.Size - old.Size})
+		}
+		delete(previous, file.Path)
+		diff.SizeDelta += file.Size
+	}
+	for _, file := range fromFiles {
+		if _, ok := previous[file.Path]; ok {
+			diff.Removed = append(diff.Removed, &response.DeployFileChange{Path: file.Path, FromSize: file.Size, SizeDelta: -file.Size})
+		}
+		diff.SizeDelta -= file.Size
+	}
+	return diff, nil
+}
+
+// manifest 获取部署的文件清单，复制而来的部署使用源部署的清单
+func (s *deployService) manifest(ctx context.Context, deploy *model.ProjectEnvDeploy) ([]*model.DeployFile, error) {
+	if !model.IsDeployable(deploy.Status) {
+		return nil, fmt.Errorf("%w: 部署 #%d 尚未就绪", ErrNoManifest, deploy.ID)
+	}
+	files, err := s.deployFileRepo.ListByDeployID(ctx, deploy.StorageDeployID())
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		// 文件清单功能上线之前创建的部署
+		return nil, fmt.Errorf("%w: 部署 #%d", ErrNoManifest, deploy.ID)
+	}
+	return files, nil
+}
+
+func (s *deployService) ListLogs(ctx context.Context, projectID, deployID, afterID uint) ([]*response.DeployLogResponse, bool, error) {
+	// 先读取状态再读取日志，状态已结束时日志一定已全部写入
+	deploy, err := s.getDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, false, err
+	}
+	// 等待上传的部署提交后还会继续处理
+	finished := !model.IsDeployInProgress(deploy.Status) && deploy.Status != model.DeployStatusUploading
+
+	logs, err := s.deployLogRepo.ListByDeployID(ctx, deployID, afterID, logPageSize)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(logs) == logPageSize {
+		// 还有未读取的日志
+		finished = false
+	}
+
+	responses := make([]*response.DeployLogResponse, 0, len(logs))
+	for _, log := range logs {
+		responses = append(responses, &response.DeployLogResponse{
+			ID:        log.ID,
+			Level:     log.Level,
+			Message:   log.Message,
+			CreatedAt: log.CreatedAt,
+		})
+	}
+	return responses, finished, nil
+}
+
+// deployJob 部署的后台处理步骤，task 为部署记录的副本
+type deployJob func(ctx context.Context, task *model.ProjectEnvDeploy) error
+
+// submit 将部署交给后台任务池，失败时记录原因。任务池已满或已关闭时部署直接失败
+func (s *deployService) submit(deploy *model.ProjectEnvDeploy, job deployJob) error {
+	s.logf(deploy, model.DeployLogLevelInfo, "部署已提交，等待处理")
+
+	// 排队和处理期间持续续约，任务结束后停止
+	leaseCtx, releaseLease := context.WithCancel(context.Background())
+	go s.keepLease(leaseCtx, deploy.ID)
+
+	// 复制一份交给后台任务，避免与响应共用同一个对象
+	task := *deploy
+	err := s.pool.Submit(func(ctx context.Context) {
+		defer releaseLease()
+		if err := job(ctx, &task); err != nil {
+			s.fail(ctx, &task, err)
+		}
+	})
+	if err != nil {
+		releaseLease()
+		s.fail(context.Background(), deploy, ErrDeployBusy)
+		return ErrDeployBusy
+	}
+	return nil
+}
+
+// keepLease 定期续约部署，直到 ctx 结束。实例退出后租约不再续约，由其他实例的清理任务将部署标记为失败
+func (s *deployService) keepLease(ctx context.Context, deployID uint) {
+	ticker := time.NewTicker(s.config.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		if err := s.projectDeployRepo.RenewLease(ctx, deployID, s.config.LeaseTTL); err != nil && ctx.Err() == nil {
+			logger.Logger.Warnf("部署续约失败 deploy=%d: %v", deployID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logf 记录部署日志。日志写入失败不影响部署处理，ctx 可能已失效，写入使用新的 context
+func (s *deployService) logf(deploy *model.ProjectEnvDeploy, level, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if runes := []rune(message); len(runes) > 1024 {
+		message = string(runes[:1024])
+	}
+
+	log := &model.DeployLog{DeployID: deploy.ID, Level: level, Message: message}
+	if err := s.deployLogRepo.Create(context.Background(), log); err != nil {
+		logger.Logger.Warnf("记录部署日志失败 deploy=%d: %v", deploy.ID, err)
+	}
+}
+
+// fail 将部署标记为失败。
+// 已写入的文件内容可能同时被其他部署引用，失败时不删除
+func (s *deployService) fail(ctx context.Context, deploy *model.ProjectEnvDeploy, err error) {
+	reason := err.Error()
+	if ctx.Err() != nil {
+		reason = reasonShutdown
+	}
+	if runes := []rune(reason); len(runes) > 512 {
+		reason = string(runes[:512])
+	}
+	logger.Logger.Warnf("部署失败 deploy=%d: %v", deploy.ID, err)
+	// 日志须在状态变为 failed 之前写入，读取日志的一方看到结束状态后不会再读取
+	s.logf(deploy, model.DeployLogLevelError, "部署失败: %s", reason)
+
+	// ctx 可能已失效，状态更新使用新的 context
+	if err := s.projectDeployRepo.Transition(context.Background(), deploy, model.DeployStatusFailed, reason); err != nil {
+		logger.Logger.Errorf("更新部署状态失败 deploy=%d: %v", deploy.ID, err)
+	}
+}
+
+// fetchURL 下载远程产物后继续解压和校验
+func (s *deployService) fetchURL(ctx context.Context, deploy *model.ProjectEnvDeploy, checksum string) error {
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusFetching, ""); err != nil {
+		return err
+	}
+
+	s.logf(deploy, model.DeployLogLevelInfo, "开始下载 %s", deploy.Target)
+	a, err := s.download(ctx, deploy.Target)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	s.logf(deploy, model.DeployLogLevelInfo, "下载完成，大小 %d 字节，sha256 %s", a.size, a.checksum)
+
+	if checksum != "" && checksum != a.checksum {
+		return fmt.Errorf("校验和不匹配: 期望 %s，实际 %s", checksum, a.checksum)
+	}
+	deploy.Checksum = a.checksum
+	deploy.Size = a.size
+	return s.process(ctx, deploy, a)
+}
+
+// build 克隆代码并执行构建，将构建产物目录打包后继续解压和校验
+func (s *deployService) build(ctx context.Context, deploy *model.ProjectEnvDeploy, spec builder.Spec) error {
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusBuilding, ""); err != nil {
+		return err
+	}
+
+	branch := spec.Branch
+	if branch == "" {
+		branch = "默认分支"
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "开始构建 %s（%s）", deploy.Target, branch)
+	ws, err := s.builder.Run(ctx, spec, func(line string) {
+		s.logf(deploy, model.DeployLogLevelInfo, "%s", line)
+	})
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	s.logf(deploy, model.DeployLogLevelInfo, "构建完成")
+
+	// 打包时同样受产物大小限制，超出时 receive 提前返回，关闭读端使打包中止
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(archive.PackDir(pw, ws.Output))
+	}()
+	a, err := s.receive(pr)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	a.keepRoot = true
+	deploy.Checksum = a.checksum
+	deploy.Size = a.size
+	return s.process(ctx, deploy, a)
+}
+
+func (s *deployService) download(ctx context.Context, target string) (*artifact, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.DownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("无效的下载地址: %w", err)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("下载失败: 远程服务器返回 %s", resp.Status)
+	}
+	if resp.ContentLength > s.config.MaxArtifactMB<<20 {
+		return nil, fmt.Errorf("%w: 压缩包不能超过 %dMB", ErrArtifactTooLarge, s.config.MaxArtifactMB)
+	}
+
+	return s.receive(resp.Body)
+}
+
+// process 解压、校验已落盘的产物，完成后部署变为 ready
+func (s *deployService) process(ctx context.Context, deploy *model.ProjectEnvDeploy, a *artifact) error {
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusExtracting, ""); err != nil {
+		return err
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "开始解压")
+	if err := s.extract(ctx, deploy, a); err != nil {
+		return err
+	}
+	if a.root != "" {
+		s.logf(deploy, model.DeployLogLevelInfo, "已去掉顶层目录 %s", strings.TrimSuffix(a.root, "/"))
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "解压完成，共 %d 个文件，其中 %d 个文件的内容已存在，未重复存储", len(a.files), a.reused)
+
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusValidating, ""); err != nil {
+		return err
+	}
+	return s.finish(ctx, deploy, a.files, a.ruleFiles, a.root)
+}
+
+// assemble 校验增量上传的文件内容是否都已存在，规则文件的内容从存储中读取，完成后部署变为 ready
+func (s *deployService) assemble(ctx context.Context, deploy *model.ProjectEnvDeploy, files []*model.DeployFile) error {
+	// 进入后台任务后部署要么就绪要么失败，提交的清单不再需要
+	defer func() {
+		if err := s.storage.DeletePrefix(context.Background(), pendingManifestKey(deploy.ID)); err != nil {
+			logger.Logger.Warnf("清理增量上传的文件清单失败 deploy=%d: %v", deploy.ID, err)
+		}
+	}()
+
+	if err := s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusValidating, ""); err != nil {
+		return err
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "开始校验文件内容")
+
+	ruleFiles := make(map[string][]byte)
+	manifest := make([]*model.DeployFile, 0, len(files))
+	for _, file := range files {
+		key := BlobKey(file.Checksum)
+		info, err := s.storage.Stat(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("%w: 文件内容不存在: %s", ErrInvalidArtifact, file.Path)
+		}
+		if err != nil {
+			return err
+		}
+		if info.Size != file.Size {
+			return fmt.Errorf("%w: 文件大小与清单不一致: %s", ErrInvalidArtifact, file.Path)
+		}
+
+		if file.Path == rules.RedirectsFile || file.Path == rules.HeadersFile {
+			data, err := s.readBlob(ctx, key, rules.MaxFileSize+1)
+			if err != nil {
+				return err
+			}
+			ruleFiles[file.Path] = data
+			continue
+		}
+		// 扩展名无法识别类型时按内容判断，与解压时一致
+		if file.ContentType == "" {
+			head, err := s.readBlob(ctx, key, 512)
+			if err != nil {
+				return err
+			}
+			file.ContentType = http.DetectContentType(head)
+		}
+		manifest = append(manifest, file)
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "文件内容校验完成，共 %d 个文件", len(manifest))
+
+	return s.finish(ctx, deploy, manifest, ruleFiles, "")
+}
+
+// finish 解析规则文件并保存文件清单，部署变为 ready。ruleFiles 的键带有顶层目录 root
+func (s *deployService) finish(ctx context.Context, deploy *model.ProjectEnvDeploy, files []*model.DeployFile, ruleFiles map[string][]byte, root string) error {
+	ruleSet, err := parseRules(ruleFiles, root)
+	if err != nil {
+		return err
+	}
+	if ruleSet != nil {
+		s.logf(deploy, model.DeployLogLevelInfo, "已解析 %d 条重定向规则，%d 条响应头规则", len(ruleSet.Redirects), len(ruleSet.Headers))
+	}
+
+	if err := s.precompress(ctx, deploy, files); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		file.DeployID = deploy.ID
+	}
+	if err := s.deployFileRepo.CreateBatch(ctx, files); err != nil {
+		return err
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "已生成文件清单，共 %d 个文件", len(files))
+
+	deploy.FileCount = len(files)
+	deploy.Rules = ruleSet
+	deploy.ContentAddressed = true
+	if err := s.projectDeployRepo.UpdateArtifact(ctx, deploy); err != nil {
+		return err
+	}
+	s.logf(deploy, model.DeployLogLevelInfo, "部署已就绪")
+	return s.projectDeployRepo.Transition(ctx, deploy, model.DeployStatusReady, "")
+}
+
+// receive 将产物写入临时文件并计算校验和，超过大小限制时返回 ErrArtifactTooLarge
+func (s *deployService) receive(r io.Reader) (*artifact, error) {
+	tmp, err := os.CreateTemp("", "pubfree-artifact-*")
+	if err != nil {
+		return nil, err
+	}
+	a := &artifact{file: tmp}
+
+	maxSize := s.config.MaxArtifactMB << 20
+	hash := sha256.New()
+	a.size, err = io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	if a.size > maxSize {
+		a.Close()
+		return nil, fmt.Errorf("%w: 压缩包不能超过 %dMB", ErrArtifactTooLarge, s.config.MaxArtifactMB)
+	}
+	a.checksum = hex.EncodeToString(hash.Sum(nil))
+	return a, nil
+}
+
+// extract 先遍历一遍压缩包，确认路径安全、数量和大小在限制内，并收集规则文件；
+// 再将文件按内容写入存储并生成文件清单，压缩包只有一个顶层目录时去掉该目录。
+// 根目录下的规则文件会解析到部署记录中，不写入存储，也不会被网关返回
+func (s *deployService) extract(ctx context.Context, deploy *model.ProjectEnvDeploy, a *artifact) error {
+	// 此时还不知道顶层目录，先收集所有同名的规则文件
+	a.ruleFiles = make(map[string][]byte)
+	err := s.walk(a, func(name string, r io.Reader) error {
+		a.names = append(a.names, name)
+		if base := path.Base(name); base == rules.RedirectsFile || base == rules.HeadersFile {
+			data, err := io.ReadAll(io.LimitReader(r, rules.MaxFileSize+1))
+			if err != nil {
+				return err
+			}
+			a.ruleFiles[name] = data
+		}
+		_, err := io.Copy(io.Discard, r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(a.names) == 0 {
+		return fmt.Errorf("%w: 压缩包中没有文件", ErrInvalidArtifact)
+	}
+	if !a.keepRoot {
+		a.root = archive.CommonRoot(a.names)
+	}
+
+	// 文件先写入临时文件计算 sha256，内容不存在时再写入存储
+	spool, err := os.CreateTemp("", "pubfree-file-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	return s.walk(a, func(name string, r io.Reader) error {
+		name = strings.TrimPrefix(name, a.root)
+		if name == rules.RedirectsFile || name == rules.HeadersFile {
+			return nil
+		}
+		if len(name) > maxPathLength {
+			return fmt.Errorf("%w: 文件路径不能超过 %d 个字符: %s", ErrInvalidArtifact, maxPathLength, name)
+		}
+
+		file, stored, err := s.storeBlob(ctx, spool, name, r)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			a.reused++
+		}
+		a.files = append(a.files, file)
+		return nil
+	})
+}
+
+// storeBlob 将文件内容写入按内容寻址的存储，内容已存在时不再写入，stored 表示是否实际写入
+func (s *deployService) storeBlob(ctx context.Context, spool *os.File, name string, r io.Reader) (file *model.DeployFile, stored bool, err error) {
+	file, err = spoolBlob(spool, name, r)
+	if err != nil {
+		return nil, false, err
+	}
+	stored, err = s.putBlob(ctx, spool, file)
+	if err != nil {
+		return nil, false, err
+	}
+	return file, stored, nil
+}
+
+// spoolBlob 将文件内容写入临时文件，同时计算大小和 sha256。
+// 扩展名无法识别类型时按内容判断，与网关返回的类型一致
+func spoolBlob(spool *os.File, name string, r io.Reader) (*model.DeployFile, error) {
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := spool.Truncate(0); err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), r)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, err := spool.ReadAt(head, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		contentType = http.DetectContentType(head[:n])
+	}
+
+	return &model.DeployFile{
+		Path:        name,
+		Size:        size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		ContentType: contentType,
+	}, nil
+}
+
+// putBlob 将临时文件中的内容写入存储，内容已存在时不再写入
+func (s *deployService) putBlob(ctx context.Context, spool *os.File, file *model.DeployFile) (bool, error) {
+	key := BlobKey(file.Checksum)
+	if _, err := s.storage.Stat(ctx, key); err == nil {
+		return false, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return false, err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	if err := s.storage.Put(ctx, key, spool, file.Size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// precompress 为值得压缩的文件生成 gzip 与 brotli 版本，网关按 Accept-Encoding 直接返回，无需每次请求时压缩。
+// 压缩版本按原内容的 sha256 寻址，已存在时只记录大小；压缩后没有变小的不保存
+func (s *deployService) precompress(ctx context.Context, deploy *model.ProjectEnvDeploy, files []*model.DeployFile) error {
+	spool, err := os.CreateTemp("", "pubfree-encoded-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	count := 0
+	for _, file := range files {
+		if !compress.Compressible(file.ContentType, file.Size) {
+			continue
+		}
+		for _, encoding := range compress.Encodings {
+			size, err := s.storeEncoded(ctx, spool, file, encoding)
+			if err != nil {
+				return err
+			}
+			file.SetEncodedSize(encoding, size)
+		}
+		if file.GzipSize > 0 || file.BrotliSize > 0 {
+			count++
+		}
+	}
+	if count > 0 {
+		s.logf(deploy, model.DeployLogLevelInfo, "共 %d 个文件有预压缩版本", count)
+	}
+	return nil
+}
+
+// storeEncoded 将文件内容压缩后写入存储，返回压缩版本的大小，压缩后没有变小时返回 0
+func (s *deployService) storeEncoded(ctx context.Context, spool *os.File, file *model.DeployFile, encoding string) (int64, error) {
+	key := EncodedBlobKey(file.Checksum, encoding)
+	if info, err := s.storage.Stat(ctx, key); err == nil {
+		return info.Size, nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return 0, err
+	}
+
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := spool.Truncate(0); err != nil {
+		return 0, err
+	}
+	object, err := s.storage.Get(ctx, BlobKey(file.Checksum))
+	if err != nil {
+		return 0, err
+	}
+	err = compress.Encode(spool, object, encoding)
+	object.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := spool.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if size >= file.Size {
+		return 0, nil
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := s.storage.Put(ctx, key, spool, size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// readBlob 读取文件内容的前 limit 个字节
+func (s *deployService) readBlob(ctx context.Context, key string, limit int64) ([]byte, error) {
+	object, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(io.LimitReader(object, limit))
+}
+
+// checkManifest 校验增量上传的文件清单，路径规则和数量、大小限制与解压压缩包时一致。
+// 扩展名无法识别类型的文件 ContentType 为空，校验文件内容时再判断
+func (s *deployService) checkManifest(list []*request.ManifestFile) ([]*model.DeployFile, error) {
+	if s.config.MaxFiles > 0 && len(list) > s.config.MaxFiles {
+		return nil, fmt.Errorf("%w: 文件数量不能超过 %d 个", ErrArtifactTooLarge, s.config.MaxFiles)
+	}
+
+	var (
+		total int64
+		pages int
+	)
+	files := make([]*model.DeployFile, 0, len(list))
+	seen := make(map[string]bool, len(list))
+	for _, item := range list {
+		name, ok := archive.CleanPath(strings.TrimPrefix(item.Path, "/"))
+		if !ok {
+			return nil, fmt.Errorf("%w: 不安全的文件路径: %s", ErrInvalidArtifact, item.Path)
+		}
+		if len(name) > maxPathLength {
+			return nil, fmt.Errorf("%w: 文件路径不能超过 %d 个字符: %s", ErrInvalidArtifact, maxPathLength, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: 重复的文件路径: %s", ErrInvalidArtifact, name)
+		}
+		seen[name] = true
+
+		if name == rules.RedirectsFile || name == rules.HeadersFile {
+			if item.Size > rules.MaxFileSize {
+				return nil, fmt.Errorf("%w: %s 不能超过 %dKB", ErrInvalidArtifact, name, rules.MaxFileSize>>10)
+			}
+		} else {
+			pages++
+		}
+		total += item.Size
+		if max := s.config.MaxUnpackedMB << 20; max > 0 && total > max {
+			return nil, fmt.Errorf("%w: 文件总大小不能超过 %dMB", ErrArtifactTooLarge, s.config.MaxUnpackedMB)
+		}
+
+		files = append(files, &model.DeployFile{
+			Path:        name,
+			Size:        item.Size,
+			Checksum:    strings.ToLower(item.SHA256),
+			ContentType: mime.TypeByExtension(path.Ext(name)),
+		})
+	}
+	if pages == 0 {
+		return nil, fmt.Errorf("%w: 清单中没有文件", ErrInvalidArtifact)
+	}
+	return files, nil
+}
+
+// missingBlobs 返回清单中存储里还没有的文件内容，相同内容只返回一次
+func (s *deployService) missingBlobs(ctx context.Context, files []*model.DeployFile) ([]string, error) {
+	missing := []string{}
+	checked := make(map[string]bool, len(files))
+	for _, file := range files {
+		if checked[file.Checksum] {
+			continue
+		}
+		checked[file.Checksum] = true
+
+		_, err := s.storage.Stat(ctx, BlobKey(file.Checksum))
+		if errors.Is(err, storage.ErrNotFound) {
+			missing = append(missing, file.Checksum)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return missing, nil
+}
+
+// uploadingDeploy 获取等待上传的部署及客户端提交的文件清单
+func (s *deployService) uploadingDeploy(ctx context.Context, projectID, deployID uint) (*model.ProjectEnvDeploy, []*model.DeployFile, error) {
+	deploy, err := s.getDeploy(ctx, projectID, deployID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deploy.Status != model.DeployStatusUploading {
+		return nil, nil, ErrNotUploading
+	}
+
+	object, err := s.storage.Get(ctx, pendingManifestKey(deploy.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer object.Close()
+
+	var files []*model.DeployFile
+	if err := json.NewDecoder(object).Decode(&files); err != nil {
+		return nil, nil, err
+	}
+	return deploy, files, nil
+}
+
+// parseRules 解析产物根目录下的 _redirects 与 _headers，语法错误时返回带行号的错误
+func parseRules(files map[string][]byte, root string) (*rules.RuleSet, error) {
+	set := &rules.RuleSet{}
+	for _, file := range []string{rules.RedirectsFile, rules.HeadersFile} {
+		data, ok := files[root+file]
+		if !ok {
+			continue
+		}
+		if len(data) > rules.MaxFileSize {
+			return nil, fmt.Errorf("%w: %s 不能超过 %dKB", ErrInvalidArtifact, file, rules.MaxFileSize>>10)
+		}
+
+		var err error
+		if file == rules.RedirectsFile {
+			set.Redirects, err = rules.ParseRedirects(bytes.NewReader(data))
+		} else {
+			set.Headers, err = rules.ParseHeaders(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+		}
+	}
+
+	if set.IsEmpty() {
+		return nil, nil
+	}
+	return set, nil
+}
+
+// walk 遍历压缩包，并将解压错误转换为业务错误
+func (s *deployService) walk(a *artifact, fn archive.WalkFunc) error {
+	limits := archive.Limits{
+		MaxFiles:     s.config.MaxFiles,
+		MaxTotalSize: s.config.MaxUnpackedMB << 20,
+	}
+
+	err := archive.Walk(a.file, a.size, limits, fn)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, archive.ErrTooManyFiles), errors.Is(err, archive.ErrTooLarge):
+		return fmt.Errorf("%w: %v", ErrArtifactTooLarge, err)
+	case errors.Is(err, archive.ErrUnsupportedFormat), errors.Is(err, archive.ErrUnsafePath):
+		return fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	default:
+		return err
+	}
+}
+
+// toResponse 转换为响应并填充预览地址
+func (s *deployService) toResponse(ctx context.Context, deploy *model.ProjectEnvDeploy) *response.ProjectDeployResponse {
+	resp := deployModelToResponse(deploy)
+	s.previewService.Fill(ctx, resp)
+	return resp
+}
+
+func activationModelToResponse(activation *model.DeployActivation) *response.DeployActivationResponse {
+	resp := &response.DeployActivationResponse{
+		ID:           activation.ID,
+		ProjectID:    activation.ProjectID,
+		ProjectEnvID: activation.ProjectEnvID,
+		FromDeployID: activation.FromDeployID,
+		ToDeployID:   activation.ToDeployID,
+		Action:       activation.Action,
+		OperatorID:   activation.OperatorID,
+		CreatedAt:    activation.CreatedAt,
+	}
+
+	if activation.Operator.ID != 0 {
+		resp.Operator = response.UserResponse{
+			ID:        activation.Operator.ID,
+			Name:      activation.Operator.Name,
+			CreatedAt: activation.Operator.CreatedAt,
+			UpdatedAt: activation.Operator.UpdatedAt,
+		}
+	}
+
+	return resp
+}
@@ -0,0 +1,705 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/storage"
+	"pubfree-platform/pubfree-server/pkg/workerpool"
+
+	"gorm.io/gorm"
+)
+
+type fakeProjectDeployRepo struct {
+	repository.ProjectDeployRepository
+
+	mu       sync.Mutex
+	deploys  map[uint]*model.ProjectEnvDeploy
+	statuses []string
+	renewals map[uint]int
+	stale    map[uint]bool // 超过有效期未更新的等待上传部署
+
+	// activations 为空时 Activate 不记录激活历史；beforeActivate 在 Activate 加锁前调用，用于模拟并发修改
+	activations    *fakeActivationRepo
+	beforeActivate func()
+}
+
+func (r *fakeProjectDeployRepo) ListTransitions(ctx context.Context, deployID uint) ([]*model.DeployTransition, error) {
+	return nil, nil
+}
+
+// Activate 与数据库实现相同：加锁后重新检查部署，替换环境中原先激活的部署并记录激活历史
+func (r *fakeProjectDeployRepo) Activate(ctx context.Context, deploy *model.ProjectEnvDeploy, userID uint, action string) (bool, error) {
+	if r.beforeActivate != nil {
+		r.beforeActivate()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	target, ok := r.deploys[deploy.ID]
+	if !ok || target.ProjectEnvID != deploy.ProjectEnvID || !model.IsDeployable(target.Status) {
+		return false, nil
+	}
+	if target.IsActive != nil && *target.IsActive == 1 {
+		return true, nil
+	}
+
+	activation := &model.DeployActivation{ProjectEnvID: target.ProjectEnvID, ToDeployID: target.ID, Action: action, OperatorID: userID}
+	inactive, active := int8(0), int8(1)
+	for _, other := range r.deploys {
+		if other.ProjectEnvID == target.ProjectEnvID && other.IsActive != nil && *other.IsActive == 1 {
+			other.IsActive = &inactive
+			other.Status = model.DeployStatusSuperseded
+			activation.FromDeployID = &other.ID
+		}
+	}
+	target.IsActive = &active
+	target.Status = model.DeployStatusActive
+	if r.activations != nil {
+		r.activations.add(activation)
+	}
+	return true, nil
+}
+
+func (r *fakeProjectDeployRepo) GetByID(ctx context.Context, id uint) (*model.ProjectEnvDeploy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deploy, ok := r.deploys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return deploy, nil
+}
+
+func (r *fakeProjectDeployRepo) Transition(ctx context.Context, deploy *model.ProjectEnvDeploy, to string, reason string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, to)
+	deploy.Status = to
+	return nil
+}
+
+func (r *fakeProjectDeployRepo) Create(ctx context.Context, deploy *model.ProjectEnvDeploy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.deploys == nil {
+		r.deploys = make(map[uint]*model.ProjectEnvDeploy)
+	}
+	deploy.ID = uint(len(r.deploys) + 1)
+	r.deploys[deploy.ID] = deploy
+	return nil
+}
+
+func (r *fakeProjectDeployRepo) RenewLease(ctx context.Context, id uint, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.renewals == nil {
+		r.renewals = make(map[uint]int)
+	}
+	r.renewals[id]++
+	return nil
+}
+
+// FailStaleUploading 将 stale 中的等待上传部署标记为失败
+func (r *fakeProjectDeployRepo) FailStaleUploading(ctx context.Context, ttl time.Duration, reason string) ([]*model.ProjectEnvDeploy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var failed []*model.ProjectEnvDeploy
+	for id, deploy := range r.deploys {
+		if r.stale[id] && deploy.Status == model.DeployStatusUploading {
+			deploy.Status = model.DeployStatusFailed
+			deploy.FailReason = &reason
+			failed = append(failed, deploy)
+		}
+	}
+	return failed, nil
+}
+
+func (r *fakeProjectDeployRepo) TouchUploading(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stale, id)
+	return nil
+}
+
+func (r *fakeProjectDeployRepo) renewalCount(id uint) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.renewals[id]
+}
+
+type fakeDeployLogRepo struct {
+	repository.DeployLogRepository
+	logs []*model.DeployLog
+}
+
+func (r *fakeDeployLogRepo) Create(ctx context.Context, log *model.DeployLog) error {
+	r.logs = append(r.logs, log)
+	return nil
+}
+
+// fakeActivationRepo 按时间倒序保存激活历史
+type fakeActivationRepo struct {
+	repository.DeployActivationRepository
+	activations []*model.DeployActivation
+}
+
+func (r *fakeActivationRepo) add(activation *model.DeployActivation) {
+	activation.ID = uint(len(r.activations) + 1)
+	r.activations = append([]*model.DeployActivation{activation}, r.activations...)
+}
+
+func (r *fakeActivationRepo) ListByProjectEnvID(ctx context.Context, projectEnvID uint, limit int) ([]*model.DeployActivation, error) {
+	var activations []*model.DeployActivation
+	for _, activation := range r.activations {
+		if activation.ProjectEnvID == projectEnvID {
+			activations = append(activations, activation)
+		}
+	}
+	if limit > 0 && len(activations) > limit {
+		activations = activations[:limit]
+	}
+	return activations, nil
+}
+
+type fakeDeployFileRepo struct {
+	repository.DeployFileRepository
+	files map[uint][]*model.DeployFile
+}
+
+func (r *fakeDeployFileRepo) ListByDeployID(ctx context.Context, deployID uint) ([]*model.DeployFile, error) {
+	return r.files[deployID], nil
+}
+
+type fakeProjectEnvRepo struct {
+	repository.ProjectEnvRepository
+	envs map[uint]*model.ProjectEnv
+}
+
+func (r *fakeProjectEnvRepo) GetByID(ctx context.Context, id uint) (*model.ProjectEnv, error) {
+	env, ok := r.envs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return env, nil
+}
+
+// fakeStorage 在本地存储之上记录 Stat 的调用，statErr 不为空时 Stat 返回该错误
+type fakeStorage struct {
+	storage.Storage
+	statErr error
+	stats   []string
+}
+
+func (f *fakeStorage) Stat(ctx context.Context, key string) (*storage.ObjectInfo, error) {
+	f.stats = append(f.stats, key)
+	if f.statErr != nil {
+		return nil, f.statErr
+	}
+	return f.Storage.Stat(ctx, key)
+}
+
+func newFakeStorage(t *testing.T) *fakeStorage {
+	t.Helper()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeStorage{Storage: local}
+}
+
+func newTestDeployService() *deployService {
+	cfg := config.DeployConfig{MaxArtifactMB: 1, MaxUnpackedMB: 1, MaxFiles: 100, DownloadTimeout: 5 * time.Second}
+	return &deployService{
+		projectDeployRepo: &fakeProjectDeployRepo{},
+		deployLogRepo:     &fakeDeployLogRepo{},
+		deployFileRepo:    &fakeDeployFileRepo{},
+		previewService:    NewPreviewService(nil, config.PreviewConfig{}),
+		config:            cfg,
+		// 测试服务器监听在回环地址上
+		httpClient: newDownloadClient(cfg.DownloadTimeout, true),
+	}
+}
+
+func TestDownload(t *testing.T) {
+	body := "artifact content"
+	sum := sha256.Sum256([]byte(body))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/artifact.zip":
+			w.Write([]byte(body))
+		case "/redirect":
+			http.Redirect(w, r, "/artifact.zip", http.StatusFound)
+		case "/large.zip":
+			w.Header().Set("Content-Length", strconv.Itoa(2<<20))
+			w.WriteHeader(http.StatusOK)
+		case "/stream.zip":
+			// 未声明长度时按实际读取的大小限制
+			w.Write([]byte(strings.Repeat("x", 1<<20+1)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	s := newTestDeployService()
+	tests := []struct {
+		path    string
+		wantErr string
+	}{
+		{path: "/artifact.zip"},
+		{path: "/redirect"},
+		{path: "/large.zip", wantErr: ErrArtifactTooLarge.Error()},
+		{path: "/stream.zip", wantErr: ErrArtifactTooLarge.Error()},
+		{path: "/missing.zip", wantErr: "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			a, err := s.download(context.Background(), server.URL+tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, 期望包含 %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("下载失败: %v", err)
+			}
+			defer a.Close()
+			if a.size != int64(len(body)) || a.checksum != hex.EncodeToString(sum[:]) {
+				t.Errorf("size = %d, checksum = %s", a.size, a.checksum)
+			}
+		})
+	}
+}
+
+func TestFetchURLChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("artifact content"))
+	}))
+	defer server.Close()
+
+	s := newTestDeployService()
+	deploy := &model.ProjectEnvDeploy{Target: server.URL + "/artifact.zip", Status: model.DeployStatusPending}
+	err := s.fetchURL(context.Background(), deploy, strings.Repeat("0", 64))
+	if err == nil || !strings.Contains(err.Error(), "校验和不匹配") {
+		t.Fatalf("err = %v, 期望校验和不匹配", err)
+	}
+	if deploy.Checksum != "" {
+		t.Error("校验失败时不应记录校验和")
+	}
+	if got := s.projectDeployRepo.(*fakeProjectDeployRepo).statuses; len(got) != 1 || got[0] != model.DeployStatusFetching {
+		t.Errorf("状态变更 = %v, 期望只进入 fetching", got)
+	}
+}
+
+func TestSubmitRenewsLeaseUntilJobFinishes(t *testing.T) {
+	s := newTestDeployService()
+	s.config.LeaseTTL = 30 * time.Millisecond
+	s.pool = workerpool.New(1, 1)
+	defer s.pool.Shutdown(context.Background())
+	repo := s.projectDeployRepo.(*fakeProjectDeployRepo)
+
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	deploy := &model.ProjectEnvDeploy{ID: 7, Status: model.DeployStatusPending}
+	err := s.submit(deploy, func(ctx context.Context, task *model.ProjectEnvDeploy) error {
+		defer close(finished)
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("submit 出错: %v", err)
+	}
+
+	// 任务执行期间持续续约
+	deadline := time.Now().Add(2 * time.Second)
+	for repo.renewalCount(deploy.ID) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("任务执行期间续约 %d 次，期望至少 3 次", repo.renewalCount(deploy.ID))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	close(release)
+	<-finished
+	time.Sleep(20 * time.Millisecond)
+	count := repo.renewalCount(deploy.ID)
+	time.Sleep(3 * s.config.LeaseTTL)
+	if got := repo.renewalCount(deploy.ID); got != count {
+		t.Errorf("任务结束后仍在续约: %d -> %d", count, got)
+	}
+}
+
+func TestDiffDeploys(t *testing.T) {
+	file := func(path, checksum string, size int64) *model.DeployFile {
+		return &model.DeployFile{Path: path, Checksum: checksum, Size: size}
+	}
+	source := uint(1)
+	s := newTestDeployService()
+	s.projectDeployRepo = &fakeProjectDeployRepo{deploys: map[uint]*model.ProjectEnvDeploy{
+		1: {ID: 1, ProjectID: 1, Status: model.DeployStatusSuperseded},
+		2: {ID: 2, ProjectID: 1, Status: model.DeployStatusActive},
+		3: {ID: 3, ProjectID: 1, Status: model.DeployStatusReady, SourceDeployID: &source},
+		4: {ID: 4, ProjectID: 1, Status: model.DeployStatusBuilding},
+		5: {ID: 5, ProjectID: 1, Status: model.DeployStatusReady},
+		6: {ID: 6, ProjectID: 2, Status: model.DeployStatusReady},
+	}}
+	// 清单按路径排序
+	s.deployFileRepo = &fakeDeployFileRepo{files: map[uint][]*model.DeployFile{
+		1: {file("a.js", "a1", 100), file("index.html", "i1", 50), file("old.css", "o1", 30)},
+		2: {file("a.js", "a2", 120), file("b.js", "b1", 10), file("index.html", "i1", 50)},
+		6: {file("index.html", "i1", 50)},
+	}}
+
+	diff, err := s.DiffDeploys(context.Background(), 1, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := func(changes []*response.DeployFileChange) string {
+		var parts []string
+		for _, c := range changes {
+			parts = append(parts, fmt.Sprintf("%s:%d->%d(%+d)", c.Path, c.FromSize, c.ToSize, c.SizeDelta))
+		}
+		return strings.Join(parts, ",")
+	}
+	if got := format(diff.Added); got != "b.js:0->10(+10)" {
+		t.Errorf("Added = %s", got)
+	}
+	if got := format(diff.Removed); got != "old.css:30->0(-30)" {
+		t.Errorf("Removed = %s", got)
+	}
+	if got := format(diff.Changed); got != "a.js:100->120(+20)" {
+		t.Errorf("Changed = %s", got)
+	}
+	if diff.SizeDelta != 0 || diff.FromDeployID != 1 || diff.ToDeployID != 2 {
+		t.Errorf("SizeDelta = %d, from = %d, to = %d", diff.SizeDelta, diff.FromDeployID, diff.ToDeployID)
+	}
+
+	// 提升产生的部署使用源部署的清单
+	diff, err = s.DiffDeploys(context.Background(), 1, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 || diff.SizeDelta != 0 {
+		t.Errorf("相同的文件不应有变化: %+v", diff)
+	}
+
+	tests := []struct {
+		name     string
+		from, to uint
+		wantErr  error
+	}{
+		{"部署尚未就绪", 1, 4, ErrNoManifest},
+		{"没有文件清单", 5, 1, ErrNoManifest},
+		{"部署不存在", 1, 99, ErrDeployNotFound},
+		{"部署属于其他项目", 6, 1, ErrDeployNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.DiffDeploys(context.Background(), 1, tt.from, tt.to); !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMissingBlobs(t *testing.T) {
+	store := newFakeStorage(t)
+	if err := store.Put(context.Background(), BlobKey("aaaa"), strings.NewReader("a"), 1); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestDeployService()
+	s.storage = store
+
+	files := []*model.DeployFile{
+		{Path: "a.html", Checksum: "aaaa"},
+		{Path: "b.html", Checksum: "bbbb"},
+		{Path: "c.html", Checksum: "cccc"},
+		// 内容相同的文件只检查一次
+		{Path: "copy/b.html", Checksum: "bbbb"},
+	}
+	missing, err := s.missingBlobs(context.Background(), files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(missing, ",") != "bbbb,cccc" {
+		t.Errorf("missing = %v, 期望 [bbbb cccc]", missing)
+	}
+	if len(store.stats) != 3 {
+		t.Errorf("Stat 调用 %d 次, 期望 3 次", len(store.stats))
+	}
+
+	// 全部已存在时返回空列表而不是 nil，接口返回 []
+	missing, err = s.missingBlobs(context.Background(), files[:1])
+	if err != nil || missing == nil || len(missing) != 0 {
+		t.Errorf("missing = %#v, err = %v", missing, err)
+	}
+
+	store.statErr = errors.New("存储不可用")
+	if _, err := s.missingBlobs(context.Background(), files); !errors.Is(err, store.statErr) {
+		t.Errorf("err = %v, 期望存储错误", err)
+	}
+}
+
+func TestCreateManifestDeployFailsWhenBlobCheckFails(t *testing.T) {
+	store := newFakeStorage(t)
+	store.statErr = errors.New("存储不可用")
+	deployRepo := &fakeProjectDeployRepo{}
+	s := newTestDeployService()
+	s.storage = store
+	s.projectDeployRepo = deployRepo
+	s.projectEnvRepo = &fakeProjectEnvRepo{envs: map[uint]*model.ProjectEnv{1: {ID: 1, ProjectID: 1}}}
+
+	req := &request.CreateManifestDeployRequest{Files: []*request.ManifestFile{
+		{Path: "index.html", SHA256: strings.Repeat("a", 64), Size: 10},
+	}}
+	if _, err := s.CreateManifestDeploy(context.Background(), 1, 1, 1, req); !errors.Is(err, store.statErr) {
+		t.Fatalf("err = %v, 期望存储错误", err)
+	}
+
+	deploy := deployRepo.deploys[1]
+	if deploy == nil || deploy.Status != model.DeployStatusFailed {
+		t.Fatalf("部署应被标记为失败: %+v", deploy)
+	}
+	if _, err := store.Storage.Stat(context.Background(), pendingManifestKey(deploy.ID)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("失败后应清理提交的文件清单, err = %v", err)
+	}
+}
+
+func TestActivateDeploy(t *testing.T) {
+	active := int8(1)
+	newService := func() (*deployService, *fakeProjectDeployRepo) {
+		repo := &fakeProjectDeployRepo{
+			activations: &fakeActivationRepo{},
+			deploys: map[uint]*model.ProjectEnvDeploy{
+				1: {ID: 1, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusActive, IsActive: &active},
+				2: {ID: 2, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusReady},
+				3: {ID: 3, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusExtracting},
+				4: {ID: 4, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusFailed},
+				5: {ID: 5, ProjectID: 2, ProjectEnvID: 2, Status: model.DeployStatusReady},
+			},
+		}
+		s := newTestDeployService()
+		s.projectDeployRepo = repo
+		return s, repo
+	}
+
+	tests := []struct {
+		name     string
+		deployID uint
+		wantErr  error
+	}{
+		{"就绪的部署", 2, nil},
+		{"已激活的部署", 1, nil},
+		{"处理中的部署", 3, ErrDeployNotReady},
+		{"失败的部署", 4, ErrDeployNotReady},
+		{"其他项目的部署", 5, ErrDeployNotFound},
+		{"部署不存在", 99, ErrDeployNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newService()
+			resp, err := s.ActivateDeploy(context.Background(), 1, tt.deployID, 7)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if repo.deploys[1].Status != model.DeployStatusActive {
+					t.Error("激活失败时原先激活的部署不应改变")
+				}
+				return
+			}
+			if resp.ID != tt.deployID || resp.Status != model.DeployStatusActive {
+				t.Errorf("响应 id = %d, status = %s", resp.ID, resp.Status)
+			}
+		})
+	}
+
+	// 检查通过后部署被并发删除或改变状态
+	s, repo := newService()
+	repo.beforeActivate = func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		repo.deploys[2].Status = model.DeployStatusFailed
+	}
+	if _, err := s.ActivateDeploy(context.Background(), 1, 2, 7); !errors.Is(err, ErrDeployNotReady) {
+		t.Errorf("err = %v, 期望 ErrDeployNotReady", err)
+	}
+	if len(repo.activations.activations) != 0 {
+		t.Error("未激活时不应记录激活历史")
+	}
+}
+
+func TestRollbackEnv(t *testing.T) {
+	// steps 依次执行："activate N" 激活部署 N，"rollback" 回滚到上一个部署，"delete N" 删除部署 N
+	tests := []struct {
+		name    string
+		steps   []string
+		want    uint
+		wantErr error
+	}{
+		{"没有激活过的部署", nil, 0, ErrNoRollbackTarget},
+		{"只激活过一个部署", []string{"activate 1"}, 0, ErrNoRollbackTarget},
+		{"回到上一个部署", []string{"activate 1", "activate 2"}, 1, nil},
+		{"重复激活同一部署", []string{"activate 1", "activate 2", "activate 1"}, 2, nil},
+		{"连续回滚", []string{"activate 1", "activate 2", "activate 3", "rollback"}, 1, nil},
+		{"回滚到最早的部署后没有目标", []string{"activate 1", "activate 2", "activate 3", "rollback", "rollback"}, 0, ErrNoRollbackTarget},
+		{"回滚后发布新部署", []string{"activate 1", "activate 2", "rollback", "activate 3"}, 1, nil},
+		{"跳过已删除的部署", []string{"activate 1", "activate 2", "activate 3", "delete 2"}, 1, nil},
+		{"其他环境的激活记录", []string{"activate 1", "activate 4"}, 0, ErrNoRollbackTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeProjectDeployRepo{activations: &fakeActivationRepo{}, deploys: map[uint]*model.ProjectEnvDeploy{}}
+			for id := uint(1); id <= 3; id++ {
+				repo.deploys[id] = &model.ProjectEnvDeploy{ID: id, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusReady}
+			}
+			repo.deploys[4] = &model.ProjectEnvDeploy{ID: 4, ProjectID: 1, ProjectEnvID: 2, Status: model.DeployStatusReady}
+			s := newTestDeployService()
+			s.projectDeployRepo = repo
+			s.activationRepo = repo.activations
+			s.projectEnvRepo = &fakeProjectEnvRepo{envs: map[uint]*model.ProjectEnv{1: {ID: 1, ProjectID: 1}, 2: {ID: 2, ProjectID: 1}}}
+
+			ctx := context.Background()
+			for _, step := range tt.steps {
+				op, arg, _ := strings.Cut(step, " ")
+				id, _ := strconv.ParseUint(arg, 10, 32)
+				var err error
+				switch op {
+				case "activate":
+					_, err = s.ActivateDeploy(ctx, 1, uint(id), 1)
+				case "rollback":
+					_, err = s.RollbackEnv(ctx, 1, 1, 1, &request.RollbackEnvRequest{})
+				case "delete":
+					delete(repo.deploys, uint(id))
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", step, err)
+				}
+			}
+
+			resp, err := s.RollbackEnv(ctx, 1, 1, 1, &request.RollbackEnvRequest{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if resp.ID != tt.want {
+				t.Errorf("回滚到部署 %d, 期望 %d", resp.ID, tt.want)
+			}
+			latest := repo.activations.activations[0]
+			if latest.Action != model.ActivationActionRollback || latest.ToDeployID != tt.want {
+				t.Errorf("最新的激活记录 = %+v", latest)
+			}
+		})
+	}
+}
+
+func TestRollbackEnvToDeploy(t *testing.T) {
+	active := int8(1)
+	repo := &fakeProjectDeployRepo{activations: &fakeActivationRepo{}, deploys: map[uint]*model.ProjectEnvDeploy{
+		1: {ID: 1, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusSuperseded},
+		2: {ID: 2, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusActive, IsActive: &active},
+		3: {ID: 3, ProjectID: 1, ProjectEnvID: 1, Status: model.DeployStatusFailed},
+		4: {ID: 4, ProjectID: 1, ProjectEnvID: 2, Status: model.DeployStatusReady},
+	}}
+	s := newTestDeployService()
+	s.projectDeployRepo = repo
+	s.activationRepo = repo.activations
+	s.projectEnvRepo = &fakeProjectEnvRepo{envs: map[uint]*model.ProjectEnv{1: {ID: 1, ProjectID: 1}, 3: {ID: 3, ProjectID: 2}}}
+
+	tests := []struct {
+		name     string
+		envID    uint
+		deployID uint
+		wantErr  error
+	}{
+		{"回滚到指定的部署", 1, 1, nil},
+		{"部署未就绪", 1, 3, ErrDeployNotReady},
+		{"部署属于其他环境", 1, 4, ErrDeployNotFound},
+		{"部署不存在", 1, 99, ErrDeployNotFound},
+		{"环境属于其他项目", 3, 1, ErrEnvNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployID := tt.deployID
+			_, err := s.RollbackEnv(context.Background(), 1, tt.envID, 1, &request.RollbackEnvRequest{DeployID: &deployID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, 期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpireUploading(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStorage(t)
+	deployRepo := &fakeProjectDeployRepo{}
+	s := newTestDeployService()
+	s.storage = store
+	s.projectDeployRepo = deployRepo
+	s.projectEnvRepo = &fakeProjectEnvRepo{envs: map[uint]*model.ProjectEnv{1: {ID: 1, ProjectID: 1}}}
+
+	sum := sha256.Sum256([]byte("a"))
+	checksum := hex.EncodeToString(sum[:])
+	req := &request.CreateManifestDeployRequest{Files: []*request.ManifestFile{
+		{Path: "index.html", SHA256: checksum, Size: 1},
+	}}
+	var ids []uint
+	for i := 0; i < 3; i++ {
+		manifest, err := s.CreateManifestDeploy(ctx, 1, 1, 1, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, manifest.Deploy.ID)
+	}
+	stale, uploaded, fresh := ids[0], ids[1], ids[2]
+	deployRepo.stale = map[uint]bool{stale: true, uploaded: true}
+
+	// 上传文件内容后刷新更新时间，不会过期
+	if err := s.UploadBlob(ctx, 1, uploaded, checksum, strings.NewReader("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := s.ExpireUploading(ctx, time.Hour)
+	if err != nil || count != 1 {
+		t.Fatalf("ExpireUploading = %d, %v, 期望 1", count, err)
+	}
+	if deploy := deployRepo.deploys[stale]; deploy.Status != model.DeployStatusFailed || deploy.FailReason == nil || *deploy.FailReason != ReasonUploadExpired {
+		t.Errorf("过期的部署 = %+v", deploy)
+	}
+	if _, err := store.Storage.Stat(ctx, pendingManifestKey(stale)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("过期后应删除文件清单, err = %v", err)
+	}
+	for _, id := range []uint{uploaded, fresh} {
+		if deploy := deployRepo.deploys[id]; deploy.Status != model.DeployStatusUploading {
+			t.Errorf("部署 %d 状态 = %s, 期望 uploading", id, deploy.Status)
+		}
+		if _, err := store.Storage.Stat(ctx, pendingManifestKey(id)); err != nil {
+			t.Errorf("部署 %d 的文件清单不应删除: %v", id, err)
+		}
+	}
+
+	// 过期的部署不能继续上传或提交
+	if _, err := s.FinalizeManifestDeploy(ctx, 1, stale); !errors.Is(err, ErrNotUploading) {
+		t.Errorf("提交过期的部署 err = %v, 期望 %v", err, ErrNotUploading)
+	}
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"pubfree-platform/pubfree-server/internal/dto/request"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGrayRuleNotFound = errors.New("灰度规则不存在")
+	ErrInvalidGrayRule  = errors.New("无效的灰度规则")
+	ErrGrayRuleConflict = errors.New("该生产环境已配置了其他灰度环境")
+	ErrNoGrayDeploy     = errors.New("灰度环境没有激活的部署")
+)
+
+// GrayService 管理灰度环境的分流规则，规则配置在灰度环境上，指向同一项目的生产环境
+type GrayService interface {
+	GetRule(ctx context.Context, projectID, grayEnvID uint) (*response.GrayRuleResponse, error)
+	SaveRule(ctx context.Context, projectID, grayEnvID, userID uint, req *request.SaveGrayRuleRequest) (*response.GrayRuleResponse, error)
+	// UpdatePercentage 只调整流量比例，用于逐步放量
+	UpdatePercentage(ctx context.Context, projectID, grayEnvID, userID uint, req *request.UpdateGrayPercentageRequest) (*response.GrayRuleResponse, error)
+	// Promote 将灰度环境当前激活的部署发布到生产环境，并将灰度流量比例归零
+	Promote(ctx context.Context, projectID, grayEnvID, userID uint) (*response.ProjectDeployResponse, error)
+}
+
+type grayService struct {
+	grayRuleRepo      repository.GrayRuleRepository
+	projectEnvRepo    repository.ProjectEnvRepository
+	projectDeployRepo repository.ProjectDeployRepository
+	previewService    PreviewService
+}
+
+func NewGrayService(
+	grayRuleRepo repository.GrayRuleRepository,
+	projectEnvRepo repository.ProjectEnvRepository,
+	projectDeployRepo repository.ProjectDeployRepository,
+	previewService PreviewService,
+) GrayService {
+	return &grayService{
+		grayRuleRepo:      grayRuleRepo,
+		projectEnvRepo:    projectEnvRepo,
+		projectDeployRepo: projectDeployRepo,
+		previewService:    previewService,
+	}
+}
+
+func (s *grayService) GetRule(ctx context.Context, projectID, grayEnvID uint) (*response.GrayRuleResponse, error) {
+	rule, err := s.getRule(ctx, projectID, grayEnvID)
+	if err != nil {
+		return nil, err
+	}
+	return grayRuleModelToResponse(rule), nil
+}
+
+func (s *grayService) SaveRule(ctx context.Context, projectID, grayEnvID, userID uint, req *request.SaveGrayRuleRequest) (*response.GrayRuleResponse, error) {
+	grayEnv, err := s.getEnv(ctx, projectID, grayEnvID)
+	if err != nil {
+		return nil, err
+	}
+	if grayEnv.EnvType != model.EnvTypeGray {
+		return nil, fmt.Errorf("%w: 只能在灰度类型的环境上配置", ErrInvalidGrayRule)
+	}
+
+	prodEnv, err := s.getEnv(ctx, projectID, req.ProdEnvID)
+	if err != nil {
+		return nil, err
+	}
+	if prodEnv.EnvType != model.EnvTypeProd {
+		return nil, fmt.Errorf("%w: 分流的目标必须是生产环境", ErrInvalidGrayRule)
+	}
+
+	existing, err := s.grayRuleRepo.GetByProdEnvID(ctx, prodEnv.ID)
+	switch {
+	case err == nil && existing.GrayEnvID != grayEnvID:
+		return nil, ErrGrayRuleConflict
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	rule, err := s.grayRuleRepo.GetByGrayEnvID(ctx, grayEnvID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		rule = &model.GrayRule{ProjectID: projectID, GrayEnvID: grayEnvID}
+	}
+
+	rule.ProdEnvID = prodEnv.ID
+	rule.Percentage = *req.Percentage
+	rule.ForceHeader = strings.TrimSpace(req.ForceHeader)
+	if rule.ForceHeader != "" {
+		rule.ForceHeader = http.CanonicalHeaderKey(rule.ForceHeader)
+	}
+	rule.ForceCookie = strings.TrimSpace(req.ForceCookie)
+	rule.UserIDCookie = strings.TrimSpace(req.UserIDCookie)
+	rule.AllowUserIDs = req.AllowUserIDs
+	rule.OperatorID = userID
+	if err := s.grayRuleRepo.Save(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return grayRuleModelToResponse(rule), nil
+}
+
+func (s *grayService) UpdatePercentage(ctx context.Context, projectID, grayEnvID, userID uint, req *request.UpdateGrayPercentageRequest) (*response.GrayRuleResponse, error) {
+	rule, err := s.getRule(ctx, projectID, grayEnvID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.Percentage = *req.Percentage
+	rule.OperatorID = userID
+	if err := s.grayRuleRepo.Save(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	return grayRuleModelToResponse(rule), nil
+}
+
+func (s *grayService) Promote(ctx context.Context, projectID, grayEnvID, userID uint) (*response.ProjectDeployResponse, error) {
+	rule, err := s.getRule(ctx, projectID, grayEnvID)
+	if err != nil {
+		return nil, err
+	}
+
+	grayDeploy, err := s.projectDeployRepo.GetActiveByEnvID(ctx, grayEnvID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoGrayDeploy
+		}
+		return nil, err
+	}
+
+	// 在生产环境创建一条沿用灰度部署文件的部署记录，生产环境的激活历史和回滚照常工作
+	sourceID := grayDeploy.StorageDeployID()
+	remark := fmt.Sprintf("由灰度部署 #%d 发布", grayDeploy.ID)
+	deploy := &model.ProjectEnvDeploy{
+		ProjectID:        projectID,
+		ProjectEnvID:     rule.ProdEnvID,
+		Remark:           &remark,
+		TargetType:       grayDeploy.TargetType,
+		Target:           grayDeploy.Target,
+		Checksum:         grayDeploy.Checksum,
+		Size:             grayDeploy.Size,
+		FileCount:        grayDeploy.FileCount,
+		Status:           model.DeployStatusReady,
+		Rules:            grayDeploy.Rules,
+		SourceDeployID:   &sourceID,
+		ContentAddressed: grayDeploy.ContentAddressed,
+		CreateUserID:     userID,
+		ActionUserID:     userID,
+	}
+	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
+		return nil, err
+	}
+
+	activated, err := s.projectDeployRepo.Activate(ctx, deploy, userID, model.ActivationActionPromote)
+	if err != nil {
+		return nil, err
+	}
+	if !activated {
+		return nil, ErrDeployNotReady
+	}
+
+	rule.Percentage = 0
+	rule.OperatorID = userID
+	if err := s.grayRuleRepo.Save(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	deploy, err = s.projectDeployRepo.GetByID(ctx, deploy.ID)
+	if err != nil {
+		return nil, err
+	}
+	resp := deployModelToResponse(deploy)
+	s.previewService.Fill(ctx, resp)
+	return resp, nil
+}
+
+// getRule 获取灰度环境上的规则，环境不属于该项目时视为不存在
+func (s *grayService) getRule(ctx context.Context, projectID, grayEnvID uint) (*model.GrayRule, error) {
+	if _, err := s.getEnv(ctx, projectID, grayEnvID); err != nil {
+		return nil, err
+	}
+
+	rule, err := s.grayRuleRepo.GetByGrayEnvID(ctx, grayEnvID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGrayRuleNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *grayService) getEnv(ctx context.Context, projectID, envID uint) (*model.ProjectEnv, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+	return env, nil
+}
+
+func grayRuleModelToResponse(rule *model.GrayRule) *response.GrayRuleResponse {
+	return &response.GrayRuleResponse{
+		ID:           rule.ID,
+		ProjectID:    rule.ProjectID,
+		ProdEnvID:    rule.ProdEnvID,
+		GrayEnvID:    rule.GrayEnvID,
+		Percentage:   rule.Percentage,
+		ForceHeader:  rule.ForceHeader,
+		ForceCookie:  rule.ForceCookie,
+		UserIDCookie: rule.UserIDCookie,
+		AllowUserIDs: rule.AllowUserIDs,
+		OperatorID:   rule.OperatorID,
+		CreatedAt:    rule.CreatedAt,
+		UpdatedAt:    rule.UpdatedAt,
+	}
+}
@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"errors"
 	"pubfree-platform/pubfree-server/internal/dto/request"
 	"pubfree-platform/pubfree-server/internal/dto/response"
 	"pubfree-platform/pubfree-server/internal/model"
 	"pubfree-platform/pubfree-server/internal/repository"
+
+	"gorm.io/gorm"
 )
 
 type GroupService interface {
@@ -15,19 +18,21 @@ type GroupService interface {
 	DeleteGroup(ctx context.Context, id uint) error
 	ListGroups(ctx context.Context, page, pageSize int) ([]*response.GroupResponse, int64, error)
 	GetGroupMembers(ctx context.Context, groupID uint) ([]*response.GroupMemberResponse, error)
-	AddGroupMember(ctx context.Context, groupID uint, req *request.AddGroupMemberRequest) (*response.GroupMemberResponse, error)
-	RemoveGroupMember(ctx context.Context, groupID, userID uint) error
+	AddGroupMember(ctx context.Context, groupID, operatorID uint, req *request.AddGroupMemberRequest) (*response.GroupMemberResponse, error)
+	RemoveGroupMember(ctx context.Context, groupID, operatorID, userID uint) error
 }
 
 type groupService struct {
-	groupRepo       repository.GroupRepository
-	groupMemberRepo repository.GroupMemberRepository
+	groupRepo         repository.GroupRepository
+	groupMemberRepo   repository.GroupMemberRepository
+	permissionService PermissionService
 }
 
-func NewGroupService(groupRepo repository.GroupRepository, groupMemberRepo repository.GroupMemberRepository) GroupService {
+func NewGroupService(groupRepo repository.GroupRepository, groupMemberRepo repository.GroupMemberRepository, permissionService PermissionService) GroupService {
 	return &groupService{
-		groupRepo:       groupRepo,
-		groupMemberRepo: groupMemberRepo,
+		groupRepo:         groupRepo,
+		groupMemberRepo:   groupMemberRepo,
+		permissionService: permissionService,
 	}
 }
 
@@ -47,7 +52,7 @@ func (s *groupService) CreateGroup(ctx context.Context, userID uint, req *reques
 	member := &model.GroupMember{
 		GroupID: group.ID,
 		UserID:  userID,
-		Role:    1, // 管理员角色
+		Role:    model.RoleOwner,
 	}
 	_ = s.groupMemberRepo.Create(ctx, member)
 
@@ -120,8 +125,41 @@ func (s *groupService) GetGroupMembers(ctx context.Context, groupID uint) ([]*re
 	return responses, nil
 }
 
-func (s *groupService) AddGroupMember(ctx context.Context, groupID uint, req *request.AddGroupMemberRequest) (*response.GroupMemberResponse, error) {
-	member := &model.GroupMember{
+func (s *groupService) AddGroupMember(ctx context.Context, groupID, operatorID uint, req *request.AddGroupMemberRequest) (*response.GroupMemberResponse, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserID == group.OwnerID {
+		return nil, errors.New("不能修改空间所有者的角色")
+	}
+
+	operatorRole, err := s.permissionService.GroupRole(ctx, groupID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已是成员时修改其角色
+	member, err := s.groupMemberRepo.GetByGroupIDAndUserID(ctx, groupID, req.UserID)
+	if err == nil {
+		if err := checkMemberChange(operatorRole, member.Role, req.Role); err != nil {
+			return nil, err
+		}
+		member.Role = req.Role
+		if err := s.groupMemberRepo.Update(ctx, member); err != nil {
+			return nil, err
+		}
+		return s.memberModelToResponse(member), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := checkMemberChange(operatorRole, 0, req.Role); err != nil {
+		return nil, err
+	}
+
+	member = &model.GroupMember{
 		GroupID: groupID,
 		UserID:  req.UserID,
 		Role:    req.Role,
@@ -134,7 +172,31 @@ func (s *groupService) AddGroupMember(ctx context.Context, groupID uint, req *re
 	return s.memberModelToResponse(member), nil
 }
 
-func (s *groupService) RemoveGroupMember(ctx context.Context, groupID, userID uint) error {
+func (s *groupService) RemoveGroupMember(ctx context.Context, groupID, operatorID, userID uint) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if userID == group.OwnerID {
+		return errors.New("不能移除空间所有者")
+	}
+
+	member, err := s.groupMemberRepo.GetByGroupIDAndUserID(ctx, groupID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("成员不存在")
+		}
+		return err
+	}
+
+	operatorRole, err := s.permissionService.GroupRole(ctx, groupID, operatorID)
+	if err != nil {
+		return err
+	}
+	if err := checkMemberChange(operatorRole, member.Role, 0); err != nil {
+		return err
+	}
+
 	return s.groupMemberRepo.DeleteByGroupIDAndUserID(ctx, groupID, userID)
 }
 
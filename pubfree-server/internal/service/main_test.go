@@ -0,0 +1,14 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/pkg/logger"
+)
+
+func TestMain(m *testing.M) {
+	logger.Init(config.LoggerConfig{Level: "panic"})
+	os.Exit(m.Run())
+}
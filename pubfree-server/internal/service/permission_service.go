@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrForbidden       = errors.New("没有权限执行该操作")
+	ErrProjectNotFound = errors.New("项目不存在")
+	ErrGroupNotFound   = errors.New("空间不存在")
+)
+
+// Action 项目或空间上的操作
+type Action string
+
+const (
+	ActionView          Action = "view"           // 浏览项目、环境、部署记录
+	ActionDeploy        Action = "deploy"         // 发布、激活部署
+	ActionManageEnv     Action = "manage_env"     // 创建环境、绑定域名
+	ActionCreateProject Action = "create_project" // 在空间下创建项目
+	ActionManageMembers Action = "manage_members" // 添加、修改、移除成员
+	ActionManageTokens  Action = "manage_tokens"  // 管理部署令牌
+	ActionUpdate        Action = "update"         // 修改项目或空间详情
+	ActionDelete        Action = "delete"         // 删除项目或空间
+	ActionTransfer      Action = "transfer"       // 转移项目
+)
+
+// actionMinRole 各操作所需的最低角色
+var actionMinRole = map[Action]model.Role{
+	ActionView:          model.RoleGuest,
+	ActionDeploy:        model.RoleDeveloper,
+	ActionManageEnv:     model.RoleDeveloper,
+	ActionCreateProject: model.RoleDeveloper,
+	ActionManageMembers: model.RoleMaster,
+	ActionManageTokens:  model.RoleMaster,
+	ActionUpdate:        model.RoleOwner,
+	ActionDelete:        model.RoleOwner,
+	ActionTransfer:      model.RoleOwner,
+}
+
+// RoleAllows 判断角色是否允许执行操作，未知操作一律拒绝
+func RoleAllows(role model.Role, action Action) bool {
+	minRole, ok := actionMinRole[action]
+	return ok && role >= minRole
+}
+
+// 成员权限来源
+const (
+	MemberSourceDirect = "direct" // 项目成员
+	MemberSourceGroup  = "group"  // 继承自项目所属空间
+)
+
+// ProjectMemberRole 用户在项目中的有效角色及其来源
+type ProjectMemberRole struct {
+	UserID        uint
+	User          model.User
+	Role          model.Role           // 有效角色，取直接角色与继承角色中较高者
+	Source        string               // 有效角色的来源，两者相同时视为直接成员
+	DirectRole    model.Role           // 项目成员角色，非直接成员为0
+	InheritedRole model.Role           // 继承自空间的角色，未继承为0
+	GroupID       *uint                // 继承来源的空间ID
+	Member        *model.ProjectMember // 项目成员记录，非直接成员为nil
+}
+
+type PermissionService interface {
+	// ProjectRole 返回用户在项目中的有效角色（直接角色与空间继承角色取较高者），非成员返回0
+	ProjectRole(ctx context.Context, projectID, userID uint) (model.Role, error)
+	// ProjectMembers 返回项目的全部有效成员，包括继承自空间的成员
+	ProjectMembers(ctx context.Context, projectID uint) ([]*ProjectMemberRole, error)
+	// GroupRole 返回用户在空间中的角色，非成员返回0
+	GroupRole(ctx context.Context, groupID, userID uint) (model.Role, error)
+	CheckProject(ctx context.Context, projectID, userID uint, action Action) error
+	CheckGroup(ctx context.Context, groupID, userID uint, action Action) error
+	// IsPlatformAdmin 判断用户是否为平台管理员。构建命令等会在服务器上执行的设置只允许平台管理员修改
+	IsPlatformAdmin(userID uint) bool
+}
+
+type permissionService struct {
+	projectRepo       repository.ProjectRepository
+	projectMemberRepo repository.ProjectMemberRepository
+	groupRepo         repository.GroupRepository
+	groupMemberRepo   repository.GroupMemberRepository
+	admins            map[uint]bool
+}
+
+// NewPermissionService adminIDs 为平台管理员的用户ID
+func NewPermissionService(
+	projectRepo repository.ProjectRepository,
+	projectMemberRepo repository.ProjectMemberRepository,
+	groupRepo repository.GroupRepository,
+	groupMemberRepo repository.GroupMemberRepository,
+	adminIDs []uint,
+) PermissionService {
+	admins := make(map[uint]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		admins[id] = true
+	}
+	return &permissionService{
+		projectRepo:       projectRepo,
+		projectMemberRepo: projectMemberRepo,
+		groupRepo:         groupRepo,
+		groupMemberRepo:   groupMemberRepo,
+		admins:            admins,
+	}
+}
+
+func (s *permissionService) IsPlatformAdmin(userID uint) bool {
+	return userID != 0 && s.admins[userID]
+}
+
+func (s *permissionService) ProjectRole(ctx context.Context, projectID, userID uint) (model.Role, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrProjectNotFound
+		}
+		return 0, err
+	}
+
+	// 项目所有者始终拥有 Owner 角色
+	if project.OwnerID == userID {
+		return model.RoleOwner, nil
+	}
+
+	var role model.Role
+	member, err := s.projectMemberRepo.GetByProjectIDAndUserID(ctx, projectID, userID)
+	switch {
+	case err == nil:
+		role = member.Role
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	// 空间成员在空间下的项目中至少拥有其空间角色
+	if project.GroupID != nil {
+		groupRole, err := s.GroupRole(ctx, *project.GroupID, userID)
+		if err != nil && !errors.Is(err, ErrGroupNotFound) {
+			return 0, err
+		}
+		role = max(role, groupRole)
+	}
+
+	return role, nil
+}
+
+func (s *permissionService) ProjectMembers(ctx context.Context, projectID uint) ([]*ProjectMemberRole, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+
+	members, err := s.projectMemberRepo.ListByProjectID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	byUser := make(map[uint]*ProjectMemberRole)
+	var result []*ProjectMemberRole
+	for _, member := range members {
+		entry := &ProjectMemberRole{
+			UserID:     member.UserID,
+			User:       member.User,
+			Role:       member.Role,
+			Source:     MemberSourceDirect,
+			DirectRole: member.Role,
+			Member:     member,
+		}
+		byUser[member.UserID] = entry
+		result = append(result, entry)
+	}
+
+	if project.GroupID != nil {
+		groupMembers, err := s.groupMemberRepo.ListByGroupID(ctx, *project.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		for _, groupMember := range groupMembers {
+			entry, ok := byUser[groupMember.UserID]
+			if !ok {
+				entry = &ProjectMemberRole{
+					UserID: groupMember.UserID,
+					User:   groupMember.User,
+				}
+				byUser[groupMember.UserID] = entry
+				result = append(result, entry)
+			}
+			entry.InheritedRole = groupMember.Role
+			entry.GroupID = project.GroupID
+			if groupMember.Role > entry.Role {
+				entry.Role = groupMember.Role
+				entry.Source = MemberSourceGroup
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Role > result[j].Role
+	})
+	return result, nil
+}
+
+func (s *permissionService) GroupRole(ctx context.Context, groupID, userID uint) (model.Role, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrGroupNotFound
+		}
+		return 0, err
+	}
+
+	if group.OwnerID == userID {
+		return model.RoleOwner, nil
+	}
+
+	member, err := s.groupMemberRepo.GetByGroupIDAndUserID(ctx, groupID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return member.Role, nil
+}
+
+func (s *permissionService) CheckProject(ctx context.Context, projectID, userID uint, action Action) error {
+	role, err := s.ProjectRole(ctx, projectID, userID)
+	if err != nil {
+		return err
+	}
+	if !RoleAllows(role, action) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *permissionService) CheckGroup(ctx context.Context, groupID, userID uint, action Action) error {
+	role, err := s.GroupRole(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if !RoleAllows(role, action) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// checkMemberChange 校验成员变更，不能修改比自己角色高的成员，也不能授予比自己高的角色。
+// newRole 为0表示移除成员
+func checkMemberChange(operatorRole, currentRole, newRole model.Role) error {
+	if currentRole > operatorRole || newRole > operatorRole {
+		return ErrForbidden
+	}
+	return nil
+}
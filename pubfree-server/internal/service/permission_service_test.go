@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type fakeProjectRepo struct {
+	repository.ProjectRepository
+	projects map[uint]*model.Project
+}
+
+func (r *fakeProjectRepo) GetByID(ctx context.Context, id uint) (*model.Project, error) {
+	project, ok := r.projects[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return project, nil
+}
+
+type fakeProjectMemberRepo struct {
+	repository.ProjectMemberRepository
+	members []*model.ProjectMember
+}
+
+func (r *fakeProjectMemberRepo) GetByProjectIDAndUserID(ctx context.Context, projectID, userID uint) (*model.ProjectMember, error) {
+	for _, member := range r.members {
+		if member.ProjectID == projectID && member.UserID == userID {
+			return member, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeProjectMemberRepo) ListByProjectID(ctx context.Context, projectID uint) ([]*model.ProjectMember, error) {
+	var members []*model.ProjectMember
+	for _, member := range r.members {
+		if member.ProjectID == projectID {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+type fakeGroupRepo struct {
+	repository.GroupRepository
+	groups map[uint]*model.Group
+}
+
+func (r *fakeGroupRepo) GetByID(ctx context.Context, id uint) (*model.Group, error) {
+	group, ok := r.groups[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return group, nil
+}
+
+type fakeGroupMemberRepo struct {
+	repository.GroupMemberRepository
+	members []*model.GroupMember
+}
+
+func (r *fakeGroupMemberRepo) GetByGroupIDAndUserID(ctx context.Context, groupID, userID uint) (*model.GroupMember, error) {
+	for _, member := range r.members {
+		if member.GroupID == groupID && member.UserID == userID {
+			return member, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeGroupMemberRepo) ListByGroupID(ctx context.Context, groupID uint) ([]*model.GroupMember, error) {
+	var members []*model.GroupMember
+	for _, member := range r.members {
+		if member.GroupID == groupID {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+// 用户：1 项目所有者，2 项目 Developer，3 空间 Master，4 项目 Guest 且空间 Developer，5 空间所有者，9 无关用户
+func newTestPermissionService() PermissionService {
+	groupID := uint(10)
+	projects := &fakeProjectRepo{projects: map[uint]*model.Project{
+		1: {ID: 1, OwnerID: 1, GroupID: &groupID},
+		2: {ID: 2, OwnerID: 1},
+	}}
+	projectMembers := &fakeProjectMemberRepo{members: []*model.ProjectMember{
+		{ProjectID: 1, UserID: 2, Role: model.RoleDeveloper},
+		{ProjectID: 1, UserID: 4, Role: model.RoleGuest},
+		{ProjectID: 2, UserID: 2, Role: model.RoleGuest},
+	}}
+	groups := &fakeGroupRepo{groups: map[uint]*model.Group{10: {ID: 10, OwnerID: 5}}}
+	groupMembers := &fakeGroupMemberRepo{members: []*model.GroupMember{
+		{GroupID: 10, UserID: 3, Role: model.RoleMaster},
+		{GroupID: 10, UserID: 4, Role: model.RoleDeveloper},
+	}}
+	return NewPermissionService(projects, projectMembers, groups, groupMembers, []uint{9})
+}
+
+func TestIsPlatformAdmin(t *testing.T) {
+	s := newTestPermissionService()
+	for userID, want := range map[uint]bool{9: true, 1: false, 0: false} {
+		if got := s.IsPlatformAdmin(userID); got != want {
+			t.Errorf("IsPlatformAdmin(%d) = %v, 期望 %v", userID, got, want)
+		}
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role   model.Role
+		action Action
+		want   bool
+	}{
+		{model.RoleGuest, ActionView, true},
+		{model.RoleGuest, ActionDeploy, false},
+		{model.RoleDeveloper, ActionDeploy, true},
+		{model.RoleDeveloper, ActionManageEnv, true},
+		{model.RoleDeveloper, ActionManageMembers, false},
+		{model.RoleMaster, ActionManageMembers, true},
+		{model.RoleMaster, ActionManageTokens, true},
+		{model.RoleMaster, ActionDelete, false},
+		{model.RoleOwner, ActionTransfer, true},
+		{model.RoleOwner, Action("unknown"), false},
+		{0, ActionView, false},
+	}
+	for _, tt := range tests {
+		if got := RoleAllows(tt.role, tt.action); got != tt.want {
+			t.Errorf("RoleAllows(%v, %s) = %v, want %v", tt.role, tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestProjectRole(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestPermissionService()
+
+	tests := []struct {
+		name      string
+		projectID uint
+		userID    uint
+		want      model.Role
+		wantErr   error
+	}{
+		{"owner", 1, 1, model.RoleOwner, nil},
+		{"direct member", 1, 2, model.RoleDeveloper, nil},
+		{"inherited from group", 1, 3, model.RoleMaster, nil},
+		{"higher of direct and inherited", 1, 4, model.RoleDeveloper, nil},
+		{"group owner", 1, 5, model.RoleOwner, nil},
+		{"not a member", 1, 9, 0, nil},
+		{"project without group", 2, 3, 0, nil},
+		{"direct member without group", 2, 2, model.RoleGuest, nil},
+		{"missing project", 99, 1, 0, ErrProjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.ProjectRole(ctx, tt.projectID, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProjectRole() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ProjectRole() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckProject(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestPermissionService()
+
+	tests := []struct {
+		name    string
+		userID  uint
+		action  Action
+		wantErr error
+	}{
+		{"developer deploys", 2, ActionDeploy, nil},
+		{"developer cannot manage members", 2, ActionManageMembers, ErrForbidden},
+		{"group master manages members", 3, ActionManageMembers, nil},
+		{"group master cannot delete", 3, ActionDelete, ErrForbidden},
+		{"outsider cannot view", 9, ActionView, ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.CheckProject(ctx, 1, tt.userID, tt.action); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckProject() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectMembersMergesInherited(t *testing.T) {
+	members, err := newTestPermissionService().ProjectMembers(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byUser := map[uint]*ProjectMemberRole{}
+	for _, member := range members {
+		byUser[member.UserID] = member
+	}
+	if len(byUser) != 3 {
+		t.Fatalf("got %d members, want 3", len(byUser))
+	}
+	if m := byUser[3]; m.Role != model.RoleMaster || m.Source != MemberSourceGroup || m.Member != nil {
+		t.Errorf("user 3 = %+v, want inherited Master", m)
+	}
+	if m := byUser[4]; m.Role != model.RoleDeveloper || m.Source != MemberSourceGroup || m.DirectRole != model.RoleGuest {
+		t.Errorf("user 4 = %+v, want Developer inherited over direct Guest", m)
+	}
+	if m := byUser[2]; m.Role != model.RoleDeveloper || m.Source != MemberSourceDirect {
+		t.Errorf("user 2 = %+v, want direct Developer", m)
+	}
+	for i := 1; i < len(members); i++ {
+		if members[i].Role > members[i-1].Role {
+			t.Fatalf("members not sorted by role: %v before %v", members[i-1].Role, members[i].Role)
+		}
+	}
+}
+
+func TestCheckMemberChange(t *testing.T) {
+	tests := []struct {
+		name                            string
+		operatorRole, currentRole, role model.Role
+		denied                          bool
+	}{
+		{name: "master adds developer", operatorRole: model.RoleMaster, currentRole: 0, role: model.RoleDeveloper},
+		{name: "master promotes to master", operatorRole: model.RoleMaster, currentRole: model.RoleDeveloper, role: model.RoleMaster},
+		{name: "master cannot grant owner", operatorRole: model.RoleMaster, currentRole: model.RoleDeveloper, role: model.RoleOwner, denied: true},
+		{name: "master cannot remove owner", operatorRole: model.RoleMaster, currentRole: model.RoleOwner, role: 0, denied: true},
+		{name: "owner removes master", operatorRole: model.RoleOwner, currentRole: model.RoleMaster, role: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMemberChange(tt.operatorRole, tt.currentRole, tt.role)
+			if denied := errors.Is(err, ErrForbidden); denied != tt.denied {
+				t.Errorf("checkMemberChange() error = %v, want denied=%v", err, tt.denied)
+			}
+		})
+	}
+}
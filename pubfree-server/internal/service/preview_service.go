@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/logger"
+)
+
+const (
+	// previewSubdomain 预览域名位于基础域名的该子域名下
+	previewSubdomain = "preview"
+	// previewSeparator 预览域名中部署ID与项目标签的分隔符
+	previewSeparator = "--"
+	// maxLabelLength DNS 单个标签的最大长度
+	maxLabelLength = 63
+)
+
+// PreviewService 生成部署的预览地址，预览地址不随激活状态变化，可在切换流量前验证部署
+type PreviewService interface {
+	// Fill 为部署响应填充预览地址，未配置预览域名时不做处理
+	Fill(ctx context.Context, deploys ...*response.ProjectDeployResponse)
+}
+
+type previewService struct {
+	projectRepo repository.ProjectRepository
+	config      config.PreviewConfig
+}
+
+func NewPreviewService(projectRepo repository.ProjectRepository, previewConfig config.PreviewConfig) PreviewService {
+	return &previewService{
+		projectRepo: projectRepo,
+		config:      previewConfig,
+	}
+}
+
+func (s *previewService) Fill(ctx context.Context, deploys ...*response.ProjectDeployResponse) {
+	if s.config.BaseDomain == "" {
+		return
+	}
+
+	names := make(map[uint]string)
+	for _, deploy := range deploys {
+		name, ok := names[deploy.ProjectID]
+		if !ok {
+			project, err := s.projectRepo.GetByID(ctx, deploy.ProjectID)
+			if err != nil {
+				logger.Logger.Warnf("生成预览地址失败 deploy=%d: %v", deploy.ID, err)
+				continue
+			}
+			name = project.Name
+			names[deploy.ProjectID] = name
+		}
+
+		host := PreviewHost(s.config.BaseDomain, deploy.ID, name)
+		if s.config.Port != "" {
+			host = net.JoinHostPort(host, s.config.Port)
+		}
+		deploy.PreviewURL = s.config.Scheme + "://" + host + "/"
+	}
+}
+
+// PreviewHost 部署的预览域名：<部署ID>--<项目标签>.preview.<baseDomain>
+func PreviewHost(baseDomain string, deployID uint, projectName string) string {
+	return previewLabel(deployID, projectName) + "." + previewSubdomain + "." + strings.ToLower(baseDomain)
+}
+
+// parsePreviewHost 解析预览域名，host 须已经过 NormalizeHost 处理
+func parsePreviewHost(baseDomain, host string) (deployID uint, label string, ok bool) {
+	if baseDomain == "" {
+		return 0, "", false
+	}
+
+	suffix := "." + previewSubdomain + "." + strings.ToLower(baseDomain)
+	label, found := strings.CutSuffix(host, suffix)
+	if !found || label == "" || strings.Contains(label, ".") {
+		return 0, "", false
+	}
+
+	idPart, _, found := strings.Cut(label, previewSeparator)
+	if !found {
+		return 0, "", false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil {
+		return 0, "", false
+	}
+	return uint(id), label, true
+}
+
+// previewLabel 由部署ID和项目名生成域名标签，项目名中域名不允许的字符替换为 "-"，
+// 超长时截断，没有可用字符时使用 "project"
+func previewLabel(deployID uint, projectName string) string {
+	prefix := strconv.FormatUint(uint64(deployID), 10) + previewSeparator
+
+	var b strings.Builder
+	for _, r := range strings.ToLower(projectName) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	slug := strings.Trim(b.String(), "-")
+	if len(slug) > maxLabelLength-len(prefix) {
+		slug = strings.TrimRight(slug[:maxLabelLength-len(prefix)], "-")
+	}
+	if slug == "" {
+		slug = "project"
+	}
+	return prefix + slug
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type fakeProjectDomainRepo struct {
+	repository.ProjectDomainRepository
+	domains map[string]*model.ProjectDomain
+}
+
+func (r *fakeProjectDomainRepo) GetByHost(ctx context.Context, host string) (*model.ProjectDomain, error) {
+	domain, ok := r.domains[host]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return domain, nil
+}
+
+func TestPreviewLabel(t *testing.T) {
+	tests := []struct {
+		deployID uint
+		name     string
+		want     string
+	}{
+		{deployID: 12, name: "web", want: "12--web"},
+		{deployID: 12, name: "My_Site.v2", want: "12--my-site-v2"},
+		{deployID: 12, name: "--官网--", want: "12--project"},
+		{deployID: 12, name: "", want: "12--project"},
+		{deployID: 12, name: strings.Repeat("a", 70), want: "12--" + strings.Repeat("a", 59)},
+		{deployID: 12, name: "a" + strings.Repeat("-", 70), want: "12--a"},
+	}
+	for _, tt := range tests {
+		got := previewLabel(tt.deployID, tt.name)
+		if got != tt.want {
+			t.Errorf("previewLabel(%d, %q) = %q, 期望 %q", tt.deployID, tt.name, got, tt.want)
+		}
+		if len(got) > maxLabelLength {
+			t.Errorf("previewLabel(%d, %q) 长度 %d 超过 %d", tt.deployID, tt.name, len(got), maxLabelLength)
+		}
+	}
+}
+
+func TestParsePreviewHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseDomain string
+		host       string
+		wantID     uint
+		wantLabel  string
+		wantOK     bool
+	}{
+		{name: "预览域名", baseDomain: "pubfree.dev", host: "12--web.preview.pubfree.dev", wantID: 12, wantLabel: "12--web", wantOK: true},
+		{name: "基础域名大小写", baseDomain: "PubFree.Dev", host: "12--web.preview.pubfree.dev", wantID: 12, wantLabel: "12--web", wantOK: true},
+		{name: "未配置基础域名", baseDomain: "", host: "12--web.preview.pubfree.dev"},
+		{name: "其他基础域名", baseDomain: "pubfree.dev", host: "12--web.preview.example.com"},
+		{name: "后缀相同的其他域名", baseDomain: "pubfree.dev", host: "12--web.preview.evilpubfree.dev"},
+		{name: "不在预览子域名下", baseDomain: "pubfree.dev", host: "12--web.pubfree.dev"},
+		{name: "预览子域名本身", baseDomain: "pubfree.dev", host: "preview.pubfree.dev"},
+		{name: "多级标签", baseDomain: "pubfree.dev", host: "a.12--web.preview.pubfree.dev"},
+		{name: "缺少分隔符", baseDomain: "pubfree.dev", host: "12-web.preview.pubfree.dev"},
+		{name: "部署ID不是数字", baseDomain: "pubfree.dev", host: "abc--web.preview.pubfree.dev"},
+		{name: "部署ID为负数", baseDomain: "pubfree.dev", host: "-1--web.preview.pubfree.dev"},
+		{name: "部署ID溢出", baseDomain: "pubfree.dev", host: "99999999999--web.preview.pubfree.dev"},
+		{name: "普通域名", baseDomain: "pubfree.dev", host: "www.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, label, ok := parsePreviewHost(tt.baseDomain, tt.host)
+			if ok != tt.wantOK || id != tt.wantID || label != tt.wantLabel {
+				t.Errorf("parsePreviewHost(%q, %q) = (%d, %q, %v), 期望 (%d, %q, %v)",
+					tt.baseDomain, tt.host, id, label, ok, tt.wantID, tt.wantLabel, tt.wantOK)
+			}
+		})
+	}
+
+	// PreviewHost 生成的域名可以被解析回来
+	host := PreviewHost("PubFree.Dev", 7, "Docs Site")
+	if id, label, ok := parsePreviewHost("pubfree.dev", NormalizeHost(host)); !ok || id != 7 || label != "7--docs-site" {
+		t.Errorf("无法解析 %q: (%d, %q, %v)", host, id, label, ok)
+	}
+}
+
+func newTestSiteService() SiteService {
+	return NewSiteService(
+		&fakeProjectRepo{projects: map[uint]*model.Project{
+			1: {ID: 1, Name: "web"},
+		}},
+		&fakeProjectDomainRepo{domains: map[string]*model.ProjectDomain{}},
+		&fakeProjectEnvRepo{envs: map[uint]*model.ProjectEnv{
+			10: {ID: 10, ProjectID: 1},
+		}},
+		&fakeProjectDeployRepo{deploys: map[uint]*model.ProjectEnvDeploy{
+			100: {ID: 100, ProjectID: 1, ProjectEnvID: 10, Status: model.DeployStatusReady},
+			101: {ID: 101, ProjectID: 1, ProjectEnvID: 10, Status: model.DeployStatusBuilding},
+			102: {ID: 102, ProjectID: 2, ProjectEnvID: 20, Status: model.DeployStatusReady},
+		}},
+		nil,
+		&fakeDeployFileRepo{},
+		"pubfree.dev",
+	)
+}
+
+func TestResolvePreview(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		wantID  uint
+		wantErr error
+	}{
+		{name: "预览域名", host: "100--web.preview.pubfree.dev", wantID: 100},
+		{name: "带端口和大写", host: "100--WEB.Preview.PubFree.dev:8080", wantID: 100},
+		{name: "结尾的点", host: "100--web.preview.pubfree.dev.", wantID: 100},
+		{name: "项目标签不一致", host: "100--other.preview.pubfree.dev", wantErr: ErrSiteNotFound},
+		{name: "部署不存在", host: "999--web.preview.pubfree.dev", wantErr: ErrSiteNotFound},
+		{name: "项目不存在", host: "102--web.preview.pubfree.dev", wantErr: ErrSiteNotFound},
+		{name: "部署未就绪", host: "101--web.preview.pubfree.dev", wantErr: ErrSiteNotReady},
+		{name: "其他基础域名", host: "100--web.preview.example.com", wantErr: ErrSiteNotFound},
+		{name: "未绑定的域名", host: "www.example.com", wantErr: ErrSiteNotFound},
+	}
+	s := newTestSiteService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, err := s.Resolve(context.Background(), tt.host)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Resolve(%q) 错误 = %v, 期望 %v", tt.host, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tt.host, err)
+			}
+			if site.DeployID != tt.wantID || !site.Preview {
+				t.Errorf("Resolve(%q) = deploy %d preview %v, 期望 deploy %d preview true", tt.host, site.DeployID, site.Preview, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestPreviewFill(t *testing.T) {
+	projectRepo := &fakeProjectRepo{projects: map[uint]*model.Project{1: {ID: 1, Name: "web"}}}
+	tests := []struct {
+		name   string
+		config config.PreviewConfig
+		want   string
+	}{
+		{name: "未配置", config: config.PreviewConfig{}, want: ""},
+		{name: "默认端口", config: config.PreviewConfig{BaseDomain: "pubfree.dev", Scheme: "https"}, want: "https://5--web.preview.pubfree.dev/"},
+		{name: "指定端口", config: config.PreviewConfig{BaseDomain: "pubfree.dev", Scheme: "http", Port: "8080"}, want: "http://5--web.preview.pubfree.dev:8080/"},
+	}
+	for _, tt := range tests {
+		deploy := &response.ProjectDeployResponse{ID: 5, ProjectID: 1}
+		missing := &response.ProjectDeployResponse{ID: 6, ProjectID: 2}
+		NewPreviewService(projectRepo, tt.config).Fill(context.Background(), deploy, missing)
+		if deploy.PreviewURL != tt.want {
+			t.Errorf("%s: PreviewURL = %q, 期望 %q", tt.name, deploy.PreviewURL, tt.want)
+		}
+		if missing.PreviewURL != "" {
+			t.Errorf("%s: 项目不存在时不应生成预览地址: %q", tt.name, missing.PreviewURL)
+		}
+	}
+}
@@ -3,33 +3,43 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"pubfree-platform/pubfree-server/internal/dto/request"
 	"pubfree-platform/pubfree-server/internal/dto/response"
 	"pubfree-platform/pubfree-server/internal/model"
 	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/rules"
 	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
+var ErrInvalidCacheRule = errors.New("无效的缓存规则")
+
 type ProjectService interface {
 	CreateProject(ctx context.Context, userID uint, req *request.CreateProjectRequest) (*response.ProjectResponse, error)
 	GetProject(ctx context.Context, id uint) (*response.ProjectResponse, error)
-	UpdateProject(ctx context.Context, id uint, req *request.UpdateProjectRequest) (*response.ProjectResponse, error)
+	UpdateProject(ctx context.Context, id, userID uint, req *request.UpdateProjectRequest) (*response.ProjectResponse, error)
 	DeleteProject(ctx context.Context, id uint) error
 	ListProjects(ctx context.Context, groupID string, page, pageSize int) ([]*response.ProjectResponse, int64, error)
 	GetProjectMembers(ctx context.Context, projectID uint) ([]*response.ProjectMemberResponse, error)
-	AddProjectMember(ctx context.Context, projectID uint, req *request.AddProjectMemberRequest) (*response.ProjectMemberResponse, error)
-	RemoveProjectMember(ctx context.Context, projectID, userID uint) error
+	AddProjectMember(ctx context.Context, projectID, operatorID uint, req *request.AddProjectMemberRequest) (*response.ProjectMemberResponse, error)
+	RemoveProjectMember(ctx context.Context, projectID, operatorID, userID uint) error
+	TransferProject(ctx context.Context, projectID, operatorID uint, req *request.TransferProjectRequest) (*response.ProjectResponse, error)
 
 	// 环境管理
 	CreateProjectEnv(ctx context.Context, projectID, userID uint, req *request.CreateProjectEnvRequest) (*response.ProjectEnvResponse, error)
 	GetProjectEnvs(ctx context.Context, projectID uint) ([]*response.ProjectEnvResponse, error)
+	UpdateProjectEnvRouting(ctx context.Context, projectID, envID uint, req *request.UpdateEnvRoutingRequest) (*response.ProjectEnvResponse, error)
+	UpdateProjectEnvCache(ctx context.Context, projectID, envID uint, req *request.UpdateEnvCacheRequest) (*response.ProjectEnvResponse, error)
+	UpdateProjectBuild(ctx context.Context, id uint, req *request.UpdateBuildSettingsRequest) (*response.ProjectResponse, error)
 
 	// 域名管理
 	CreateProjectDomain(ctx context.Context, projectID uint, req *request.CreateProjectDomainRequest) (*response.ProjectDomainResponse, error)
 	GetProjectDomains(ctx context.Context, projectID uint) ([]*response.ProjectDomainResponse, error)
 
 	// 部署管理
-	CreateProjectDeploy(ctx context.Context, projectID, userID uint, req *request.CreateProjectDeployRequest) (*response.ProjectDeployResponse, error)
 	GetProjectDeploys(ctx context.Context, projectID uint) ([]*response.ProjectDeployResponse, error)
 }
 
@@ -39,6 +49,8 @@ type projectService struct {
 	projectEnvRepo    repository.ProjectEnvRepository
 	projectDomainRepo repository.ProjectDomainRepository
 	projectDeployRepo repository.ProjectDeployRepository
+	permissionService PermissionService
+	previewService    PreviewService
 }
 
 func NewProjectService(
@@ -47,6 +59,8 @@ func NewProjectService(
 	projectEnvRepo repository.ProjectEnvRepository,
 	projectDomainRepo repository.ProjectDomainRepository,
 	projectDeployRepo repository.ProjectDeployRepository,
+	permissionService PermissionService,
+	previewService PreviewService,
 ) ProjectService {
 	return &projectService{
 		projectRepo:       projectRepo,
@@ -54,6 +68,8 @@ func NewProjectService(
 		projectEnvRepo:    projectEnvRepo,
 		projectDomainRepo: projectDomainRepo,
 		projectDeployRepo: projectDeployRepo,
+		permissionService: permissionService,
+		previewService:    previewService,
 	}
 }
 
@@ -63,6 +79,13 @@ func (s *projectService) CreateProject(ctx context.Context, userID uint, req *re
 		return nil, errors.New("项目名已存在")
 	}
 
+	// 在空间下创建项目需要空间的 Developer 及以上角色
+	if req.GroupID != nil {
+		if err := s.permissionService.CheckGroup(ctx, *req.GroupID, userID, ActionCreateProject); err != nil {
+			return nil, err
+		}
+	}
+
 	project := &model.Project{
 		Name:         req.Name,
 		ZhName:       req.ZhName,
@@ -80,7 +103,7 @@ func (s *projectService) CreateProject(ctx context.Context, userID uint, req *re
 	member := &model.ProjectMember{
 		ProjectID: project.ID,
 		UserID:    userID,
-		Role:      1, // 管理员角色
+		Role:      model.RoleOwner,
 	}
 	_ = s.projectMemberRepo.Create(ctx, member)
 
@@ -95,7 +118,7 @@ func (s *projectService) GetProject(ctx context.Context, id uint) (*response.Pro
 	return s.modelToResponse(project), nil
 }
 
-func (s *projectService) UpdateProject(ctx context.Context, id uint, req *request.UpdateProjectRequest) (*response.ProjectResponse, error) {
+func (s *projectService) UpdateProject(ctx context.Context, id, userID uint, req *request.UpdateProjectRequest) (*response.ProjectResponse, error) {
 	project, err := s.projectRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -110,8 +133,13 @@ func (s *projectService) UpdateProject(ctx context.Context, id uint, req *reques
 	if req.Description != nil {
 		project.Description = req.Description
 	}
-	if req.GroupID != nil {
+	if req.GroupID != nil && (project.GroupID == nil || *project.GroupID != *req.GroupID) {
+		// 移入其他空间同样需要目标空间的创建项目权限
+		if err := s.permissionService.CheckGroup(ctx, *req.GroupID, userID, ActionCreateProject); err != nil {
+			return nil, err
+		}
 		project.GroupID = req.GroupID
+		project.Group = nil
 	}
 
 	if err := s.projectRepo.Update(ctx, project); err != nil {
@@ -157,22 +185,56 @@ func (s *projectService) ListProjects(ctx context.Context, groupID string, page,
 	return responses, total, nil
 }
 
+// GetProjectMembers 返回项目成员，包括通过所属空间继承权限的成员
 func (s *projectService) GetProjectMembers(ctx context.Context, projectID uint) ([]*response.ProjectMemberResponse, error) {
-	members, err := s.projectMemberRepo.ListByProjectID(ctx, projectID)
+	members, err := s.permissionService.ProjectMembers(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
 
 	var responses []*response.ProjectMemberResponse
 	for _, member := range members {
-		responses = append(responses, s.memberModelToResponse(member))
+		responses = append(responses, s.memberRoleToResponse(projectID, member))
 	}
 
 	return responses, nil
 }
 
-func (s *projectService) AddProjectMember(ctx context.Context, projectID uint, req *request.AddProjectMemberRequest) (*response.ProjectMemberResponse, error) {
-	member := &model.ProjectMember{
+func (s *projectService) AddProjectMember(ctx context.Context, projectID, operatorID uint, req *request.AddProjectMemberRequest) (*response.ProjectMemberResponse, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserID == project.OwnerID {
+		return nil, errors.New("不能修改项目所有者的角色，请使用转移项目")
+	}
+
+	operatorRole, err := s.permissionService.ProjectRole(ctx, projectID, operatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 已是成员时修改其角色
+	member, err := s.projectMemberRepo.GetByProjectIDAndUserID(ctx, projectID, req.UserID)
+	if err == nil {
+		if err := checkMemberChange(operatorRole, member.Role, req.Role); err != nil {
+			return nil, err
+		}
+		member.Role = req.Role
+		if err := s.projectMemberRepo.Update(ctx, member); err != nil {
+			return nil, err
+		}
+		return s.memberModelToResponse(member), nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := checkMemberChange(operatorRole, 0, req.Role); err != nil {
+		return nil, err
+	}
+
+	member = &model.ProjectMember{
 		ProjectID: projectID,
 		UserID:    req.UserID,
 		Role:      req.Role,
@@ -185,15 +247,67 @@ func (s *projectService) AddProjectMember(ctx context.Context, projectID uint, r
 	return s.memberModelToResponse(member), nil
 }
 
-func (s *projectService) RemoveProjectMember(ctx context.Context, projectID, userID uint) error {
+func (s *projectService) RemoveProjectMember(ctx context.Context, projectID, operatorID, userID uint) error {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if userID == project.OwnerID {
+		return errors.New("不能移除项目所有者，请先转移项目")
+	}
+
+	member, err := s.projectMemberRepo.GetByProjectIDAndUserID(ctx, projectID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("成员不存在")
+		}
+		return err
+	}
+
+	operatorRole, err := s.permissionService.ProjectRole(ctx, projectID, operatorID)
+	if err != nil {
+		return err
+	}
+	if err := checkMemberChange(operatorRole, member.Role, 0); err != nil {
+		return err
+	}
+
 	return s.projectMemberRepo.DeleteByProjectIDAndUserID(ctx, projectID, userID)
 }
 
+func (s *projectService) TransferProject(ctx context.Context, projectID, operatorID uint, req *request.TransferProjectRequest) (*response.ProjectResponse, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserID == project.OwnerID {
+		return nil, errors.New("该用户已是项目所有者")
+	}
+
+	// 只能转移给已有成员，原所有者降级为 Master
+	transferred, err := s.projectRepo.Transfer(ctx, projectID, project.OwnerID, req.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("只能将项目转移给项目成员")
+		}
+		return nil, err
+	}
+	if !transferred {
+		return nil, errors.New("项目所有者已变更，请刷新后重试")
+	}
+
+	project, err = s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return s.modelToResponse(project), nil
+}
+
 func (s *projectService) CreateProjectEnv(ctx context.Context, projectID, userID uint, req *request.CreateProjectEnvRequest) (*response.ProjectEnvResponse, error) {
 	env := &model.ProjectEnv{
 		ProjectID:    projectID,
 		Name:         req.Name,
-		EnvType:      req.EnvType,
+		EnvType:      *req.EnvType,
 		CreateUserID: userID,
 	}
 
@@ -218,11 +332,92 @@ func (s *projectService) GetProjectEnvs(ctx context.Context, projectID uint) ([]
 	return responses, nil
 }
 
+// UpdateProjectEnvRouting 更新环境的路由设置，网关在缓存过期后生效
+func (s *projectService) UpdateProjectEnvRouting(ctx context.Context, projectID, envID uint, req *request.UpdateEnvRoutingRequest) (*response.ProjectEnvResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	env.Routing = model.RoutingSettings{
+		SPAFallback:   req.SPAFallback,
+		TrailingSlash: req.TrailingSlash,
+		CleanURLs:     req.CleanURLs,
+		NotFoundPage:  req.NotFoundPage,
+	}
+	if err := s.projectEnvRepo.Update(ctx, env); err != nil {
+		return nil, err
+	}
+
+	return s.envModelToResponse(env), nil
+}
+
+// UpdateProjectEnvCache 更新环境的缓存设置，网关在缓存过期后生效
+func (s *projectService) UpdateProjectEnvCache(ctx context.Context, projectID, envID uint, req *request.UpdateEnvCacheRequest) (*response.ProjectEnvResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	cacheRules := make([]model.CacheRule, 0, len(req.Rules))
+	for _, rule := range req.Rules {
+		if err := rules.ValidatePattern(rule.Path); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCacheRule, err)
+		}
+		// 值原样写入响应头，不能包含换行
+		cacheControl := strings.TrimSpace(rule.CacheControl)
+		if cacheControl == "" || strings.ContainsAny(cacheControl, "\r\n") {
+			return nil, fmt.Errorf("%w: 路径 %q 的 Cache-Control 无效", ErrInvalidCacheRule, rule.Path)
+		}
+		cacheRules = append(cacheRules, model.CacheRule{Path: rule.Path, CacheControl: cacheControl})
+	}
+
+	env.Cache = model.CacheSettings{Rules: cacheRules}
+	if err := s.projectEnvRepo.Update(ctx, env); err != nil {
+		return nil, err
+	}
+
+	return s.envModelToResponse(env), nil
+}
+
+// UpdateProjectBuild 更新项目的构建配置，仓库地址在构建时校验
+func (s *projectService) UpdateProjectBuild(ctx context.Context, id uint, req *request.UpdateBuildSettingsRequest) (*response.ProjectResponse, error) {
+	project, err := s.projectRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	project.Build = &model.BuildSettings{
+		RepoURL:   strings.TrimSpace(req.RepoURL),
+		Branch:    strings.TrimSpace(req.Branch),
+		Command:   req.Command,
+		OutputDir: strings.Trim(strings.TrimSpace(req.OutputDir), "/"),
+	}
+	if err := s.projectRepo.Update(ctx, project); err != nil {
+		return nil, err
+	}
+
+	return s.modelToResponse(project), nil
+}
+
 func (s *projectService) CreateProjectDomain(ctx context.Context, projectID uint, req *request.CreateProjectDomainRequest) (*response.ProjectDomainResponse, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, req.ProjectEnvID)
+	if err != nil || env.ProjectID != projectID {
+		return nil, ErrEnvNotFound
+	}
+
+	// 网关按域名查找站点，同一域名只能绑定一个环境
+	host := NormalizeHost(req.Host)
+	if _, err := s.projectDomainRepo.GetByHost(ctx, host); err == nil {
+		return nil, ErrDomainTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
 	domain := &model.ProjectDomain{
 		ProjectID:    projectID,
 		ProjectEnvID: req.ProjectEnvID,
-		Host:         req.Host,
+		Host:         host,
 	}
 
 	if err := s.projectDomainRepo.Create(ctx, domain); err != nil {
@@ -246,24 +441,6 @@ func (s *projectService) GetProjectDomains(ctx context.Context, projectID uint)
 	return responses, nil
 }
 
-func (s *projectService) CreateProjectDeploy(ctx context.Context, projectID, userID uint, req *request.CreateProjectDeployRequest) (*response.ProjectDeployResponse, error) {
-	deploy := &model.ProjectEnvDeploy{
-		ProjectID:    projectID,
-		ProjectEnvID: req.ProjectEnvID,
-		Remark:       req.Remark,
-		TargetType:   req.TargetType,
-		Target:       req.Target,
-		CreateUserID: userID,
-		ActionUserID: userID,
-	}
-
-	if err := s.projectDeployRepo.Create(ctx, deploy); err != nil {
-		return nil, err
-	}
-
-	return s.deployModelToResponse(deploy), nil
-}
-
 func (s *projectService) GetProjectDeploys(ctx context.Context, projectID uint) ([]*response.ProjectDeployResponse, error) {
 	deploys, err := s.projectDeployRepo.ListByProjectID(ctx, projectID)
 	if err != nil {
@@ -272,8 +449,9 @@ func (s *projectService) GetProjectDeploys(ctx context.Context, projectID uint)
 
 	var responses []*response.ProjectDeployResponse
 	for _, deploy := range deploys {
-		responses = append(responses, s.deployModelToResponse(deploy))
+		responses = append(responses, deployModelToResponse(deploy))
 	}
+	s.previewService.Fill(ctx, responses...)
 
 	return responses, nil
 }
@@ -288,6 +466,7 @@ func (s *projectService) modelToResponse(project *model.Project) *response.Proje
 		OwnerID:      project.OwnerID,
 		GroupID:      project.GroupID,
 		CreateUserID: project.CreateUserID,
+		Build:        project.Build,
 		CreatedAt:    project.CreatedAt,
 		UpdatedAt:    project.UpdatedAt,
 	}
@@ -327,10 +506,14 @@ func (s *projectService) modelToResponse(project *model.Project) *response.Proje
 
 func (s *projectService) memberModelToResponse(member *model.ProjectMember) *response.ProjectMemberResponse {
 	resp := &response.ProjectMemberResponse{
-		ID:        member.ID,
-		ProjectID: member.ProjectID,
-		UserID:    member.UserID,
-		Role:      member.Role,
+		ID:         member.ID,
+		ProjectID:  member.ProjectID,
+		UserID:     member.UserID,
+		Role:       member.Role,
+		Source:     MemberSourceDirect,
+		DirectRole: member.Role,
+		CreatedAt:  member.CreatedAt,
+		UpdatedAt:  member.UpdatedAt,
 	}
 
 	if member.User.ID != 0 {
@@ -345,12 +528,41 @@ func (s *projectService) memberModelToResponse(member *model.ProjectMember) *res
 	return resp
 }
 
+func (s *projectService) memberRoleToResponse(projectID uint, member *ProjectMemberRole) *response.ProjectMemberResponse {
+	var resp *response.ProjectMemberResponse
+	if member.Member != nil {
+		resp = s.memberModelToResponse(member.Member)
+	} else {
+		resp = &response.ProjectMemberResponse{
+			ProjectID: projectID,
+			UserID:    member.UserID,
+		}
+		if member.User.ID != 0 {
+			resp.User = response.UserResponse{
+				ID:        member.User.ID,
+				Name:      member.User.Name,
+				CreatedAt: member.User.CreatedAt,
+				UpdatedAt: member.User.UpdatedAt,
+			}
+		}
+	}
+
+	resp.Role = member.Role
+	resp.Source = member.Source
+	resp.DirectRole = member.DirectRole
+	resp.InheritedRole = member.InheritedRole
+	resp.GroupID = member.GroupID
+	return resp
+}
+
 func (s *projectService) envModelToResponse(env *model.ProjectEnv) *response.ProjectEnvResponse {
 	resp := &response.ProjectEnvResponse{
 		ID:           env.ID,
 		ProjectID:    env.ProjectID,
 		Name:         env.Name,
 		EnvType:      env.EnvType,
+		Routing:      env.Routing,
+		Cache:        env.Cache,
 		CreateUserID: env.CreateUserID,
 		CreatedAt:    env.CreatedAt,
 		UpdatedAt:    env.UpdatedAt,
@@ -379,18 +591,26 @@ func (s *projectService) domainModelToResponse(domain *model.ProjectDomain) *res
 	}
 }
 
-func (s *projectService) deployModelToResponse(deploy *model.ProjectEnvDeploy) *response.ProjectDeployResponse {
+// deployModelToResponse 部署记录转换为响应，项目服务与部署服务共用
+func deployModelToResponse(deploy *model.ProjectEnvDeploy) *response.ProjectDeployResponse {
 	return &response.ProjectDeployResponse{
-		ID:           deploy.ID,
-		ProjectID:    deploy.ProjectID,
-		ProjectEnvID: deploy.ProjectEnvID,
-		Remark:       deploy.Remark,
-		TargetType:   deploy.TargetType,
-		Target:       deploy.Target,
-		CreateUserID: deploy.CreateUserID,
-		ActionUserID: deploy.ActionUserID,
-		IsActive:     deploy.IsActive,
-		CreatedAt:    deploy.CreatedAt,
-		UpdatedAt:    deploy.UpdatedAt,
+		ID:             deploy.ID,
+		ProjectID:      deploy.ProjectID,
+		ProjectEnvID:   deploy.ProjectEnvID,
+		Remark:         deploy.Remark,
+		TargetType:     deploy.TargetType,
+		Target:         deploy.Target,
+		Checksum:       deploy.Checksum,
+		Size:           deploy.Size,
+		FileCount:      deploy.FileCount,
+		Status:         deploy.Status,
+		FailReason:     deploy.FailReason,
+		Rules:          deploy.Rules,
+		SourceDeployID: deploy.SourceDeployID,
+		CreateUserID:   deploy.CreateUserID,
+		ActionUserID:   deploy.ActionUserID,
+		IsActive:       deploy.IsActive,
+		CreatedAt:      deploy.CreatedAt,
+		UpdatedAt:      deploy.UpdatedAt,
 	}
 }
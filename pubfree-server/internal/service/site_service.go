@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/rules"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSiteNotFound = errors.New("站点不存在")
+	ErrSiteNotReady = errors.New("站点尚未发布")
+	ErrDomainTaken  = errors.New("域名已被使用")
+)
+
+// maxCachedManifests 文件清单缓存的部署数上限
+const maxCachedManifests = 200
+
+// Site 域名当前对应的站点内容
+type Site struct {
+	ProjectID    uint
+	ProjectEnvID uint
+	DeployID     uint
+	Prefix       string // 旧版部署文件在存储中的键前缀，Files 为空时使用
+	// Files 部署的文件清单，键为不带开头 "/" 的路径，文件内容位于 BlobKey(Checksum)
+	Files   map[string]*model.DeployFile
+	Routing model.RoutingSettings
+	Cache   model.CacheSettings
+	Rules   *rules.RuleSet // 当前部署的重定向与响应头规则
+	ModTime time.Time      // 部署的更新时间，作为文件的 Last-Modified
+	Gray    *GraySite      // 生产环境配置了灰度规则且灰度环境有激活部署时不为空
+	Preview bool           // 通过预览域名访问
+}
+
+// GraySite 生产环境上的灰度分流，由网关按规则为每个请求选择站点
+type GraySite struct {
+	Rule *model.GrayRule
+	Site *Site
+}
+
+// SiteService 供静态站点网关按域名查找当前激活的部署
+type SiteService interface {
+	Resolve(ctx context.Context, host string) (*Site, error)
+}
+
+type siteService struct {
+	projectRepo       repository.ProjectRepository
+	projectDomainRepo repository.ProjectDomainRepository
+	projectEnvRepo    repository.ProjectEnvRepository
+	projectDeployRepo repository.ProjectDeployRepository
+	grayRuleRepo      repository.GrayRuleRepository
+	deployFileRepo    repository.DeployFileRepository
+	previewDomain     string
+
+	// 文件清单创建后不再变化，按存储所属的部署缓存
+	mu        sync.RWMutex
+	manifests map[uint]map[string]*model.DeployFile
+}
+
+// NewSiteService 创建站点服务，previewDomain 为预览地址的基础域名，为空时不解析预览域名
+func NewSiteService(
+	projectRepo repository.ProjectRepository,
+	projectDomainRepo repository.ProjectDomainRepository,
+	projectEnvRepo repository.ProjectEnvRepository,
+	projectDeployRepo repository.ProjectDeployRepository,
+	grayRuleRepo repository.GrayRuleRepository,
+	deployFileRepo repository.DeployFileRepository,
+	previewDomain string,
+) SiteService {
+	return &siteService{
+		projectRepo:       projectRepo,
+		projectDomainRepo: projectDomainRepo,
+		projectEnvRepo:    projectEnvRepo,
+		projectDeployRepo: projectDeployRepo,
+		grayRuleRepo:      grayRuleRepo,
+		deployFileRepo:    deployFileRepo,
+		previewDomain:     previewDomain,
+		manifests:         make(map[uint]map[string]*model.DeployFile),
+	}
+}
+
+// NormalizeHost 去掉端口和结尾的点并转为小写
+func NormalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+func (s *siteService) Resolve(ctx context.Context, host string) (*Site, error) {
+	host = NormalizeHost(host)
+	if deployID, label, ok := parsePreviewHost(s.previewDomain, host); ok {
+		return s.previewSite(ctx, deployID, label)
+	}
+
+	domain, err := s.projectDomainRepo.GetByHost(ctx, host)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	site, err := s.envSite(ctx, domain.ProjectEnvID)
+	if err != nil {
+		return nil, err
+	}
+
+	gray, err := s.graySite(ctx, domain.ProjectEnvID)
+	if err != nil {
+		return nil, err
+	}
+	site.Gray = gray
+	return site, nil
+}
+
+// envSite 返回环境当前激活部署对应的站点
+func (s *siteService) envSite(ctx context.Context, envID uint) (*Site, error) {
+	env, err := s.projectEnvRepo.GetByID(ctx, envID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	deploy, err := s.projectDeployRepo.GetActiveByEnvID(ctx, envID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotReady
+		}
+		return nil, err
+	}
+
+	return s.deploySite(ctx, env, deploy)
+}
+
+// previewSite 返回预览域名对应部署的站点，部署无需激活。
+// 域名中的项目标签必须与部署所属项目一致，避免同一部署出现多个地址
+func (s *siteService) previewSite(ctx context.Context, deployID uint, label string) (*Site, error) {
+	deploy, err := s.projectDeployRepo.GetByID(ctx, deployID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	project, err := s.projectRepo.GetByID(ctx, deploy.ProjectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotFound
+		}
+		return nil, err
+	}
+	if previewLabel(deploy.ID, project.Name) != label {
+		return nil, ErrSiteNotFound
+	}
+	if !model.IsDeployable(deploy.Status) {
+		return nil, ErrSiteNotReady
+	}
+
+	env, err := s.projectEnvRepo.GetByID(ctx, deploy.ProjectEnvID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSiteNotFound
+		}
+		return nil, err
+	}
+
+	site, err := s.deploySite(ctx, env, deploy)
+	if err != nil {
+		return nil, err
+	}
+	site.Preview = true
+	return site, nil
+}
+
+func (s *siteService) deploySite(ctx context.Context, env *model.ProjectEnv, deploy *model.ProjectEnvDeploy) (*Site, error) {
+	site := &Site{
+		ProjectID:    deploy.ProjectID,
+		ProjectEnvID: deploy.ProjectEnvID,
+		DeployID:     deploy.ID,
+		Prefix:       DeployPrefix(deploy.ProjectID, deploy.StorageDeployID()),
+		Routing:      env.Routing,
+		Cache:        env.Cache,
+		Rules:        deploy.Rules,
+		ModTime:      deploy.UpdatedAt,
+	}
+	if deploy.ContentAddressed {
+		files, err := s.manifest(ctx, deploy.StorageDeployID())
+		if err != nil {
+			return nil, err
+		}
+		site.Files = files
+	}
+	return site, nil
+}
+
+// manifest 返回部署的文件清单，超过缓存上限时清空缓存
+func (s *siteService) manifest(ctx context.Context, deployID uint) (map[string]*model.DeployFile, error) {
+	s.mu.RLock()
+	files, ok := s.manifests[deployID]
+	s.mu.RUnlock()
+	if ok {
+		return files, nil
+	}
+
+	list, err := s.deployFileRepo.ListByDeployID(ctx, deployID)
+	if err != nil {
+		return nil, err
+	}
+	files = make(map[string]*model.DeployFile, len(list))
+	for _, file := range list {
+		files[file.Path] = file
+	}
+
+	s.mu.Lock()
+	if len(s.manifests) >= maxCachedManifests {
+		s.manifests = make(map[uint]map[string]*model.DeployFile)
+	}
+	s.manifests[deployID] = files
+	s.mu.Unlock()
+	return files, nil
+}
+
+// graySite 返回生产环境的灰度分流，没有规则或灰度环境尚未发布时返回 nil
+func (s *siteService) graySite(ctx context.Context, prodEnvID uint) (*GraySite, error) {
+	rule, err := s.grayRuleRepo.GetByProdEnvID(ctx, prodEnvID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	site, err := s.envSite(ctx, rule.GrayEnvID)
+	if err != nil {
+		if errors.Is(err, ErrSiteNotFound) || errors.Is(err, ErrSiteNotReady) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &GraySite{Rule: rule, Site: site}, nil
+}
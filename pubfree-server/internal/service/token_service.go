@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/dto/response"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/logger"
+	"pubfree-platform/pubfree-server/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+
+// activeSessionCacheTTL 会话未吊销状态的缓存时间。吊销写入缓存失败时，
+// 缓存中未吊销的状态最多保留该时间，之后重新查询数据库
+const activeSessionCacheTTL = 30 * time.Second
+
+type TokenService interface {
+	// IssueTokens 为用户开启新会话并签发访问令牌与刷新令牌
+	IssueTokens(ctx context.Context, user *model.User) (*response.TokenResponse, error)
+	// Refresh 轮换刷新令牌，已被替换或吊销的令牌会被拒绝
+	Refresh(ctx context.Context, refreshToken string) (*response.TokenResponse, error)
+	// Logout 吊销刷新令牌所属的会话
+	Logout(ctx context.Context, refreshToken string) error
+	// RevokeUserSessions 吊销用户的全部会话
+	RevokeUserSessions(ctx context.Context, userID uint) error
+	// ParseAccessToken 校验访问令牌签名、有效期以及会话是否已被吊销
+	ParseAccessToken(ctx context.Context, tokenString string) (*utils.Claims, error)
+}
+
+type tokenService struct {
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	revocationCache  repository.SessionRevocationCache
+	jwtConfig        config.JWTConfig
+}
+
+// NewTokenService 创建令牌服务，revocationCache 可以为 nil，此时直接查询数据库
+func NewTokenService(
+	userRepo repository.UserRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	revocationCache repository.SessionRevocationCache,
+	jwtConfig config.JWTConfig,
+) TokenService {
+	return &tokenService{
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		revocationCache:  revocationCache,
+		jwtConfig:        jwtConfig,
+	}
+}
+
+func (s *tokenService) IssueTokens(ctx context.Context, user *model.User) (*response.TokenResponse, error) {
+	sessionID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, record, err := s.newRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshTokenRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+
+	return s.buildResponse(user, sessionID, refreshToken, record.ExpiresAt)
+}
+
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*response.TokenResponse, error) {
+	current, err := s.refreshTokenRepo.GetByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 已被替换的令牌再次出现，说明令牌可能泄露，吊销整个会话
+	if current.ReplacedByID != nil {
+		_ = s.revokeSession(ctx, current.SessionID)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(ctx, current.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	nextToken, next, err := s.newRefreshToken(user.ID, current.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.refreshTokenRepo.Rotate(ctx, current.ID, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// 并发刷新时只有一个请求能成功，另一个视为重放
+		_ = s.revokeSession(ctx, current.SessionID)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.buildResponse(user, current.SessionID, nextToken, next.ExpiresAt)
+}
+
+func (s *tokenService) Logout(ctx context.Context, refreshToken string) error {
+	current, err := s.refreshTokenRepo.GetByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	return s.revokeSession(ctx, current.SessionID)
+}
+
+func (s *tokenService) RevokeUserSessions(ctx context.Context, userID uint) error {
+	sessionIDs, err := s.refreshTokenRepo.RevokeByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionID := range sessionIDs {
+		s.cacheRevocation(ctx, sessionID)
+	}
+	return nil
+}
+
+func (s *tokenService) ParseAccessToken(ctx context.Context, tokenString string) (*utils.Claims, error) {
+	claims, err := utils.ParseToken(s.jwtConfig, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := s.isSessionRevoked(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("会话已失效，请重新登录")
+	}
+
+	return claims, nil
+}
+
+func (s *tokenService) newRefreshToken(userID uint, sessionID string) (string, *model.RefreshToken, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, &model.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(s.jwtConfig.RefreshExpiresAt),
+	}, nil
+}
+
+func (s *tokenService) buildResponse(user *model.User, sessionID, refreshToken string, refreshExpiresAt time.Time) (*response.TokenResponse, error) {
+	accessToken, expiresAt, err := utils.GenerateToken(s.jwtConfig, user.ID, user.Name, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.TokenResponse{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+	}, nil
+}
+
+func (s *tokenService) revokeSession(ctx context.Context, sessionID string) error {
+	if err := s.refreshTokenRepo.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	s.cacheRevocation(ctx, sessionID)
+	return nil
+}
+
+// cacheRevocation 将会话吊销写入缓存，缓存只需保留到该会话签发的访问令牌全部过期
+func (s *tokenService) cacheRevocation(ctx context.Context, sessionID string) {
+	if s.revocationCache == nil {
+		return
+	}
+	if err := s.revocationCache.Set(ctx, sessionID, true, s.jwtConfig.ExpiresAt); err != nil {
+		logger.Logger.Errorf("写入会话吊销缓存失败，吊销最多延迟 %s 生效: %v", activeSessionCacheTTL, err)
+	}
+}
+
+// isSessionRevoked 先查缓存，未命中或缓存不可用时查询数据库，并将结果回填缓存
+func (s *tokenService) isSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if s.revocationCache != nil {
+		revoked, found, err := s.revocationCache.Get(ctx, sessionID)
+		if err == nil && found {
+			return revoked, nil
+		}
+		if err != nil {
+			logger.Logger.Warnf("读取会话吊销缓存失败，回退到数据库: %v", err)
+		}
+	}
+
+	revoked, err := s.refreshTokenRepo.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	if s.revocationCache != nil {
+		ttl := activeSessionCacheTTL
+		if revoked {
+			ttl = s.jwtConfig.ExpiresAt
+		}
+		if err := s.revocationCache.Set(ctx, sessionID, revoked, ttl); err != nil {
+			logger.Logger.Warnf("写入会话吊销缓存失败: %v", err)
+		}
+	}
+	return revoked, nil
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+var testJWTConfig = config.JWTConfig{
+	Secret:           "test-secret",
+	ExpiresAt:        time.Hour,
+	RefreshExpiresAt: 24 * time.Hour,
+	Issuer:           "pubfree-test",
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[uint]*model.User
+}
+
+func (r *fakeUserRepo) GetByID(ctx context.Context, id uint) (*model.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *fakeUserRepo) Delete(ctx context.Context, id uint) error {
+	delete(r.users, id)
+	return nil
+}
+
+type fakeRefreshTokenRepo struct {
+	mu     sync.Mutex
+	tokens []*model.RefreshToken
+}
+
+func (r *fakeRefreshTokenRepo) Create(ctx context.Context, token *model.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uint(len(r.tokens) + 1)
+	r.tokens = append(r.tokens, token)
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) GetByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			copied := *token
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeRefreshTokenRepo) Rotate(ctx context.Context, oldID uint, next *model.RefreshToken) (bool, error) {
+	r.mu.Lock()
+	old := r.tokens[oldID-1]
+	if old.ReplacedByID != nil || old.RevokedAt != nil {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.mu.Unlock()
+
+	if err := r.Create(ctx, next); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old.ReplacedByID = &next.ID
+	return true, nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeSession(ctx context.Context, sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.SessionID == sessionID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeRefreshTokenRepo) RevokeByUserID(ctx context.Context, userID uint) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	seen := map[string]bool{}
+	var sessionIDs []string
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+			if !seen[token.SessionID] {
+				seen[token.SessionID] = true
+				sessionIDs = append(sessionIDs, token.SessionID)
+			}
+		}
+	}
+	return sessionIDs, nil
+}
+
+func (r *fakeRefreshTokenRepo) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.SessionID == sessionID && token.RevokedAt != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeRevocationCache 内存中的吊销缓存，failWrites 模拟缓存写入失败
+type fakeRevocationCache struct {
+	mu         sync.Mutex
+	entries    map[string]bool
+	failWrites bool
+}
+
+func (c *fakeRevocationCache) Set(ctx context.Context, sessionID string, revoked bool, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failWrites {
+		return errors.New("cache unavailable")
+	}
+	c.entries[sessionID] = revoked
+	return nil
+}
+
+func (c *fakeRevocationCache) Get(ctx context.Context, sessionID string) (bool, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	revoked, found := c.entries[sessionID]
+	return revoked, found, nil
+}
+
+func newTestTokenService() (TokenService, *fakeRefreshTokenRepo, *fakeRevocationCache) {
+	users := &fakeUserRepo{users: map[uint]*model.User{1: {ID: 1, Name: "alice"}}}
+	tokens := &fakeRefreshTokenRepo{}
+	cache := &fakeRevocationCache{entries: map[string]bool{}}
+	return NewTokenService(users, tokens, cache, testJWTConfig), tokens, cache
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestTokenService()
+
+	issued, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := svc.Refresh(ctx, issued.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if refreshed.RefreshToken == issued.RefreshToken {
+		t.Fatal("Refresh() returned the same refresh token")
+	}
+
+	claims, err := svc.ParseAccessToken(ctx, refreshed.Token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if claims.UserID != 1 {
+		t.Errorf("UserID = %d, want 1", claims.UserID)
+	}
+	if _, err := svc.Refresh(ctx, refreshed.RefreshToken); err != nil {
+		t.Errorf("Refresh() with rotated token error = %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestTokenService()
+
+	issued, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := svc.Refresh(ctx, issued.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 已被替换的令牌再次出现，整个会话被吊销
+	if _, err := svc.Refresh(ctx, issued.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("Refresh() with replaced token error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := svc.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after reuse error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := svc.ParseAccessToken(ctx, refreshed.Token); err == nil {
+		t.Error("ParseAccessToken() after reuse succeeded, want revoked session")
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestTokenService()
+
+	issued, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Logout(ctx, issued.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := svc.ParseAccessToken(ctx, issued.Token); err == nil {
+		t.Error("ParseAccessToken() after logout succeeded")
+	}
+	if _, err := svc.Refresh(ctx, issued.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh() after logout error = %v, want ErrInvalidRefreshToken", err)
+	}
+	// 其他会话不受影响
+	if _, err := svc.ParseAccessToken(ctx, other.Token); err != nil {
+		t.Errorf("ParseAccessToken() of other session error = %v", err)
+	}
+
+	if err := svc.RevokeUserSessions(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ParseAccessToken(ctx, other.Token); err == nil {
+		t.Error("ParseAccessToken() after RevokeUserSessions succeeded")
+	}
+}
+
+func TestRevocationCacheMissChecksDatabase(t *testing.T) {
+	ctx := context.Background()
+	svc, _, cache := newTestTokenService()
+
+	issued, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ParseToken(testJWTConfig, issued.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 吊销写入缓存失败，且缓存中没有该会话（如 Redis 重启）
+	cache.failWrites = true
+	if err := svc.Logout(ctx, issued.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ParseAccessToken(ctx, issued.Token); err == nil {
+		t.Error("ParseAccessToken() on cache miss succeeded for revoked session")
+	}
+
+	// 未命中时查询数据库的结果回填缓存
+	cache.failWrites = false
+	if _, err := svc.ParseAccessToken(ctx, issued.Token); err == nil {
+		t.Fatal("ParseAccessToken() succeeded for revoked session")
+	}
+	if revoked, found, _ := cache.Get(ctx, claims.SessionID); !found || !revoked {
+		t.Errorf("cache entry = (%v, %v), want revoked", revoked, found)
+	}
+
+	active, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.ParseAccessToken(ctx, active.Token); err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	activeClaims, _ := utils.ParseToken(testJWTConfig, active.Token)
+	if revoked, found, _ := cache.Get(ctx, activeClaims.SessionID); !found || revoked {
+		t.Errorf("cache entry = (%v, %v), want cached as active", revoked, found)
+	}
+}
+
+func TestParseAccessTokenRejectsInvalid(t *testing.T) {
+	ctx := context.Background()
+	svc, _, _ := newTestTokenService()
+
+	expiredConfig := testJWTConfig
+	expiredConfig.ExpiresAt = -time.Minute
+	expired, _, err := utils.GenerateToken(expiredConfig, 1, "alice", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret := testJWTConfig
+	otherSecret.Secret = "other-secret"
+	forged, _, err := utils.GenerateToken(otherSecret, 1, "alice", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherIssuer := testJWTConfig
+	otherIssuer.Issuer = "someone-else"
+	wrongIssuer, _, err := utils.GenerateToken(otherIssuer, 1, "alice", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noSession, _, err := utils.GenerateToken(testJWTConfig, 1, "alice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"garbage", "not-a-jwt"},
+		{"expired", expired},
+		{"wrong secret", forged},
+		{"wrong issuer", wrongIssuer},
+		{"missing session", noSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.ParseAccessToken(ctx, tt.token); err == nil {
+				t.Error("ParseAccessToken() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpired(t *testing.T) {
+	ctx := context.Background()
+	svc, tokens, _ := newTestTokenService()
+
+	issued, err := svc.IssueTokens(ctx, &model.User{ID: 1, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens.tokens[0].ExpiresAt = time.Now().Add(-time.Minute)
+
+	for name, token := range map[string]string{"unknown": "no-such-token", "expired": issued.RefreshToken} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := svc.Refresh(ctx, token); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Refresh() error = %v, want ErrInvalidRefreshToken", err)
+			}
+		})
+	}
+}
+
+func TestDeleteUserRevokesSessions(t *testing.T) {
+	ctx := context.Background()
+	users := &fakeUserRepo{users: map[uint]*model.User{1: {ID: 1, Name: "alice"}}}
+	tokenService := NewTokenService(users, &fakeRefreshTokenRepo{}, &fakeRevocationCache{entries: map[string]bool{}}, testJWTConfig)
+	userService := NewUserService(users, tokenService)
+
+	issued, err := tokenService.IssueTokens(ctx, users.users[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := userService.DeleteUser(ctx, 1); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, ok := users.users[1]; ok {
+		t.Error("DeleteUser() did not delete the user")
+	}
+	if _, err := tokenService.ParseAccessToken(ctx, issued.Token); err == nil {
+		t.Error("ParseAccessToken() after DeleteUser succeeded")
+	}
+	if _, err := tokenService.Refresh(ctx, issued.RefreshToken); err == nil {
+		t.Error("Refresh() after DeleteUser succeeded")
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+	"pubfree-platform/pubfree-server/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUploadNotFound   = errors.New("上传不存在")
+	ErrUploadExpired    = errors.New("上传已过期")
+	ErrUploadTooLarge   = errors.New("上传的文件超过大小限制")
+	ErrUploadOffset     = errors.New("上传偏移量与已接收的大小不一致")
+	ErrUploadLocked     = errors.New("该上传正在写入中")
+	ErrUploadIncomplete = errors.New("上传尚未完成")
+)
+
+// cleanupBatchSize 每次查询过期上传的条数
+const cleanupBatchSize = 100
+
+// UploadService tus 断点续传的上传，上传只属于创建者，完成后在创建部署时使用
+type UploadService interface {
+	// Create 创建上传，length 为文件的总大小
+	Create(ctx context.Context, userID uint, length int64, filename string) (*model.Upload, error)
+	Get(ctx context.Context, userID uint, id string) (*model.Upload, error)
+	// Write 从 offset 处追加内容，offset 须等于已接收的大小。请求中断时已收到的内容会保留，客户端可从新的偏移量继续
+	Write(ctx context.Context, userID uint, id string, offset int64, r io.Reader) (*model.Upload, error)
+	Delete(ctx context.Context, userID uint, id string) error
+	// Open 打开已完成的上传，调用方负责关闭文件
+	Open(ctx context.Context, userID uint, id string) (*model.Upload, *os.File, error)
+	// Cleanup 删除过期的上传及其内容，返回删除的数量
+	Cleanup(ctx context.Context) (int, error)
+	// MaxSize 单个上传的大小上限
+	MaxSize() int64
+}
+
+type uploadService struct {
+	uploadRepo repository.UploadRepository
+	config     config.UploadConfig
+	maxSize    int64
+
+	// 正在写入的上传，同一上传同时只允许一个请求写入
+	writing sync.Map
+}
+
+func NewUploadService(uploadRepo repository.UploadRepository, uploadConfig config.UploadConfig, maxSize int64) UploadService {
+	return &uploadService{
+		uploadRepo: uploadRepo,
+		config:     uploadConfig,
+		maxSize:    maxSize,
+	}
+}
+
+func (s *uploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *uploadService) Create(ctx context.Context, userID uint, length int64, filename string) (*model.Upload, error) {
+	if length > s.maxSize {
+		return nil, fmt.Errorf("%w: 不能超过 %dMB", ErrUploadTooLarge, s.maxSize>>20)
+	}
+	if runes := []rune(filename); len(runes) > 255 {
+		filename = string(runes[:255])
+	}
+
+	id, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(s.path(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	upload := &model.Upload{
+		ID:        id,
+		UserID:    userID,
+		Filename:  filename,
+		Length:    length,
+		ExpiresAt: time.Now().Add(s.config.Expiration),
+	}
+	if err := s.uploadRepo.Create(ctx, upload); err != nil {
+		os.Remove(s.path(id))
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (s *uploadService) Get(ctx context.Context, userID uint, id string) (*model.Upload, error) {
+	upload, err := s.uploadRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	if upload.UserID != userID {
+		return nil, ErrUploadNotFound
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, ErrUploadExpired
+	}
+	return upload, nil
+}
+
+func (s *uploadService) Write(ctx context.Context, userID uint, id string, offset int64, r io.Reader) (*model.Upload, error) {
+	if _, busy := s.writing.LoadOrStore(id, struct{}{}); busy {
+		return nil, ErrUploadLocked
+	}
+	defer s.writing.Delete(id)
+
+	upload, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, fmt.Errorf("%w: 已接收 %d 字节", ErrUploadOffset, upload.Offset)
+	}
+	if upload.IsComplete() {
+		return upload, nil
+	}
+
+	file, err := os.OpenFile(s.path(id), os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// 上次写入后进度未能保存时，文件可能比记录的长，从记录的进度处继续写
+	if err := file.Truncate(offset); err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n, copyErr := io.Copy(file, io.LimitReader(r, upload.Length-offset))
+	if n > 0 {
+		upload.Offset += n
+		upload.ExpiresAt = time.Now().Add(s.config.Expiration)
+		// 客户端断开时请求的 ctx 已取消，进度仍需保存
+		if err := s.uploadRepo.UpdateProgress(context.Background(), upload); err != nil {
+			return nil, err
+		}
+	}
+	if copyErr != nil {
+		return nil, copyErr
+	}
+	return upload, nil
+}
+
+func (s *uploadService) Delete(ctx context.Context, userID uint, id string) error {
+	upload, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.remove(ctx, upload)
+}
+
+func (s *uploadService) Open(ctx context.Context, userID uint, id string) (*model.Upload, *os.File, error) {
+	upload, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !upload.IsComplete() {
+		return nil, nil, fmt.Errorf("%w: 已接收 %d/%d 字节", ErrUploadIncomplete, upload.Offset, upload.Length)
+	}
+
+	file, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return upload, file, nil
+}
+
+func (s *uploadService) Cleanup(ctx context.Context) (int, error) {
+	removed := 0
+	for {
+		uploads, err := s.uploadRepo.ListExpired(ctx, time.Now(), cleanupBatchSize)
+		if err != nil {
+			return removed, err
+		}
+		for _, upload := range uploads {
+			if err := s.remove(ctx, upload); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if len(uploads) < cleanupBatchSize {
+			return removed, nil
+		}
+	}
+}
+
+// remove 删除上传的内容和记录
+func (s *uploadService) remove(ctx context.Context, upload *model.Upload) error {
+	if err := os.Remove(s.path(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.uploadRepo.Delete(ctx, upload.ID)
+}
+
+// path 上传内容在上传目录中的路径，id 为服务端生成的十六进制字符串
+func (s *uploadService) path(id string) string {
+	return filepath.Join(s.config.Dir, id)
+}
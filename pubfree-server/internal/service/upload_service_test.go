@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+	"pubfree-platform/pubfree-server/internal/model"
+	"pubfree-platform/pubfree-server/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+type fakeUploadRepo struct {
+	repository.UploadRepository
+
+	mu      sync.Mutex
+	uploads map[string]*model.Upload
+}
+
+func (r *fakeUploadRepo) Create(ctx context.Context, upload *model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.uploads == nil {
+		r.uploads = make(map[string]*model.Upload)
+	}
+	saved := *upload
+	r.uploads[upload.ID] = &saved
+	return nil
+}
+
+func (r *fakeUploadRepo) GetByID(ctx context.Context, id string) (*model.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *upload
+	return &found, nil
+}
+
+func (r *fakeUploadRepo) UpdateProgress(ctx context.Context, upload *model.Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if saved, ok := r.uploads[upload.ID]; ok {
+		saved.Offset = upload.Offset
+		saved.ExpiresAt = upload.ExpiresAt
+	}
+	return nil
+}
+
+func (r *fakeUploadRepo) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *fakeUploadRepo) ListExpired(ctx context.Context, before time.Time, limit int) ([]*model.Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var uploads []*model.Upload
+	for _, upload := range r.uploads {
+		if upload.ExpiresAt.Before(before) && len(uploads) < limit {
+			found := *upload
+			uploads = append(uploads, &found)
+		}
+	}
+	return uploads, nil
+}
+
+// expire 将上传的过期时间改为已过期
+func (r *fakeUploadRepo) expire(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads[id].ExpiresAt = time.Now().Add(-time.Minute)
+}
+
+func newTestUploadService(t *testing.T) (*uploadService, *fakeUploadRepo) {
+	t.Helper()
+	repo := &fakeUploadRepo{}
+	s := NewUploadService(repo, config.UploadConfig{Dir: t.TempDir(), Expiration: time.Hour}, 10)
+	return s.(*uploadService), repo
+}
+
+func TestUploadCreate(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUploadService(t)
+
+	if _, err := s.Create(ctx, 1, 11, "site.zip"); !errors.Is(err, ErrUploadTooLarge) {
+		t.Errorf("超过大小限制 err = %v, 期望 %v", err, ErrUploadTooLarge)
+	}
+	if len(repo.uploads) != 0 {
+		t.Errorf("超过大小限制时不应创建上传: %v", repo.uploads)
+	}
+
+	upload, err := s.Create(ctx, 1, 10, strings.Repeat("名", 300))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upload.ID) != 32 || upload.Offset != 0 || upload.Length != 10 {
+		t.Errorf("上传 = %+v", upload)
+	}
+	if n := len([]rune(upload.Filename)); n != 255 {
+		t.Errorf("文件名长度 = %d, 期望截断为 255", n)
+	}
+	if upload.ExpiresAt.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("过期时间 = %v, 期望约 1 小时后", upload.ExpiresAt)
+	}
+	if info, err := os.Stat(s.path(upload.ID)); err != nil || info.Size() != 0 {
+		t.Errorf("应创建空的上传文件: %v, %v", info, err)
+	}
+}
+
+func TestUploadWrite(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUploadService(t)
+	upload, err := s.Create(ctx, 1, 10, "site.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Write(ctx, 1, upload.ID, 3, strings.NewReader("abc")); !errors.Is(err, ErrUploadOffset) {
+		t.Errorf("偏移量不一致 err = %v, 期望 %v", err, ErrUploadOffset)
+	}
+	if _, err := s.Write(ctx, 2, upload.ID, 0, strings.NewReader("abc")); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("其他用户写入 err = %v, 期望 %v", err, ErrUploadNotFound)
+	}
+	if _, err := s.Write(ctx, 1, "missing", 0, strings.NewReader("abc")); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("上传不存在 err = %v, 期望 %v", err, ErrUploadNotFound)
+	}
+
+	upload, err = s.Write(ctx, 1, upload.ID, 0, strings.NewReader("abcd"))
+	if err != nil || upload.Offset != 4 {
+		t.Fatalf("写入 offset = %d, err = %v", upload.Offset, err)
+	}
+	if _, _, err := s.Open(ctx, 1, upload.ID); !errors.Is(err, ErrUploadIncomplete) {
+		t.Errorf("未完成时打开 err = %v, 期望 %v", err, ErrUploadIncomplete)
+	}
+	if _, err := s.Write(ctx, 1, upload.ID, 0, strings.NewReader("abcd")); !errors.Is(err, ErrUploadOffset) {
+		t.Errorf("重复写入 err = %v, 期望 %v", err, ErrUploadOffset)
+	}
+
+	// 请求中断时已收到的内容保留，客户端从新的偏移量继续
+	_, err = s.Write(ctx, 1, upload.ID, 4, io.MultiReader(strings.NewReader("ef"), errReader{}))
+	if err == nil {
+		t.Fatal("读取请求体出错时应返回错误")
+	}
+	if upload, err = s.Get(ctx, 1, upload.ID); err != nil || upload.Offset != 6 {
+		t.Fatalf("中断后 offset = %d, err = %v, 期望 6", upload.Offset, err)
+	}
+
+	// 超过总大小的内容被忽略
+	upload, err = s.Write(ctx, 1, upload.ID, 6, strings.NewReader("ghijklmn"))
+	if err != nil || upload.Offset != 10 || !upload.IsComplete() {
+		t.Fatalf("完成写入 offset = %d, err = %v", upload.Offset, err)
+	}
+
+	_, file, err := s.Open(ctx, 1, upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "abcdefghij" {
+		t.Errorf("上传内容 = %q", data)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) { return 0, errors.New("连接断开") }
+
+func TestUploadWriteLocked(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestUploadService(t)
+	upload, err := s.Create(ctx, 1, 10, "site.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一个请求阻塞在读取请求体时，同一上传的其他写入被拒绝
+	pr, pw := io.Pipe()
+	done := make(chan error)
+	go func() {
+		_, err := s.Write(ctx, 1, upload.ID, 0, pr)
+		done <- err
+	}()
+	if _, err := pw.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write(ctx, 1, upload.ID, 0, strings.NewReader("ab")); !errors.Is(err, ErrUploadLocked) {
+		t.Errorf("并发写入 err = %v, 期望 %v", err, ErrUploadLocked)
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadExpired(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUploadService(t)
+
+	expired, err := s.Create(ctx, 1, 10, "old.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.expire(expired.ID)
+	fresh, err := s.Create(ctx, 1, 10, "new.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(ctx, 1, expired.ID); !errors.Is(err, ErrUploadExpired) {
+		t.Errorf("读取过期的上传 err = %v, 期望 %v", err, ErrUploadExpired)
+	}
+	if _, err := s.Write(ctx, 1, expired.ID, 0, strings.NewReader("a")); !errors.Is(err, ErrUploadExpired) {
+		t.Errorf("写入过期的上传 err = %v, 期望 %v", err, ErrUploadExpired)
+	}
+
+	count, err := s.Cleanup(ctx)
+	if err != nil || count != 1 {
+		t.Fatalf("Cleanup = %d, %v, 期望 1", count, err)
+	}
+	if _, err := os.Stat(s.path(expired.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("过期上传的内容应被删除: %v", err)
+	}
+	if _, err := s.Get(ctx, 1, expired.ID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("过期上传的记录应被删除: %v", err)
+	}
+	if _, err := s.Get(ctx, 1, fresh.ID); err != nil {
+		t.Errorf("未过期的上传不应被清理: %v", err)
+	}
+	if _, err := os.Stat(s.path(fresh.ID)); err != nil {
+		t.Errorf("未过期上传的内容不应被删除: %v", err)
+	}
+}
+
+func TestUploadCleanupBatches(t *testing.T) {
+	ctx := context.Background()
+	s, repo := newTestUploadService(t)
+
+	// 超过单次查询的条数时分批删除
+	for i := 0; i < cleanupBatchSize+5; i++ {
+		upload, err := s.Create(ctx, 1, 1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.expire(upload.ID)
+	}
+	count, err := s.Cleanup(ctx)
+	if err != nil || count != cleanupBatchSize+5 {
+		t.Fatalf("Cleanup = %d, %v, 期望 %d", count, err, cleanupBatchSize+5)
+	}
+	if entries, _ := os.ReadDir(s.config.Dir); len(entries) != 0 {
+		t.Errorf("上传目录中还有 %d 个文件", len(entries))
+	}
+}
@@ -19,14 +19,21 @@ type UserService interface {
 	DeleteUser(ctx context.Context, id uint) error
 	ListUsers(ctx context.Context, page, pageSize int) ([]*response.UserResponse, int64, error)
 	Login(ctx context.Context, req *request.LoginRequest) (*response.LoginResponse, error)
+	RefreshToken(ctx context.Context, req *request.RefreshTokenRequest) (*response.TokenResponse, error)
+	Logout(ctx context.Context, req *request.RefreshTokenRequest) error
+	RevokeSessions(ctx context.Context, userID uint) error
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	tokenService TokenService
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepository, tokenService TokenService) UserService {
+	return &userService{
+		userRepo:     userRepo,
+		tokenService: tokenService,
+	}
 }
 
 func (s *userService) CreateUser(ctx context.Context, req *request.CreateUserRequest) (*response.UserResponse, error) {
@@ -71,22 +78,35 @@ func (s *userService) UpdateUser(ctx context.Context, id uint, req *request.Upda
 		user.Name = req.Name
 	}
 
+	passwordChanged := false
 	if req.Password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return nil, err
 		}
 		user.Password = string(hashedPassword)
+		passwordChanged = true
 	}
 
 	if err := s.userRepo.Update(ctx, user); err != nil {
 		return nil, err
 	}
 
+	// 修改密码后踢掉该用户的所有会话
+	if passwordChanged {
+		if err := s.tokenService.RevokeUserSessions(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
 	return s.modelToResponse(user), nil
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id uint) error {
+	// 先吊销会话再删除，吊销失败时账号仍在，可以重试
+	if err := s.tokenService.RevokeUserSessions(ctx, id); err != nil {
+		return err
+	}
 	return s.userRepo.Delete(ctx, id)
 }
 
@@ -123,15 +143,29 @@ func (s *userService) Login(ctx context.Context, req *request.LoginRequest) (*re
 		return nil, errors.New("用户名或密码错误")
 	}
 
-	// 这里应该生成JWT token，简化示例
-	token := "jwt_token_here"
+	tokens, err := s.tokenService.IssueTokens(ctx, user)
+	if err != nil {
+		return nil, err
+	}
 
 	return &response.LoginResponse{
-		Token: token,
-		User:  *s.modelToResponse(user),
+		TokenResponse: *tokens,
+		User:          *s.modelToResponse(user),
 	}, nil
 }
 
+func (s *userService) RefreshToken(ctx context.Context, req *request.RefreshTokenRequest) (*response.TokenResponse, error) {
+	return s.tokenService.Refresh(ctx, req.RefreshToken)
+}
+
+func (s *userService) Logout(ctx context.Context, req *request.RefreshTokenRequest) error {
+	return s.tokenService.Logout(ctx, req.RefreshToken)
+}
+
+func (s *userService) RevokeSessions(ctx context.Context, userID uint) error {
+	return s.tokenService.RevokeUserSessions(ctx, userID)
+}
+
 func (s *userService) modelToResponse(user *model.User) *response.UserResponse {
 	return &response.UserResponse{
 		ID:        user.ID,
@@ -0,0 +1,204 @@
+// Package archive 安全地遍历 zip 与 tar.gz 压缩包中的文件
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Format 压缩包格式
+type Format string
+
+const (
+	FormatZip   Format = "zip"
+	FormatTarGz Format = "tar.gz"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("不支持的压缩包格式，仅支持 zip 和 tar.gz")
+	ErrUnsafePath        = errors.New("压缩包中包含不安全的文件路径")
+	ErrTooManyFiles      = errors.New("压缩包中的文件数量超过限制")
+	ErrTooLarge          = errors.New("压缩包解压后的大小超过限制")
+	ErrFileReplaced      = errors.New("文件在打包过程中被替换为符号链接或其他类型的文件")
+)
+
+// Limits 解压限制，0 表示不限制
+type Limits struct {
+	MaxFiles     int
+	MaxTotalSize int64
+}
+
+// WalkFunc 接收规范化后的相对路径和文件内容，r 只在回调期间有效
+type WalkFunc func(name string, r io.Reader) error
+
+// Detect 根据文件头识别压缩包格式
+func Detect(r io.ReaderAt) (Format, error) {
+	header := make([]byte, 4)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// Walk 依次遍历压缩包中的普通文件，目录、符号链接等其他类型的条目会被忽略。
+// 路径包含 ".." 或为绝对路径时返回 ErrUnsafePath，超出 limits 时返回对应错误
+func Walk(r io.ReaderAt, size int64, limits Limits, fn WalkFunc) error {
+	format, err := Detect(r)
+	if err != nil {
+		return err
+	}
+
+	w := &walker{limits: limits, fn: fn}
+	switch format {
+	case FormatZip:
+		return w.zip(r, size)
+	default:
+		return w.tarGz(io.NewSectionReader(r, 0, size))
+	}
+}
+
+type walker struct {
+	limits Limits
+	fn     WalkFunc
+	files  int
+	total  int64
+}
+
+func (w *walker) zip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = w.entry(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker) tarGz(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := w.entry(header.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *walker) entry(name string, r io.Reader) error {
+	cleaned, ok := CleanPath(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	if ignored(cleaned) {
+		return nil
+	}
+
+	w.files++
+	if w.limits.MaxFiles > 0 && w.files > w.limits.MaxFiles {
+		return ErrTooManyFiles
+	}
+
+	// 按实际读取的字节数计算大小，不信任压缩包头中声明的大小
+	return w.fn(cleaned, &limitedReader{r: r, w: w})
+}
+
+// CleanPath 规范化压缩包中的文件路径，绝对路径或包含 ".." 时返回 false
+func CleanPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// CommonRoot 返回全部文件共同的顶层目录（含结尾的"/"），没有时返回空字符串。
+// 常见于将 dist 目录整体打包的情况
+func CommonRoot(names []string) string {
+	var root string
+	for _, name := range names {
+		i := strings.Index(name, "/")
+		if i < 0 {
+			return ""
+		}
+		dir := name[:i+1]
+		if root == "" {
+			root = dir
+		} else if root != dir {
+			return ""
+		}
+	}
+	return root
+}
+
+// ignored 忽略 macOS 打包时生成的元数据文件
+func ignored(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store"
+}
+
+type limitedReader struct {
+	r io.Reader
+	w *walker
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.w.total += int64(n)
+	if l.w.limits.MaxTotalSize > 0 && l.w.total > l.w.limits.MaxTotalSize {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
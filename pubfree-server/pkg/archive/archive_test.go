@@ -0,0 +1,258 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testEntry struct {
+	name     string
+	body     string
+	typeflag byte // 仅 tar.gz 使用，0 表示普通文件
+}
+
+func zipArchive(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, e.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+		if e.typeflag != 0 {
+			header.Typeflag, header.Size, header.Linkname = e.typeflag, 0, e.body
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			io.WriteString(tw, e.body)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// walkAll 遍历压缩包，返回文件名到内容的映射
+func walkAll(data []byte, limits Limits) (map[string]string, error) {
+	files := make(map[string]string)
+	err := Walk(bytes.NewReader(data), int64(len(data)), limits, func(name string, r io.Reader) error {
+		body, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		files[name] = string(body)
+		return nil
+	})
+	return files, err
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"index.html", "index.html", true},
+		{"dist/./assets//app.js", "dist/assets/app.js", true},
+		{`dist\index.html`, "dist/index.html", true},
+		{"dist/a/../index.html", "", false},
+		{"../etc/passwd", "", false},
+		{`..\evil.txt`, "", false},
+		{"/etc/passwd", "", false},
+		{`\evil.txt`, "", false},
+		{"C:/Windows/evil.txt", "", false},
+		{"c:evil.txt", "", false},
+		{"", "", false},
+		{"./", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := CleanPath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("CleanPath(%q) = %q, %v, 期望 %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCommonRoot(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{[]string{"dist/index.html", "dist/assets/app.js"}, "dist/"},
+		{[]string{"dist/index.html", "build/app.js"}, ""},
+		{[]string{"dist/index.html", "index.html"}, ""},
+		{[]string{"index.html"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := CommonRoot(tt.names); got != tt.want {
+			t.Errorf("CommonRoot(%v) = %q, 期望 %q", tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		data    []byte
+		want    Format
+		wantErr bool
+	}{
+		{zipArchive(t, []testEntry{{name: "a", body: "a"}}), FormatZip, false},
+		{tarGzArchive(t, []testEntry{{name: "a", body: "a"}}), FormatTarGz, false},
+		{[]byte("plain text"), "", true},
+		{nil, "", true},
+	}
+	for i, tt := range tests {
+		got, err := Detect(bytes.NewReader(tt.data))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("#%d Detect = %q, %v, 期望 %q", i, got, err, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	builders := map[string]func(*testing.T, []testEntry) []byte{
+		"zip":    zipArchive,
+		"tar.gz": tarGzArchive,
+	}
+
+	tests := []struct {
+		name    string
+		entries []testEntry
+		limits  Limits
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			name: "普通文件",
+			entries: []testEntry{
+				{name: "dist/index.html", body: "<html>"},
+				{name: "dist/./assets/app.js", body: "js"},
+			},
+			want: map[string]string{"dist/index.html": "<html>", "dist/assets/app.js": "js"},
+		},
+		{
+			name: "忽略 macOS 元数据",
+			entries: []testEntry{
+				{name: "index.html", body: "<html>"},
+				{name: "__MACOSX/._index.html", body: "meta"},
+				{name: "assets/.DS_Store", body: "meta"},
+			},
+			want: map[string]string{"index.html": "<html>"},
+		},
+		{
+			name:    "上级目录",
+			entries: []testEntry{{name: "index.html", body: "ok"}, {name: "../../etc/cron.d/evil", body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "绝对路径",
+			entries: []testEntry{{name: "/etc/passwd", body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "Windows 风格的上级目录",
+			entries: []testEntry{{name: `dist\..\..\evil.txt`, body: "x"}},
+			wantErr: ErrUnsafePath,
+		},
+		{
+			name:    "文件数量超限",
+			entries: []testEntry{{name: "a", body: "a"}, {name: "b", body: "b"}, {name: "c", body: "c"}},
+			limits:  Limits{MaxFiles: 2},
+			wantErr: ErrTooManyFiles,
+		},
+		{
+			name:    "解压大小超限",
+			entries: []testEntry{{name: "a", body: strings.Repeat("a", 64)}, {name: "b", body: strings.Repeat("b", 64)}},
+			limits:  Limits{MaxTotalSize: 100},
+			wantErr: ErrTooLarge,
+		},
+	}
+	for format, build := range builders {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				files, err := walkAll(build(t, tt.entries), tt.limits)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("err = %v, 期望 %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Walk 出错: %v", err)
+				}
+				if !reflect.DeepEqual(files, tt.want) {
+					t.Errorf("文件 = %v, 期望 %v", files, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestWalkSkipsLinks(t *testing.T) {
+	data := tarGzArchive(t, []testEntry{
+		{name: "index.html", body: "<html>"},
+		{name: "passwd", body: "/etc/passwd", typeflag: tar.TypeSymlink},
+		{name: "hosts", body: "/etc/hosts", typeflag: tar.TypeLink},
+		{name: "assets/", typeflag: tar.TypeDir},
+	})
+	files, err := walkAll(data, Limits{})
+	if err != nil {
+		t.Fatalf("Walk 出错: %v", err)
+	}
+	if want := map[string]string{"index.html": "<html>"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("文件 = %v, 期望只包含普通文件", files)
+	}
+}
+
+func TestWalkSkipsZipSymlinks(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("index.html")
+	io.WriteString(w, "<html>")
+	header := &zip.FileHeader{Name: "passwd"}
+	header.SetMode(os.ModeSymlink | 0o777)
+	w, _ = zw.CreateHeader(header)
+	io.WriteString(w, "/etc/passwd")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := walkAll(buf.Bytes(), Limits{})
+	if err != nil {
+		t.Fatalf("Walk 出错: %v", err)
+	}
+	if want := map[string]string{"index.html": "<html>"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("文件 = %v, 期望只包含普通文件", files)
+	}
+}
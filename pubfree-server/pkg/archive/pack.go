@@ -0,0 +1,93 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PackDir 将目录中的普通文件打包为 tar.gz 写入 w，文件名为相对 dir 的路径。
+// 符号链接等其他类型的文件会被忽略，避免打包目录之外的内容；.git 目录也会被跳过
+func PackDir(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+
+		// 构建命令启动的进程可能仍在运行，检查之后文件可能被替换为符号链接，
+		// 打开后须确认仍是遍历时看到的同一个普通文件
+		f, err := openRegular(name, info)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.ToSlash(rel), err)
+		}
+		defer f.Close()
+		opened, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     filepath.ToSlash(rel),
+			Mode:     0o644,
+			Size:     opened.Size(),
+			ModTime:  opened.ModTime(),
+		}); err != nil {
+			return err
+		}
+		_, err = io.CopyN(tw, f, opened.Size())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// openRegular 打开 name，要求它仍是 info 对应的普通文件。符号链接不会被跟随，
+// 打开前被替换为其他文件、目录或设备文件时返回 ErrFileReplaced
+func openRegular(name string, info fs.FileInfo) (*os.File, error) {
+	f, err := openNoFollow(name)
+	if err != nil {
+		if isSymlinkError(err) {
+			return nil, ErrFileReplaced
+		}
+		return nil, err
+	}
+	opened, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !opened.Mode().IsRegular() || !os.SameFile(info, opened) {
+		f.Close()
+		return nil, ErrFileReplaced
+	}
+	return f, nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package archive
+
+import "os"
+
+// openNoFollow 非 unix 系统没有 O_NOFOLLOW，由 openRegular 比较打开前后的文件
+func openNoFollow(name string) (*os.File, error) {
+	return os.Open(name)
+}
+
+func isSymlinkError(err error) bool { return false }
@@ -0,0 +1,110 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, body string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPackDir(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, outside, "secret")
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "index.html"), "<html>")
+	writeFile(t, filepath.Join(dir, "assets", "app.js"), "app")
+	writeFile(t, filepath.Join(dir, ".git", "config"), "git")
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := PackDir(&buf, dir); err != nil {
+		t.Fatalf("PackDir 出错: %v", err)
+	}
+	files, err := walkAll(buf.Bytes(), Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"index.html": "<html>", "assets/app.js": "app"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("文件 = %v, 期望 %v", files, want)
+	}
+}
+
+func TestOpenRegular(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "secret")
+	writeFile(t, outside, "secret")
+
+	tests := []struct {
+		name    string
+		replace func(t *testing.T, name string) // 在 Lstat 之后替换文件
+		wantErr error
+	}{
+		{name: "未替换"},
+		{name: "内容被修改", replace: func(t *testing.T, name string) {
+			writeFile(t, name, "changed")
+		}},
+		{name: "替换为符号链接", wantErr: ErrFileReplaced, replace: func(t *testing.T, name string) {
+			if err := os.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(outside, name); err != nil {
+				t.Skipf("无法创建符号链接: %v", err)
+			}
+		}},
+		{name: "替换为其他文件", wantErr: ErrFileReplaced, replace: func(t *testing.T, name string) {
+			other := name + ".tmp"
+			writeFile(t, other, "other")
+			if err := os.Rename(other, name); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{name: "替换为目录", wantErr: ErrFileReplaced, replace: func(t *testing.T, name string) {
+			if err := os.Remove(name); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Mkdir(name, 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "index.html")
+			writeFile(t, name, "<html>")
+			info, err := os.Lstat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.replace != nil {
+				tt.replace(t, name)
+			}
+
+			f, err := openRegular(name, info)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("错误 = %v, 期望 %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openRegular 出错: %v", err)
+			}
+			f.Close()
+		})
+	}
+}
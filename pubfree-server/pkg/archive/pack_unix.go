@@ -0,0 +1,20 @@
+//go:build unix
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openNoFollow 以 O_NOFOLLOW 打开文件，name 为符号链接时失败；
+// O_NONBLOCK 避免文件被替换为命名管道时阻塞，对普通文件的读取没有影响
+func openNoFollow(name string) (*os.File, error) {
+	return os.OpenFile(name, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+}
+
+// isSymlinkError O_NOFOLLOW 打开符号链接时返回 ELOOP
+func isSymlinkError(err error) bool {
+	return errors.Is(err, syscall.ELOOP)
+}
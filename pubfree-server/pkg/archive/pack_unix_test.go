@@ -0,0 +1,31 @@
+//go:build unix
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOpenRegularFifo(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "index.html")
+	writeFile(t, name, "<html>")
+	info, err := os.Lstat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(name, 0o644); err != nil {
+		t.Skipf("无法创建命名管道: %v", err)
+	}
+
+	// 没有写入方时打开命名管道不应阻塞
+	if _, err := openRegular(name, info); !errors.Is(err, ErrFileReplaced) {
+		t.Fatalf("错误 = %v, 期望 %v", err, ErrFileReplaced)
+	}
+}
@@ -0,0 +1,332 @@
+// Package builder 从 git 仓库克隆代码并执行构建命令，产出静态站点目录
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSpec  = errors.New("无效的构建配置")
+	ErrCloneFailed  = errors.New("克隆仓库失败")
+	ErrBuildFailed  = errors.New("构建命令执行失败")
+	ErrBuildTimeout = errors.New("构建超时")
+)
+
+// Options 构建的资源限制，0 表示不限制
+type Options struct {
+	WorkDir        string        // 构建工作目录的父目录，为空时使用系统临时目录
+	Timeout        time.Duration // 克隆与构建的总时长上限
+	MaxMemoryMB    int64         // 构建进程的虚拟内存上限
+	MaxFileMB      int64         // 构建进程可写入的单个文件大小上限
+	MaxLogLines    int           // 记录的输出行数上限，超出部分丢弃
+	AllowLocalRepo bool          // 是否允许服务器本地路径或 file:// 仓库
+	// User 执行克隆与构建的系统用户，须为无特权用户，且不同于服务自身的用户。
+	// 服务需要以 root 运行或具备 CAP_SETUID、CAP_SETGID 才能切换用户
+	User string
+	// RunAsServer 未配置 User 时以服务自身的用户执行构建，构建命令可以读取服务的配置与文件，仅用于开发和测试
+	RunAsServer bool
+}
+
+// Spec 一次构建的输入
+type Spec struct {
+	RepoURL   string
+	Branch    string // 为空时使用仓库默认分支
+	Command   string // 在仓库根目录通过 sh -c 执行
+	OutputDir string // 构建产物目录，相对仓库根目录，为空时为仓库根目录
+}
+
+// LogFunc 接收克隆与构建过程的输出，每次一行
+type LogFunc func(line string)
+
+// Workspace 构建完成后的工作目录，使用完毕后须调用 Close 删除
+type Workspace struct {
+	dir    string
+	Output string // 构建产物目录的绝对路径
+	Commit string // 构建所用的提交
+}
+
+func (w *Workspace) Close() {
+	os.RemoveAll(w.dir)
+}
+
+type Runner struct {
+	opts Options
+	cred *credential // 构建用户，以服务自身的用户执行时为 nil
+}
+
+// New 创建构建器，构建用户不存在或不满足要求时返回错误
+func New(opts Options) (*Runner, error) {
+	r := &Runner{opts: opts}
+	if opts.User == "" {
+		if !opts.RunAsServer {
+			return nil, errors.New("未配置执行构建的系统用户")
+		}
+		return r, nil
+	}
+
+	cred, err := lookupCredential(opts.User)
+	if err != nil {
+		return nil, err
+	}
+	r.cred = cred
+	return r, nil
+}
+
+// Run 在独立的工作目录中克隆仓库并执行构建命令。
+// 超时或 ctx 取消时终止整个进程组，返回的 Workspace 仅在没有错误时有效
+func (r *Runner) Run(ctx context.Context, spec Spec, logf LogFunc) (*Workspace, error) {
+	if err := r.validate(spec); err != nil {
+		return nil, err
+	}
+
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	if r.opts.WorkDir != "" {
+		if err := os.MkdirAll(r.opts.WorkDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := os.MkdirTemp(r.opts.WorkDir, "pubfree-build-*")
+	if err != nil {
+		return nil, err
+	}
+	ws := &Workspace{dir: dir}
+	ok := false
+	defer func() {
+		if !ok {
+			ws.Close()
+		}
+	}()
+
+	home := filepath.Join(dir, "home")
+	src := filepath.Join(dir, "src")
+	if err := os.Mkdir(home, 0o700); err != nil {
+		return nil, err
+	}
+	// 工作目录归构建用户所有，构建命令无法写入工作目录之外的位置
+	for _, name := range []string{dir, home} {
+		if err := chown(name, r.cred); err != nil {
+			return nil, err
+		}
+	}
+	out := &lineWriter{logf: logf, max: r.opts.MaxLogLines}
+	defer out.Flush()
+
+	args := []string{"clone", "--depth", "1", "--single-branch", "--no-tags"}
+	if spec.Branch != "" {
+		args = append(args, "--branch", spec.Branch)
+	}
+	repoURL := spec.RepoURL
+	if filepath.IsAbs(repoURL) {
+		// 本地路径使用 file:// 协议，浅克隆才会生效
+		repoURL = "file://" + filepath.ToSlash(repoURL)
+	}
+	args = append(args, "--", repoURL, src)
+	if err := run(r.command(ctx, dir, home, "git", args...), out); err != nil {
+		return nil, r.wrap(ctx, ErrCloneFailed, err)
+	}
+
+	var commit bytes.Buffer
+	rev := r.command(ctx, src, home, "git", "rev-parse", "HEAD")
+	rev.Stdout = &commit
+	if err := rev.Run(); err != nil {
+		return nil, r.wrap(ctx, ErrCloneFailed, err)
+	}
+	ws.Commit = strings.TrimSpace(commit.String())
+	logf("代码版本 " + ws.Commit)
+
+	logf("$ " + spec.Command)
+	if err := run(r.command(ctx, src, home, "sh", "-c", r.limits()+spec.Command), out); err != nil {
+		return nil, r.wrap(ctx, ErrBuildFailed, err)
+	}
+	out.Flush()
+
+	ws.Output, err = outputDir(src, spec.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+	ok = true
+	return ws, nil
+}
+
+func (r *Runner) validate(spec Spec) error {
+	if strings.TrimSpace(spec.Command) == "" {
+		return fmt.Errorf("%w: 构建命令不能为空", ErrInvalidSpec)
+	}
+	if strings.HasPrefix(spec.Branch, "-") {
+		return fmt.Errorf("%w: 无效的分支名 %q", ErrInvalidSpec, spec.Branch)
+	}
+	if !r.allowedRepo(spec.RepoURL) {
+		return fmt.Errorf("%w: 不支持的仓库地址 %q", ErrInvalidSpec, spec.RepoURL)
+	}
+	return nil
+}
+
+// allowedRepo 允许 http(s)、ssh、git 协议和 git@host:path 形式的地址，本地仓库需要显式开启
+func (r *Runner) allowedRepo(repoURL string) bool {
+	if repoURL == "" || strings.HasPrefix(repoURL, "-") {
+		return false
+	}
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+		switch u.Scheme {
+		case "http", "https", "ssh", "git":
+			return u.Host != ""
+		case "file":
+			return r.opts.AllowLocalRepo
+		}
+		return false
+	}
+	if user, rest, found := strings.Cut(repoURL, "@"); found && user != "" && !strings.Contains(user, "/") {
+		host, _, found := strings.Cut(rest, ":")
+		return found && host != ""
+	}
+	return r.opts.AllowLocalRepo && filepath.IsAbs(repoURL)
+}
+
+// Redact 去掉仓库地址中的密码或令牌，用于展示和记录日志
+func Redact(repoURL string) string {
+	u, err := url.Parse(repoURL)
+	if err != nil || u.User == nil {
+		return repoURL
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// limits 通过 ulimit 限制构建进程及其子进程的资源
+func (r *Runner) limits() string {
+	var b strings.Builder
+	if r.opts.MaxMemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d || exit 1; ", r.opts.MaxMemoryMB<<10)
+	}
+	if r.opts.MaxFileMB > 0 {
+		// ulimit -f 的单位为 512 字节
+		fmt.Fprintf(&b, "ulimit -f %d || exit 1; ", r.opts.MaxFileMB<<11)
+	}
+	return b.String()
+}
+
+// command 创建在 dir 中执行的命令，只传递必要的环境变量，避免泄露服务配置
+func (r *Runner) command(ctx context.Context, dir, home, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + home,
+		"CI=true",
+		"GIT_TERMINAL_PROMPT=0",
+	}
+	cmd.WaitDelay = 10 * time.Second
+	setProcessGroup(cmd)
+	setCredential(cmd, r.cred)
+	return cmd
+}
+
+func run(cmd *exec.Cmd, out *lineWriter) error {
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// wrap 超时优先于命令本身的错误
+func (r *Runner) wrap(ctx context.Context, kind, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w（超过 %s）", ErrBuildTimeout, r.opts.Timeout)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%w: %v", kind, err)
+}
+
+// outputDir 解析构建产物目录，目录不能通过 .. 或符号链接指向仓库之外
+func outputDir(src, dir string) (string, error) {
+	output := filepath.Join(src, filepath.FromSlash(path.Clean("/"+dir)))
+	resolved, err := filepath.EvalSymlinks(output)
+	if err != nil {
+		return "", fmt.Errorf("%w: 构建产物目录 %s 不存在", ErrInvalidSpec, dir)
+	}
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return "", err
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: 构建产物目录 %s 不在仓库中", ErrInvalidSpec, dir)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: 构建产物目录 %s 不是目录", ErrInvalidSpec, dir)
+	}
+	return resolved, nil
+}
+
+// lineWriter 将输出按行交给 LogFunc，超过行数上限后丢弃
+type lineWriter struct {
+	logf    LogFunc
+	max     int
+	lines   int
+	buf     []byte
+	dropped bool
+}
+
+// maxLineLength 单行输出的长度上限，超出时强制换行
+const maxLineLength = 4096
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			if len(w.buf) >= maxLineLength {
+				i = maxLineLength
+			} else {
+				break
+			}
+		}
+		w.emit(string(w.buf[:i]))
+		if i < len(w.buf) && (w.buf[i] == '\n' || w.buf[i] == '\r') {
+			i++
+		}
+		w.buf = w.buf[i:]
+	}
+	return len(p), nil
+}
+
+// Flush 输出缓冲中不完整的最后一行
+func (w *lineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.emit(string(w.buf))
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line string) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" {
+		return
+	}
+	if w.max > 0 && w.lines >= w.max {
+		if !w.dropped {
+			w.dropped = true
+			w.logf(fmt.Sprintf("输出超过 %d 行，后续输出不再记录", w.max))
+		}
+		return
+	}
+	w.lines++
+	w.logf(line)
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package builder
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type credential struct{}
+
+// setProcessGroup 非 unix 系统只终止直接启动的进程
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// lookupCredential 非 unix 系统不支持切换用户
+func lookupCredential(name string) (*credential, error) {
+	return nil, errors.New("当前系统不支持以其他用户执行构建")
+}
+
+func setCredential(cmd *exec.Cmd, cred *credential) {}
+
+func chown(name string, cred *credential) error { return nil }
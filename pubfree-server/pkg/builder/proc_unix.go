@@ -0,0 +1,60 @@
+//go:build unix
+
+package builder
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+type credential = syscall.Credential
+
+// setProcessGroup 构建命令在独立的进程组中运行，取消时终止整个进程组，包括构建工具启动的子进程
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// lookupCredential 查找构建用户，拒绝 root 以及与服务相同的用户
+func lookupCredential(name string) (*credential, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, fmt.Errorf("构建用户 %s 不存在: %w", name, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("构建用户 %s 的 uid 无效: %w", name, err)
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("构建用户 %s 的 gid 无效: %w", name, err)
+	}
+	if uid == 0 || gid == 0 {
+		return nil, fmt.Errorf("构建用户 %s 不能是 root", name)
+	}
+	if int(uid) == os.Getuid() {
+		return nil, fmt.Errorf("构建用户 %s 不能与服务的用户相同", name)
+	}
+	// Groups 为空时去掉服务进程的附加组
+	return &credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}, nil
+}
+
+// setCredential 以构建用户的身份执行命令，须在 setProcessGroup 之后调用
+func setCredential(cmd *exec.Cmd, cred *credential) {
+	if cred != nil {
+		cmd.SysProcAttr.Credential = cred
+	}
+}
+
+func chown(name string, cred *credential) error {
+	if cred == nil {
+		return nil
+	}
+	return os.Chown(name, int(cred.Uid), int(cred.Gid))
+}
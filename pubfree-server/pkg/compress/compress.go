@@ -0,0 +1,126 @@
+// Package compress 部署文件的预压缩：判断文件是否值得压缩，生成 gzip 与 brotli 版本，并按 Accept-Encoding 选择返回的版本
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 支持的编码，取值与 Content-Encoding 一致
+const (
+	Gzip   = "gzip"
+	Brotli = "br"
+)
+
+// Encodings 预压缩生成的编码，按返回时的优先级排列
+var Encodings = []string{Brotli, Gzip}
+
+// MinSize 小于该大小的文件压缩收益很小，不生成压缩版本
+const MinSize = 512
+
+// compressibleTypes 值得压缩的文本类型
+var compressibleTypes = map[string]bool{
+	"text/html":                 true,
+	"text/css":                  true,
+	"text/javascript":           true,
+	"application/javascript":    true,
+	"application/x-javascript":  true,
+	"application/json":          true,
+	"application/manifest+json": true,
+	"image/svg+xml":             true,
+}
+
+// Compressible 判断文件是否需要生成压缩版本
+func Compressible(contentType string, size int64) bool {
+	if size < MinSize {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return compressibleTypes[mediaType]
+}
+
+// Ext 编码对应的文件扩展名，用于压缩版本在存储中的键
+func Ext(encoding string) string {
+	if encoding == Gzip {
+		return ".gz"
+	}
+	return "." + encoding
+}
+
+// Encode 以最高压缩率将 r 的内容压缩后写入 w
+func Encode(w io.Writer, r io.Reader, encoding string) error {
+	var encoder io.WriteCloser
+	switch encoding {
+	case Gzip:
+		zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		encoder = zw
+	case Brotli:
+		encoder = brotli.NewWriterLevel(w, brotli.BestCompression)
+	default:
+		return fmt.Errorf("不支持的压缩编码: %s", encoding)
+	}
+
+	if _, err := io.Copy(encoder, r); err != nil {
+		encoder.Close()
+		return err
+	}
+	return encoder.Close()
+}
+
+// Negotiate 按 Accept-Encoding 从 available 中选择编码，q 值相同时按 Encodings 的顺序优先。
+// 客户端不接受任何可用编码时返回空字符串，即返回原文件
+func Negotiate(acceptEncoding string, available func(encoding string) bool) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q, valid := 1.0, true
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(param, "=")
+			if !ok || !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				valid = false
+				break
+			}
+			q = parsed
+		}
+		if valid {
+			accepted[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range Encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if !ok || q <= 0 || q <= bestQ || !available(encoding) {
+			continue
+		}
+		best, bestQ = encoding, q
+	}
+	return best
+}
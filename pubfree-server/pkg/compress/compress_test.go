@@ -0,0 +1,102 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	both := func(string) bool { return true }
+	gzipOnly := func(encoding string) bool { return encoding == Gzip }
+	none := func(string) bool { return false }
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		available      func(string) bool
+		want           string
+	}{
+		{"未携带 Accept-Encoding", "", both, ""},
+		{"q 值相同时 br 优先", "gzip, deflate, br", both, Brotli},
+		{"只接受 gzip", "gzip", both, Gzip},
+		{"只有 gzip 版本", "gzip, br", gzipOnly, Gzip},
+		{"没有压缩版本", "gzip, br", none, ""},
+		{"按 q 值选择", "br;q=0.5, gzip;q=0.8", both, Gzip},
+		{"q=0 表示不接受", "br;q=0, gzip", both, Gzip},
+		{"全部 q=0", "br;q=0, gzip;q=0", both, ""},
+		{"通配符", "*", both, Brotli},
+		{"通配符不覆盖明确拒绝", "br;q=0, *;q=0.1", both, Gzip},
+		{"明确的编码优先于通配符", "*;q=0.9, gzip", both, Gzip},
+		{"大小写与空白", " GZIP ; Q=0.9 , Br ;q=0.5", both, Gzip},
+		{"q 值之外的参数", "br;level=1;q=0.1, gzip;q=0.5", both, Gzip},
+		{"无效的 q 值忽略该编码", "br;q=abc, gzip;q=0.1", both, Gzip},
+		{"超出范围的 q 值忽略该编码", "br;q=2, gzip;q=0.1", both, Gzip},
+		{"不支持的编码", "deflate, identity", both, ""},
+		{"空的编码项", ",, gzip,", both, Gzip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.acceptEncoding, tt.available); got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, 期望 %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := []struct {
+		contentType string
+		size        int64
+		want        bool
+	}{
+		{"text/html; charset=utf-8", MinSize, true},
+		{"application/javascript", 1 << 20, true},
+		{"image/svg+xml", MinSize, true},
+		{"text/html", MinSize - 1, false},
+		{"image/png", 1 << 20, false},
+		{"", 1 << 20, false},
+	}
+	for _, tt := range tests {
+		if got := Compressible(tt.contentType, tt.size); got != tt.want {
+			t.Errorf("Compressible(%q, %d) = %v, 期望 %v", tt.contentType, tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	content := strings.Repeat("<p>pubfree</p>\n", 100)
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		Gzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		Brotli: func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+	}
+	for _, encoding := range Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, strings.NewReader(content), encoding); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() >= len(content) {
+				t.Errorf("压缩后 %d 字节，未小于原文件 %d 字节", buf.Len(), len(content))
+			}
+			r, err := decoders[encoding](&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := io.ReadAll(r)
+			if err != nil || string(decoded) != content {
+				t.Errorf("解压结果不一致, err = %v", err)
+			}
+		})
+	}
+
+	if err := Encode(io.Discard, strings.NewReader(content), "deflate"); err == nil {
+		t.Error("不支持的编码应返回错误")
+	}
+}
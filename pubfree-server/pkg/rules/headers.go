@@ -0,0 +1,88 @@
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseHeaders 解析 _headers 文件，顶格书写路径，其后缩进书写该路径的响应头：
+//
+//	/assets/*
+//	  Cache-Control: public, max-age=31536000
+//
+// 空行和以 # 开头的行会被忽略
+func ParseHeaders(r io.Reader) ([]HeaderRule, error) {
+	var (
+		rules    []HeaderRule
+		ruleLine int
+	)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		raw := scanner.Text()
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		// 顶格的行是路径，缩进的行是响应头
+		if raw[0] != ' ' && raw[0] != '\t' {
+			if n := len(rules); n > 0 && len(rules[n-1].Headers) == 0 {
+				return nil, &SyntaxError{File: HeadersFile, Line: ruleLine, Msg: fmt.Sprintf("路径 %s 下没有响应头", rules[n-1].Path)}
+			}
+			if _, err := validatePattern(text); err != nil {
+				return nil, &SyntaxError{File: HeadersFile, Line: line, Msg: err.Error()}
+			}
+			if len(rules) >= maxRules {
+				return nil, &SyntaxError{File: HeadersFile, Line: line, Msg: fmt.Sprintf("规则数量不能超过 %d 条", maxRules)}
+			}
+			rules = append(rules, HeaderRule{Path: text})
+			ruleLine = line
+			continue
+		}
+
+		if len(rules) == 0 {
+			return nil, &SyntaxError{File: HeadersFile, Line: line, Msg: "响应头之前缺少路径"}
+		}
+		header, err := parseHeader(text)
+		if err != nil {
+			return nil, &SyntaxError{File: HeadersFile, Line: line, Msg: err.Error()}
+		}
+		rule := &rules[len(rules)-1]
+		rule.Headers = append(rule.Headers, *header)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", HeadersFile, err)
+	}
+	if n := len(rules); n > 0 && len(rules[n-1].Headers) == 0 {
+		return nil, &SyntaxError{File: HeadersFile, Line: ruleLine, Msg: fmt.Sprintf("路径 %s 下没有响应头", rules[n-1].Path)}
+	}
+	return rules, nil
+}
+
+func parseHeader(text string) (*Header, error) {
+	name, value, ok := strings.Cut(text, ":")
+	if !ok {
+		return nil, fmt.Errorf("响应头格式应为 Name: value")
+	}
+	name = strings.TrimSpace(name)
+	if !isToken(name) {
+		return nil, fmt.Errorf("无效的响应头名称 %q", name)
+	}
+	return &Header{Name: name, Value: strings.TrimSpace(value)}, nil
+}
+
+// isToken 判断是否为合法的 HTTP 头名称（RFC 7230 token）
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
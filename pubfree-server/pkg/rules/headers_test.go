@@ -0,0 +1,68 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	input := "# 注释\n" +
+		"/*\n" +
+		"  X-Frame-Options: DENY\n" +
+		"\tX-Content-Type-Options: nosniff\n" +
+		"\n" +
+		"/assets/*\n" +
+		"  # 缩进的注释\n" +
+		"  Cache-Control: public, max-age=31536000\n" +
+		"  Link: </style.css>; rel=preload\n"
+
+	got, err := ParseHeaders(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseHeaders 出错: %v", err)
+	}
+	want := []HeaderRule{
+		{Path: "/*", Headers: []Header{
+			{Name: "X-Frame-Options", Value: "DENY"},
+			{Name: "X-Content-Type-Options", Value: "nosniff"},
+		}},
+		{Path: "/assets/*", Headers: []Header{
+			{Name: "Cache-Control", Value: "public, max-age=31536000"},
+			{Name: "Link", Value: "</style.css>; rel=preload"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseHeaders = %+v\n期望 %+v", got, want)
+	}
+}
+
+func TestParseHeadersSyntaxError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"响应头之前缺少路径", "# 注释\n  X-Frame-Options: DENY", 2},
+		{"路径下没有响应头", "/a\n\n/b\n  X-A: 1", 1},
+		{"最后一个路径下没有响应头", "/a\n  X-A: 1\n\n/b\n", 4},
+		{"缺少冒号", "/a\n  X-A 1", 2},
+		{"无效的响应头名称", "/a\n  X-A: 1\n  Bad Name: 1", 3},
+		{"无效的路径", "/a\n  X-A: 1\nassets/*", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseHeaders(strings.NewReader(tt.input))
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, 期望 SyntaxError", err)
+			}
+			if syntaxErr.File != HeadersFile || syntaxErr.Line != tt.line {
+				t.Errorf("错误位置 = %s:%d, 期望第 %d 行", syntaxErr.File, syntaxErr.Line, tt.line)
+			}
+			if !strings.Contains(err.Error(), HeadersFile) {
+				t.Errorf("错误信息 %q 应包含文件名", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// defaultRedirectStatus 未写状态码时的默认状态码
+const defaultRedirectStatus = 301
+
+// allowedStatus 支持的状态码，200 和 404 为改写
+var allowedStatus = map[int]bool{
+	200: true,
+	301: true,
+	302: true,
+	303: true,
+	307: true,
+	308: true,
+	404: true,
+}
+
+// ParseRedirects 解析 _redirects 文件，每行一条规则：
+//
+//	/from /to [status][!]
+//
+// 空行和以 # 开头的行会被忽略
+func ParseRedirects(r io.Reader) ([]Redirect, error) {
+	var redirects []Redirect
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		redirect, err := parseRedirect(strings.Fields(text))
+		if err != nil {
+			return nil, &SyntaxError{File: RedirectsFile, Line: line, Msg: err.Error()}
+		}
+		if len(redirects) >= maxRules {
+			return nil, &SyntaxError{File: RedirectsFile, Line: line, Msg: fmt.Sprintf("规则数量不能超过 %d 条", maxRules)}
+		}
+		redirects = append(redirects, *redirect)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %w", RedirectsFile, err)
+	}
+	return redirects, nil
+}
+
+func parseRedirect(fields []string) (*Redirect, error) {
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("缺少目标地址")
+	}
+	if len(fields) > 3 {
+		return nil, fmt.Errorf("不支持的参数 %q", fields[3])
+	}
+
+	redirect := &Redirect{From: fields[0], To: fields[1], Status: defaultRedirectStatus}
+	names, err := validatePattern(redirect.From)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 3 {
+		status := fields[2]
+		if strings.HasSuffix(status, "!") {
+			redirect.Force = true
+			status = strings.TrimSuffix(status, "!")
+		}
+		code, err := strconv.Atoi(status)
+		if err != nil || !allowedStatus[code] {
+			return nil, fmt.Errorf("不支持的状态码 %q", fields[2])
+		}
+		redirect.Status = code
+	}
+
+	external := strings.HasPrefix(redirect.To, "http://") || strings.HasPrefix(redirect.To, "https://")
+	switch {
+	case external && redirect.IsRewrite():
+		return nil, fmt.Errorf("改写规则的目标必须是站内路径")
+	case !external && !strings.HasPrefix(redirect.To, "/"):
+		return nil, fmt.Errorf("目标地址 %q 必须以 / 或 http(s):// 开头", redirect.To)
+	}
+
+	for _, name := range placeholders(redirect.To) {
+		if !names[name] {
+			return nil, fmt.Errorf("目标地址中的占位符 :%s 未在来源路径中定义", name)
+		}
+	}
+	return redirect, nil
+}
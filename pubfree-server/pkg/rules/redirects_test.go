@@ -0,0 +1,79 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRedirects(t *testing.T) {
+	input := `# 注释
+/home /
+
+/blog/:year/:slug /posts/:year/:slug 302
+/docs/*   https://docs.example.com/:splat  301!
+/app/*  /index.html  200
+/gone   /404.html 404
+`
+	got, err := ParseRedirects(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseRedirects 出错: %v", err)
+	}
+	want := []Redirect{
+		{From: "/home", To: "/", Status: 301},
+		{From: "/blog/:year/:slug", To: "/posts/:year/:slug", Status: 302},
+		{From: "/docs/*", To: "https://docs.example.com/:splat", Status: 301, Force: true},
+		{From: "/app/*", To: "/index.html", Status: 200},
+		{From: "/gone", To: "/404.html", Status: 404},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseRedirects = %+v\n期望 %+v", got, want)
+	}
+	if !got[3].IsRewrite() || !got[4].IsRewrite() || got[0].IsRewrite() {
+		t.Error("200 和 404 应为改写规则")
+	}
+}
+
+func TestParseRedirectsSyntaxError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+	}{
+		{"缺少目标地址", "/a /b\n\n/only", 3},
+		{"多余的参数", "/a /b 301 extra", 1},
+		{"不支持的状态码", "# 注释\n/a /b 418", 2},
+		{"状态码不是数字", "/a /b abc", 1},
+		{"来源不以斜杠开头", "/a /b\na /b", 2},
+		{"星号不在末尾", "/a/*/b /b", 1},
+		{"目标地址无效", "/a b", 1},
+		{"改写到外部地址", "/a https://example.com 200", 1},
+		{"未定义的占位符", "\n\n\n/blog/:slug /posts/:id", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRedirects(strings.NewReader(tt.input))
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("err = %v, 期望 SyntaxError", err)
+			}
+			if syntaxErr.File != RedirectsFile || syntaxErr.Line != tt.line {
+				t.Errorf("错误位置 = %s:%d, 期望第 %d 行", syntaxErr.File, syntaxErr.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestParseRedirectsTooManyRules(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i <= maxRules; i++ {
+		fmt.Fprintf(&b, "/a%d /b\n", i)
+	}
+	_, err := ParseRedirects(strings.NewReader(b.String()))
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != maxRules+1 {
+		t.Errorf("err = %v, 期望在第 %d 行报告规则数量超限", err, maxRules+1)
+	}
+}
@@ -0,0 +1,234 @@
+// Package rules 解析部署产物中 Netlify 风格的 _redirects 与 _headers 规则文件
+package rules
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// RedirectsFile 重定向规则文件名，位于产物根目录
+	RedirectsFile = "_redirects"
+	// HeadersFile 响应头规则文件名，位于产物根目录
+	HeadersFile = "_headers"
+
+	// MaxFileSize 规则文件的大小上限
+	MaxFileSize = 1 << 20
+	// maxRules 单个文件中的规则数量上限，网关按顺序逐条匹配
+	maxRules = 1000
+)
+
+// RuleSet 部署的规则集合，随部署一起激活
+type RuleSet struct {
+	Redirects []Redirect   `json:"redirects,omitempty"`
+	Headers   []HeaderRule `json:"headers,omitempty"`
+}
+
+// Redirect 一条重定向或改写规则
+type Redirect struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status int    `json:"status"`
+	// Force 状态码后带 "!"，即使请求路径对应的文件存在也执行规则
+	Force bool `json:"force,omitempty"`
+}
+
+// IsRewrite 是否为改写规则，改写时直接返回目标文件的内容而不重定向
+func (r *Redirect) IsRewrite() bool {
+	return r.Status == 200 || r.Status == 404
+}
+
+// HeaderRule 路径匹配时添加的响应头
+type HeaderRule struct {
+	Path    string   `json:"path"`
+	Headers []Header `json:"headers"`
+}
+
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SyntaxError 规则文件的语法错误
+type SyntaxError struct {
+	File string
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s 第%d行: %s", e.File, e.Line, e.Msg)
+}
+
+// IsEmpty 判断规则集合是否为空，nil 视为空
+func (s *RuleSet) IsEmpty() bool {
+	return s == nil || (len(s.Redirects) == 0 && len(s.Headers) == 0)
+}
+
+// MatchRedirect 返回第一条与路径匹配的规则及替换占位符后的目标地址
+func (s *RuleSet) MatchRedirect(p string) (*Redirect, string, bool) {
+	if s == nil {
+		return nil, "", false
+	}
+	for i := range s.Redirects {
+		rule := &s.Redirects[i]
+		if params, ok := match(rule.From, p); ok {
+			return rule, expand(rule.To, params), true
+		}
+	}
+	return nil, "", false
+}
+
+// MatchHeaders 返回全部与路径匹配的响应头，按规则文件中的顺序排列
+func (s *RuleSet) MatchHeaders(p string) []Header {
+	if s == nil {
+		return nil
+	}
+	var headers []Header
+	for _, rule := range s.Headers {
+		if _, ok := match(rule.Path, p); ok {
+			headers = append(headers, rule.Headers...)
+		}
+	}
+	return headers
+}
+
+// Match 判断请求路径是否与路径模式匹配，供规则文件之外的路径配置复用
+func Match(pattern, p string) bool {
+	_, ok := match(pattern, p)
+	return ok
+}
+
+// ValidatePattern 校验路径模式，语法与规则文件中的路径相同
+func ValidatePattern(pattern string) error {
+	_, err := validatePattern(pattern)
+	return err
+}
+
+// validatePattern 校验路径模式：以 "/" 开头，"*" 只能作为最后一段，":name" 为占位符
+func validatePattern(pattern string) (map[string]bool, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("路径 %q 必须以 / 开头", pattern)
+	}
+
+	names := map[string]bool{}
+	segments := splitPath(pattern)
+	for i, segment := range segments {
+		switch {
+		case segment == "*":
+			if i != len(segments)-1 {
+				return nil, fmt.Errorf("路径 %q 中的 * 只能出现在末尾", pattern)
+			}
+			names["splat"] = true
+		case strings.Contains(segment, "*"):
+			return nil, fmt.Errorf("路径 %q 中的 * 必须单独作为一段", pattern)
+		case strings.HasPrefix(segment, ":"):
+			name := segment[1:]
+			if !isIdentifier(name) {
+				return nil, fmt.Errorf("路径 %q 中的占位符 %q 无效", pattern, segment)
+			}
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// match 按路径模式匹配请求路径，返回占位符的取值，"*" 匹配的剩余部分记为 splat。
+// 结尾的斜杠不影响匹配
+func match(pattern, p string) (map[string]string, bool) {
+	patternSegments := splitPath(pattern)
+	pathSegments := splitPath(p)
+
+	params := map[string]string{}
+	for i, segment := range patternSegments {
+		if segment == "*" {
+			params["splat"] = strings.Join(pathSegments[min(i, len(pathSegments)):], "/")
+			return params, true
+		}
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+		if strings.HasPrefix(segment, ":") {
+			params[segment[1:]] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, len(patternSegments) == len(pathSegments)
+}
+
+// expand 将目标地址中的 :name 替换为占位符的取值
+func expand(to string, params map[string]string) string {
+	if len(params) == 0 {
+		return to
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(to); {
+		if to[i] == ':' {
+			end := i + 1
+			for end < len(to) && isIdentChar(to[end], end == i+1) {
+				end++
+			}
+			if value, ok := params[to[i+1:end]]; ok && end > i+1 {
+				b.WriteString(value)
+				i = end
+				continue
+			}
+		}
+		b.WriteByte(to[i])
+		i++
+	}
+	return b.String()
+}
+
+// placeholders 返回目标地址中引用的占位符名称
+func placeholders(to string) []string {
+	var names []string
+	for i := 0; i < len(to); i++ {
+		if to[i] != ':' {
+			continue
+		}
+		end := i + 1
+		for end < len(to) && isIdentChar(to[end], end == i+1) {
+			end++
+		}
+		if end > i+1 {
+			names = append(names, to[i+1:end])
+			i = end - 1
+		}
+	}
+	return names
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentChar(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentChar 占位符名称由字母、数字和下划线组成，不能以数字开头
+func isIdentChar(c byte, first bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
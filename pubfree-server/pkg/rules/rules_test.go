@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    map[string]string
+		ok      bool
+	}{
+		{"/", "/", map[string]string{}, true},
+		{"/about", "/about", map[string]string{}, true},
+		{"/about", "/about/", map[string]string{}, true},
+		{"/about", "/about/team", nil, false},
+		{"/about/team", "/about", nil, false},
+		{"/blog/:year/:slug", "/blog/2024/hello", map[string]string{"year": "2024", "slug": "hello"}, true},
+		{"/blog/:year/:slug", "/blog/2024", nil, false},
+		{"/news/*", "/news/2024/01/post.html", map[string]string{"splat": "2024/01/post.html"}, true},
+		{"/news/*", "/news", map[string]string{"splat": ""}, true},
+		{"/news/*", "/newsletter", nil, false},
+		{"/*", "/any/path", map[string]string{"splat": "any/path"}, true},
+		{"/:lang/*", "/en/docs/intro", map[string]string{"lang": "en", "splat": "docs/intro"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := match(tt.pattern, tt.path)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("match(%q, %q) = %v, %v, 期望 %v, %v", tt.pattern, tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		to     string
+		params map[string]string
+		want   string
+	}{
+		{"/index.html", nil, "/index.html"},
+		{"/posts/:year/:slug", map[string]string{"year": "2024", "slug": "hello"}, "/posts/2024/hello"},
+		{"/archive/:splat", map[string]string{"splat": "a/b.html"}, "/archive/a/b.html"},
+		{"/:slug-:slug", map[string]string{"slug": "x"}, "/x-x"},
+		{"/:unknown/page", map[string]string{"slug": "x"}, "/:unknown/page"},
+		{"/:year2/:year", map[string]string{"year": "2024"}, "/:year2/2024"},
+		{"https://example.com:8443/:slug", map[string]string{"slug": "x"}, "https://example.com:8443/x"},
+		{"/trailing:", map[string]string{"slug": "x"}, "/trailing:"},
+	}
+	for _, tt := range tests {
+		if got := expand(tt.to, tt.params); got != tt.want {
+			t.Errorf("expand(%q, %v) = %q, 期望 %q", tt.to, tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"/", false},
+		{"/blog/:year/:slug", false},
+		{"/assets/*", false},
+		{"blog", true},
+		{"/*/blog", true},
+		{"/blog*", true},
+		{"/:1st", true},
+		{"/:", true},
+		{"/:na-me", true},
+	}
+	for _, tt := range tests {
+		if err := ValidatePattern(tt.pattern); (err != nil) != tt.wantErr {
+			t.Errorf("ValidatePattern(%q) err = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	set := &RuleSet{
+		Redirects: []Redirect{
+			{From: "/old/:slug", To: "/new/:slug", Status: 301},
+			{From: "/old/*", To: "/fallback", Status: 302},
+			{From: "/*", To: "/index.html", Status: 200},
+		},
+		Headers: []HeaderRule{
+			{Path: "/*", Headers: []Header{{Name: "X-Frame-Options", Value: "DENY"}}},
+			{Path: "/assets/*", Headers: []Header{{Name: "Cache-Control", Value: "max-age=31536000"}}},
+		},
+	}
+
+	redirectTests := []struct {
+		path       string
+		wantTo     string
+		wantStatus int
+	}{
+		{"/old/post", "/new/post", 301},
+		{"/old/a/b", "/fallback", 302},
+		{"/app/settings", "/index.html", 200},
+	}
+	for _, tt := range redirectTests {
+		rule, to, ok := set.MatchRedirect(tt.path)
+		if !ok || to != tt.wantTo || rule.Status != tt.wantStatus {
+			t.Errorf("MatchRedirect(%q) = %v, %q, %v, 期望 %q %d", tt.path, rule, to, ok, tt.wantTo, tt.wantStatus)
+		}
+	}
+
+	headers := set.MatchHeaders("/assets/app.js")
+	if len(headers) != 2 || headers[0].Name != "X-Frame-Options" || headers[1].Name != "Cache-Control" {
+		t.Errorf("MatchHeaders 应按规则顺序返回全部匹配的响应头，实际 %v", headers)
+	}
+	if headers := set.MatchHeaders("/index.html"); len(headers) != 1 {
+		t.Errorf("MatchHeaders(/index.html) = %v", headers)
+	}
+
+	var empty *RuleSet
+	if !empty.IsEmpty() || !(&RuleSet{}).IsEmpty() || set.IsEmpty() {
+		t.Error("IsEmpty 结果错误")
+	}
+	if _, _, ok := empty.MatchRedirect("/"); ok {
+		t.Error("nil 规则集不应匹配任何路径")
+	}
+	if empty.MatchHeaders("/") != nil {
+		t.Error("nil 规则集不应返回响应头")
+	}
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+func TestLocalStorageStaysInRoot(t *testing.T) {
+	parent := t.TempDir()
+	s, err := NewLocalStorage(filepath.Join(parent, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(context.Background(), "../escape.txt", strings.NewReader("x"), 1); err == nil {
+		t.Error("写入根目录之外的键应当失败")
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("根目录之外不应当产生文件")
+	}
+
+	// 以"/"结尾的前缀只匹配目录，不应误删同名前缀的文件
+	if err := s.Put(context.Background(), "keep.txt", strings.NewReader("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePrefix(context.Background(), "keep/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat(context.Background(), "keep.txt"); err != nil {
+		t.Errorf("删除 keep/ 不应影响 keep.txt: %v", err)
+	}
+}
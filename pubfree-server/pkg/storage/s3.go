@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize 分片上传的分片大小，同时也是未知长度对象整体上传的阈值
+const s3PartSize = 16 << 20
+
+// S3Options S3兼容对象存储的连接参数
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // 所有对象键的公共前缀，便于多个环境共用一个桶
+	UseSSL    bool
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+// S3Storage S3兼容的对象存储（AWS S3、MinIO 等）
+type S3Storage struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure: opts.UseSSL,
+		Region: opts.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.Trim(opts.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3Storage{client: client, bucket: opts.Bucket, prefix: prefix}, nil
+}
+
+// CheckBucket 确认桶存在且可访问
+func (s *S3Storage) CheckBucket(ctx context.Context) error {
+	exists, err := s.client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("存储桶 %s 不存在", s.bucket)
+	}
+	return nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name, err := s.key(key)
+	if err != nil {
+		return err
+	}
+
+	// 长度未知时先读取一个分片，小文件直接整体上传，避免为每个文件发起分片上传
+	if size < 0 {
+		head, err := io.ReadAll(io.LimitReader(r, s3PartSize))
+		if err != nil {
+			return err
+		}
+		if len(head) < s3PartSize {
+			r, size = bytes.NewReader(head), int64(len(head))
+		} else {
+			r = io.MultiReader(bytes.NewReader(head), r)
+		}
+	}
+
+	_, err = s.client.PutObject(ctx, s.bucket, name, r, size, minio.PutObjectOptions{
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		PartSize:    s3PartSize,
+	})
+	return err
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.key(key)
+	if err != nil {
+		return nil, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, s.convertError(err)
+	}
+	// GetObject 是惰性的，通过 Stat 确认对象存在
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, s.convertError(err)
+	}
+	return object, nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.key(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, name, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.convertError(err)
+	}
+	return &ObjectInfo{Key: key, Size: info.Size, ModTime: info.LastModified}, nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if err := checkPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		objects = append(objects, ObjectInfo{
+			Key:     strings.TrimPrefix(object.Key, s.prefix),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+	return objects, nil
+}
+
+func (s *S3Storage) DeletePrefix(ctx context.Context, prefix string) error {
+	if err := checkPrefix(prefix); err != nil {
+		return err
+	}
+	if s.prefix+prefix == "" {
+		return fmt.Errorf("%w: 不允许删除整个存储桶", ErrInvalidKey)
+	}
+
+	// RemoveObjects 出错提前结束时取消列举，避免列举协程阻塞在发送上
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.prefix + prefix,
+		Recursive: true,
+	})
+
+	// 列举出错时 RemoveObjects 无法感知，单独记录下来
+	var listErr error
+	listDone := make(chan struct{})
+	toRemove := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(listDone)
+		defer close(toRemove)
+		for object := range objects {
+			if object.Err != nil {
+				listErr = object.Err
+				continue
+			}
+			select {
+			case toRemove <- object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var removeErr error
+	for result := range s.client.RemoveObjects(ctx, s.bucket, toRemove, minio.RemoveObjectsOptions{}) {
+		if removeErr == nil {
+			removeErr = fmt.Errorf("删除对象 %s 失败: %w", result.ObjectName, result.Err)
+		}
+	}
+	cancel()
+	<-listDone
+
+	if removeErr != nil {
+		return removeErr
+	}
+	return listErr
+}
+
+func (s *S3Storage) key(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.prefix + cleaned, nil
+}
+
+func (s *S3Storage) convertError(err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey", "NotFound":
+		return ErrNotFound
+	}
+	return err
+}
+
+// checkPrefix 前缀允许以"/"结尾，但不能包含 ".."
+func checkPrefix(prefix string) error {
+	if strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, prefix)
+	}
+	for _, part := range strings.Split(prefix, "/") {
+		if part == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, prefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 设置以下环境变量后同时对真实的 MinIO 运行测试，桶需要预先创建：
+// PUBFREE_TEST_S3_ENDPOINT、PUBFREE_TEST_S3_BUCKET、PUBFREE_TEST_S3_ACCESS_KEY、PUBFREE_TEST_S3_SECRET_KEY
+func TestS3StorageMinIO(t *testing.T) {
+	endpoint := os.Getenv("PUBFREE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("未设置 PUBFREE_TEST_S3_ENDPOINT")
+	}
+	s, err := NewS3Storage(S3Options{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    os.Getenv("PUBFREE_TEST_S3_BUCKET"),
+		AccessKey: os.Getenv("PUBFREE_TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("PUBFREE_TEST_S3_SECRET_KEY"),
+		Prefix:    fmt.Sprintf("storage-test-%d", time.Now().UnixNano()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckBucket(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, prefix := range []string{"deploys/", "uploads/"} {
+			if err := s.DeletePrefix(context.Background(), prefix); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+	testStorage(t, s)
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := newFakeS3("pubfree")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s, err := NewS3Storage(S3Options{
+		Endpoint: strings.TrimPrefix(server.URL, "http://"),
+		Region:   "us-east-1",
+		Bucket:   "pubfree",
+		Prefix:   "/test/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+
+	// 所有对象都应位于配置的公共前缀下
+	for _, key := range fake.keys() {
+		if !strings.HasPrefix(key, "test/") {
+			t.Errorf("对象 %s 不在公共前缀下", key)
+		}
+	}
+}
+
+func TestS3StorageDeleteWholeBucket(t *testing.T) {
+	s, err := NewS3Storage(S3Options{Endpoint: "127.0.0.1:1", Region: "us-east-1", Bucket: "pubfree"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeletePrefix(context.Background(), ""); err == nil {
+		t.Error("未设置公共前缀时不允许删除整个存储桶")
+	}
+}
+
+// fakeS3 内存中的 S3 服务，仅实现存储用到的接口，不校验签名
+type fakeS3 struct {
+	bucket string
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3(bucket string) *fakeS3 {
+	return &fakeS3{bucket: bucket, objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r.URL.Query())
+	case key == "" && r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		f.delete(w, r)
+	case key != "" && r.Method == http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			f.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+	case key != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		data, ok := f.objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	default:
+		f.error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, query url.Values) {
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+		ETag         string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: f.bucket, Prefix: query.Get("prefix"), MaxKeys: 1000}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, result.Prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			Size:         len(f.objects[key]),
+			LastModified: time.Now().UTC().Format(time.RFC3339),
+			ETag:         `"etag"`,
+		})
+	}
+	result.KeyCount = len(result.Contents)
+	writeXML(w, result)
+}
+
+func (f *fakeS3) delete(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	type deleted struct{ Key string }
+	result := struct {
+		XMLName xml.Name  `xml:"DeleteResult"`
+		Deleted []deleted `xml:"Deleted"`
+	}{}
+	for _, object := range request.Objects {
+		delete(f.objects, object.Key)
+		result.Deleted = append(result.Deleted, deleted{Key: object.Key})
+	}
+	writeXML(w, result)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+// readPayload 读取请求体，兼容客户端附带校验和时使用的 aws-chunked 编码
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") &&
+		!strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
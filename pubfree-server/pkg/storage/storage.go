@@ -0,0 +1,61 @@
+// Package storage 部署产物的存储抽象，对象以"/"分隔的键寻址，例如 deploys/1/23/index.html
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: 对象不存在")
+	ErrInvalidKey = errors.New("storage: 无效的对象键")
+)
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage 对象存储接口
+type Storage interface {
+	// Put 写入对象，size 未知时传 -1，已存在的对象会被覆盖
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取对象，不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat 获取对象元信息，不存在时返回 ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// List 列出指定前缀下的全部对象，按键排序
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// DeletePrefix 删除指定前缀下的全部对象
+	DeletePrefix(ctx context.Context, prefix string) error
+}
+
+// CleanKey 规范化对象键，拒绝绝对路径以及包含 ".." 的键
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return cleaned, nil
+}
+
+// Join 拼接对象键
+func Join(elem ...string) string {
+	return path.Join(elem...)
+}
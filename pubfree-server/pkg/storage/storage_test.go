@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "deploys/1/index.html", want: "deploys/1/index.html"},
+		{key: "deploys//1/./index.html", want: "deploys/1/index.html"},
+		{key: `deploys\1\index.html`, want: "deploys/1/index.html"},
+		{key: "deploys/1/", want: "deploys/1"},
+		{key: "", wantErr: true},
+		{key: ".", wantErr: true},
+		{key: "/etc/passwd", wantErr: true},
+		{key: "../secret", wantErr: true},
+		{key: "deploys/../../secret", wantErr: true},
+		{key: `deploys\..\secret`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("CleanKey(%q) err = %v, 期望 ErrInvalidKey", tt.key, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("CleanKey(%q) = %q, %v, 期望 %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+// testStorage 各存储实现共用的行为测试
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	files := map[string]string{
+		"deploys/1/index.html":     "<html>1</html>",
+		"deploys/1/assets/app.js":  "console.log(1)",
+		"deploys/10/index.html":    "<html>10</html>",
+		"deploys/2/index.html":     "<html>2</html>",
+		"uploads/tmp/artifact.zip": "zip",
+	}
+	for key, content := range files {
+		// 长度未知的写入走与已知长度不同的分支，两种都要覆盖
+		size := int64(len(content))
+		if strings.HasSuffix(key, ".js") {
+			size = -1
+		}
+		if err := s.Put(ctx, key, strings.NewReader(content), size); err != nil {
+			t.Fatalf("Put(%s) 出错: %v", key, err)
+		}
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		r, err := s.Get(ctx, "deploys/1/assets/app.js")
+		if err != nil {
+			t.Fatalf("Get 出错: %v", err)
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		if string(data) != "console.log(1)" {
+			t.Errorf("Get 内容 = %q", data)
+		}
+
+		if _, err := s.Get(ctx, "deploys/1/missing.html"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("读取不存在的对象 err = %v, 期望 ErrNotFound", err)
+		}
+		if _, err := s.Get(ctx, "../outside"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("读取非法键 err = %v, 期望 ErrInvalidKey", err)
+		}
+	})
+
+	t.Run("Put 覆盖已有对象", func(t *testing.T) {
+		if err := s.Put(ctx, "deploys/2/index.html", bytes.NewReader([]byte("<html>2.1</html>")), 16); err != nil {
+			t.Fatalf("Put 出错: %v", err)
+		}
+		info, err := s.Stat(ctx, "deploys/2/index.html")
+		if err != nil {
+			t.Fatalf("Stat 出错: %v", err)
+		}
+		if info.Key != "deploys/2/index.html" || info.Size != 16 {
+			t.Errorf("Stat = %+v", info)
+		}
+		if _, err := s.Stat(ctx, "deploys/2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat 目录前缀 err = %v, 期望 ErrNotFound", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		tests := []struct {
+			prefix string
+			want   []string
+		}{
+			{"deploys/1/", []string{"deploys/1/assets/app.js", "deploys/1/index.html"}},
+			{"deploys/1", []string{"deploys/1/assets/app.js", "deploys/1/index.html", "deploys/10/index.html"}},
+			{"uploads/", []string{"uploads/tmp/artifact.zip"}},
+			{"missing/", nil},
+		}
+		for _, tt := range tests {
+			objects, err := s.List(ctx, tt.prefix)
+			if err != nil {
+				t.Fatalf("List(%s) 出错: %v", tt.prefix, err)
+			}
+			var keys []string
+			for _, object := range objects {
+				keys = append(keys, object.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("List(%s) = %v, 期望 %v", tt.prefix, keys, tt.want)
+			}
+		}
+		if _, err := s.List(ctx, "../"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("List 非法前缀 err = %v, 期望 ErrInvalidKey", err)
+		}
+	})
+
+	t.Run("DeletePrefix", func(t *testing.T) {
+		if err := s.DeletePrefix(ctx, "deploys/1/"); err != nil {
+			t.Fatalf("DeletePrefix 出错: %v", err)
+		}
+		objects, err := s.List(ctx, "deploys/")
+		if err != nil {
+			t.Fatalf("List 出错: %v", err)
+		}
+		var keys []string
+		for _, object := range objects {
+			keys = append(keys, object.Key)
+		}
+		if want := []string{"deploys/10/index.html", "deploys/2/index.html"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("删除后剩余 %v, 期望 %v", keys, want)
+		}
+		if err := s.DeletePrefix(ctx, "missing/"); err != nil {
+			t.Errorf("删除不存在的前缀出错: %v", err)
+		}
+		if err := s.DeletePrefix(ctx, "../"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("DeletePrefix 非法前缀 err = %v, 期望 ErrInvalidKey", err)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"pubfree-platform/pubfree-server/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims 访问令牌中携带的用户信息
+type Claims struct {
+	UserID    uint   `json:"uid"`
+	Name      string `json:"name"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken 根据JWT配置签发访问令牌，返回令牌及其过期时间
+func GenerateToken(cfg config.JWTConfig, userID uint, name, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(cfg.ExpiresAt)
+
+	claims := Claims{
+		UserID:    userID,
+		Name:      name,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Secret))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ParseToken 校验并解析访问令牌
+func ParseToken(cfg config.JWTConfig, tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.Secret), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.UserID == 0 || claims.SessionID == "" {
+		return nil, errors.New("无效的令牌")
+	}
+	return claims, nil
+}
@@ -0,0 +1,92 @@
+// Package workerpool 固定数量 goroutine 的后台任务池，队列有上限，支持优雅关闭
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrQueueFull = errors.New("任务队列已满")
+	ErrClosed    = errors.New("任务池已关闭")
+)
+
+// Job 后台任务。关闭超时后 ctx 会被取消，任务应尽快结束并自行记录中断
+type Job func(ctx context.Context)
+
+type Pool struct {
+	jobs   chan Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// New 创建并启动任务池，workers 为并发数，queueSize 为等待执行的任务上限
+func New(workers, queueSize int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{
+		jobs:   make(chan Job, queueSize),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		job(p.ctx)
+	}
+}
+
+// Submit 提交任务，不阻塞。队列已满返回 ErrQueueFull，关闭后返回 ErrClosed
+func (p *Pool) Submit(job Job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Shutdown 停止接收新任务，等待队列中和执行中的任务完成。
+// ctx 结束时取消任务的 context，再等待任务退出，返回 ctx 的错误
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
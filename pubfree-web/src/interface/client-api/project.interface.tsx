@@ -103,4 +103,6 @@ export interface IProjectDeployDTO {
 export enum EDeployTargetType {
   Local = 0,
   Cloud = 1,
+  Git = 2,
+  Manifest = 3,
 }